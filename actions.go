@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//actions shared by the CLI handlers and the HTTP server, so both behave the
+//same way; errors wrap errNotFound / errConflict where the caller may care
+
+var (
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("already exists")
+)
+
+//registerUser creates a user, password-protected unless password is empty
+func registerUser(ctx context.Context, s *state, name, password string) (database.User, error) {
+	//check if user already exists
+	_, err := s.db.GetUser(ctx, name)
+	if err == nil {
+		return database.User{}, fmt.Errorf("User %v %w", name, errConflict)
+	}
+
+	var hash sql.NullString
+	if password != "" {
+		hash, err = hashPassword(password)
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	//create the user and set their password together, so that a failure
+	//doesn't leave an account without the password
+	var user database.User
+	err = inTx(ctx, s, func(tx *state) error {
+		user, err = tx.db.CreateUser(ctx, database.CreateUserParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name: name,
+		})
+		if err != nil {
+			if database.IsUniqueViolation(err) {
+				return fmt.Errorf("User %v %w", name, errConflict)
+			}
+			return fmt.Errorf("Error creating User: %v", err)
+		}
+
+		if hash.Valid {
+			err = tx.db.SetUserPassword(ctx, database.SetUserPasswordParams{
+				ID: user.ID,
+				PasswordHash: hash,
+				UpdatedAt: time.Now(),
+			})
+			if err != nil {
+				return fmt.Errorf("Error setting password: %v", err)
+			}
+			user.PasswordHash = hash
+		}
+		return nil
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+//deleteUser deletes a user along with the feeds they added and everything
+//else they own. It refuses while other users follow one of their feeds
+//unless force is set, and returns the names of the deleted feeds
+func deleteUser(ctx context.Context, s *state, user database.User, force bool) ([]string, error) {
+	deleted := []string{}
+	err := inTx(ctx, s, func(tx *state) error {
+		var err error
+		deleted, err = checkSharedFeeds(ctx, tx, user, force)
+		if err != nil {
+			return err
+		}
+
+		//feeds, follows, sessions, keys and the rest cascade
+		err = tx.db.DeleteUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error deleting user: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+//checkSharedFeeds returns the names of the feeds user added, about to be
+//deleted with their follows. It refuses while other users follow one of
+//them unless force is set.
+func checkSharedFeeds(ctx context.Context, s *state, user database.User, force bool) ([]string, error) {
+	feeds, err := s.db.GetFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting feeds: %v", err)
+	}
+
+	owned := []string{}
+	shared := []string{}
+	for _, feed := range feeds {
+		if feed.UserID != user.ID {
+			continue
+		}
+		owned = append(owned, feed.Name)
+
+		followers, err := s.db.GetFeedFollowerNames(ctx, feed.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting feed followers: %v", err)
+		}
+		others := []string{}
+		for _, name := range followers {
+			if name != user.Name {
+				others = append(others, name)
+			}
+		}
+		if len(others) > 0 {
+			shared = append(shared, fmt.Sprintf("%v (%v)", feed.Name, strings.Join(others, ", ")))
+		}
+	}
+	if len(shared) > 0 && !force {
+		return nil, fmt.Errorf("Other users follow feeds %v added: %v; transfer them with transfer-feed or delete anyway with --force", user.Name, strings.Join(shared, "; "))
+	}
+	return owned, nil
+}
+
+//renameUser gives a user a new name. App passwords are derived from the
+//name, so the user's one is removed; signedOut reports whether there was one
+func renameUser(ctx context.Context, s *state, user database.User, name string) (renamed database.User, signedOut bool, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return database.User{}, false, fmt.Errorf("The user name can't be empty")
+	}
+
+	err = inTx(ctx, s, func(tx *state) error {
+		var err error
+		renamed, err = tx.db.RenameUser(ctx, database.RenameUserParams{
+			ID: user.ID,
+			Name: name,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			if database.IsUniqueViolation(err) {
+				return fmt.Errorf("User %v %w", name, errConflict)
+			}
+			return fmt.Errorf("Error renaming user: %v", err)
+		}
+
+		n, err := tx.db.DeleteAppPassword(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error removing app password: %v", err)
+		}
+		signedOut = n > 0
+		return nil
+	})
+	if err != nil {
+		return database.User{}, false, err
+	}
+
+	return renamed, signedOut, nil
+}
+
+//addFeed creates a feed and makes the user who added it follow it
+func addFeed(ctx context.Context, s *state, user database.User, name, url string) (database.Feed, error) {
+	//the feed and its follow are saved together, or not at all
+	var feed database.Feed
+	err := inTx(ctx, s, func(tx *state) error {
+		//Create feed
+		var err error
+		feed, err = tx.db.CreateFeed(ctx, database.CreateFeedParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name: name,
+			Url: url,
+			UserID: user.ID,
+		})
+		if err != nil {
+			if database.IsUniqueViolation(err) {
+				return fmt.Errorf("Feed %v %w", url, errConflict)
+			}
+			return fmt.Errorf("Error creating feed: %v", err)
+		}
+
+		//Create Feed_Follow record
+		_, err = tx.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID: user.ID,
+			FeedID: feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("Error creating feed_follow record: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	return feed, nil
+}
+
+//followFeed makes the user follow the feed with the given url
+func followFeed(ctx context.Context, s *state, user database.User, url string) (database.Feed, database.CreateFeedFollowRow, error) {
+	//Get FeedID
+	feed, err := getFeedByURL(ctx, s, url)
+	if err != nil {
+		return database.Feed{}, database.CreateFeedFollowRow{}, err
+	}
+
+	//Create Feed_Follow record, in its own (nested) transaction so that
+	//following a feed twice doesn't spoil a transaction around this one
+	var follow database.CreateFeedFollowRow
+	err = inTx(ctx, s, func(tx *state) error {
+		var err error
+		follow, err = tx.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID: user.ID,
+			FeedID: feed.ID,
+		})
+		return err
+	})
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return database.Feed{}, database.CreateFeedFollowRow{}, fmt.Errorf("Follow of %v %w", url, errConflict)
+		}
+		return database.Feed{}, database.CreateFeedFollowRow{}, fmt.Errorf("Error creating feed_follow record: %v", err)
+	}
+
+	return feed, follow, nil
+}
+
+//unfollowFeed removes the user's follow of the feed with the given url
+func unfollowFeed(ctx context.Context, s *state, user database.User, url string) (database.Feed, error) {
+	//Get FeedID
+	feed, err := getFeedByURL(ctx, s, url)
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	//delete record of feed_follow
+	err = s.db.Unfollow(ctx, database.UnfollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("Error unfollowing: %v", err)
+	}
+
+	return feed, nil
+}
+
+//ownedFeed finds a feed by url, or by name when no feed has that url, and
+//checks the user is the one who added it (or was handed it)
+func ownedFeed(ctx context.Context, s *state, user database.User, urlOrName string) (database.Feed, error) {
+	feed, err := getFeedByURL(ctx, s, urlOrName)
+	if errors.Is(err, errNotFound) {
+		feed, err = getFeedByName(ctx, s, urlOrName)
+	}
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	if feed.UserID != user.ID {
+		owner, err := s.db.GetUserNameFromID(ctx, feed.UserID)
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("Error getting user name from id: %v", err)
+		}
+		return database.Feed{}, fmt.Errorf("Feed %v belongs to %v, only they can change it", feed.Name, owner)
+	}
+	return feed, nil
+}
+
+//removeFeed deletes a feed the user owns, along with its follows and posts.
+//It refuses while other users follow the feed unless force is set, and
+//returns the names of the other users who were following it
+func removeFeed(ctx context.Context, s *state, user database.User, urlOrName string, force bool) (database.Feed, []string, error) {
+	var feed database.Feed
+	others := []string{}
+	err := inTx(ctx, s, func(tx *state) error {
+		var err error
+		feed, err = ownedFeed(ctx, tx, user, urlOrName)
+		if err != nil {
+			return err
+		}
+
+		followers, err := tx.db.GetFeedFollowerNames(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("Error getting feed followers: %v", err)
+		}
+		for _, name := range followers {
+			if name != user.Name {
+				others = append(others, name)
+			}
+		}
+		if len(others) > 0 && !force {
+			return fmt.Errorf("Feed %v is still followed by %v; transfer it with transfer-feed or delete it anyway with --force", feed.Name, strings.Join(others, ", "))
+		}
+
+		//follows, posts and everything hanging off them cascade
+		err = tx.db.DeleteFeed(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("Error deleting feed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, nil, err
+	}
+
+	return feed, others, nil
+}
+
+//editFeed renames a feed the user owns and/or points it at a new url,
+//keeping the values left empty
+func editFeed(ctx context.Context, s *state, user database.User, urlOrName, name, url string) (database.Feed, error) {
+	var feed database.Feed
+	err := inTx(ctx, s, func(tx *state) error {
+		var err error
+		feed, err = ownedFeed(ctx, tx, user, urlOrName)
+		if err != nil {
+			return err
+		}
+
+		if name == "" {
+			name = feed.Name
+		}
+		if url == "" {
+			url = feed.Url
+		}
+		feed, err = tx.db.UpdateFeed(ctx, database.UpdateFeedParams{
+			ID: feed.ID,
+			Name: name,
+			Url: url,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			if database.IsUniqueViolation(err) {
+				return fmt.Errorf("Feed %v %w", url, errConflict)
+			}
+			return fmt.Errorf("Error updating feed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	return feed, nil
+}
+
+//transferFeed hands a feed the user owns over to another user
+func transferFeed(ctx context.Context, s *state, user database.User, urlOrName, newOwner string) (database.Feed, database.User, error) {
+	var feed database.Feed
+	var owner database.User
+	err := inTx(ctx, s, func(tx *state) error {
+		var err error
+		feed, err = ownedFeed(ctx, tx, user, urlOrName)
+		if err != nil {
+			return err
+		}
+
+		owner, err = tx.db.GetUser(ctx, newOwner)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("User %v %w", newOwner, errNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("Error getting user: %v", err)
+		}
+		if owner.ID == user.ID {
+			return fmt.Errorf("Feed %v already belongs to %v", feed.Name, owner.Name)
+		}
+
+		err = tx.db.SetFeedOwner(ctx, database.SetFeedOwnerParams{
+			ID: feed.ID,
+			UserID: owner.ID,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("Error transferring feed: %v", err)
+		}
+		feed.UserID = owner.ID
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, database.User{}, err
+	}
+
+	return feed, owner, nil
+}
+
+//getFeedByName finds a feed by its name, which unlike its url needn't be
+//unique
+func getFeedByName(ctx context.Context, s *state, name string) (database.Feed, error) {
+	feeds, err := s.db.GetFeeds(ctx)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("Error getting feeds: %v", err)
+	}
+
+	found := []database.Feed{}
+	for _, feed := range feeds {
+		if feed.Name == name {
+			found = append(found, feed)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return database.Feed{}, fmt.Errorf("Feed %v %w", name, errNotFound)
+	case 1:
+		return found[0], nil
+	}
+	return database.Feed{}, fmt.Errorf("%v feeds are named %v, use the url instead", len(found), name)
+}
+
+func getFeedByURL(ctx context.Context, s *state, url string) (database.Feed, error) {
+	feed, err := s.db.GetFeed(ctx, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, fmt.Errorf("Feed %v %w", url, errNotFound)
+	}
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("Error getting feed: %v", err)
+	}
+	return feed, nil
+}
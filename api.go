@@ -0,0 +1,256 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//default and maximum number of posts returned by GET .../posts
+const (
+	defaultPostsLimit = 20
+	maxPostsLimit = 500
+)
+
+type apiUserHandler func(http.ResponseWriter, *http.Request, database.User)
+
+//middlewareAuth resolves the user from an "Authorization: Bearer <api key>"
+//header, the HTTP counterpart of middlewareLoggedIn. Read-only keys are
+//limited to GET requests, and a {name} in the path must be the key's owner.
+func (cfg *apiConfig) middlewareAuth(handler apiUserHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			respondWithError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		key, err := cfg.s.db.GetUserByAPIKey(r.Context(), hashToken(strings.TrimSpace(token)))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "Invalid api key")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting api key: "+err.Error())
+			return
+		}
+
+		switch {
+		case key.RevokedAt.Valid:
+			respondWithError(w, http.StatusUnauthorized, "Api key has been revoked")
+			return
+		case key.ExpiresAt.Valid && key.ExpiresAt.Time.Before(time.Now()):
+			respondWithError(w, http.StatusUnauthorized, "Api key has expired")
+			return
+		case key.Scope == scopeReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead:
+			respondWithError(w, http.StatusForbidden, "Api key is read-only")
+			return
+		}
+
+		if name := r.PathValue("name"); name != "" && name != key.User.Name {
+			respondWithError(w, http.StatusForbidden, "Api key does not belong to "+name)
+			return
+		}
+
+		err = cfg.s.db.TouchAPIKey(r.Context(), database.TouchAPIKeyParams{
+			ID: key.KeyID,
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error recording api key use: %v", err)
+		}
+
+		handler(w, r, key.User)
+	}
+}
+
+//authenticated requires an api key for handlers that don't need the user
+func (cfg *apiConfig) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, _ database.User) {
+		handler(w, r)
+	})
+}
+
+func (cfg *apiConfig) handlerUsersGet(w http.ResponseWriter, r *http.Request) {
+	users, err := cfg.s.db.GetUsers(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error returning users: "+err.Error())
+		return
+	}
+
+	records := []userRecord{}
+	for _, user := range users {
+		records = append(records, newUserRecord(user, ""))
+	}
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+func (cfg *apiConfig) handlerFeedsGet(w http.ResponseWriter, r *http.Request) {
+	feeds, err := cfg.s.db.GetFeeds(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feeds: "+err.Error())
+		return
+	}
+
+	records := []feedRecord{}
+	for _, feed := range feeds {
+		name, err := cfg.s.db.GetUserNameFromID(r.Context(), feed.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting user name from id: "+err.Error())
+			return
+		}
+		records = append(records, newFeedRecord(feed, name))
+	}
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+func (cfg *apiConfig) handlerFeedsCreate(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+		Url string `json:"url"`
+	}
+	params := parameters{}
+	if err := decodeJSON(r, &params); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.Name == "" || params.Url == "" {
+		respondWithError(w, http.StatusBadRequest, "name and url are required")
+		return
+	}
+
+	feed, err := addFeed(r.Context(), cfg.s, user, params.Name, params.Url)
+	if err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newFeedRecord(feed, user.Name))
+}
+
+func (cfg *apiConfig) handlerFollowsGet(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := cfg.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting following feeds: "+err.Error())
+		return
+	}
+
+	records := []followRecord{}
+	for _, follow := range follows {
+		records = append(records, newFollowRecord(follow))
+	}
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+func (cfg *apiConfig) handlerFollowsCreate(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Url string `json:"url"`
+	}
+	params := parameters{}
+	if err := decodeJSON(r, &params); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.Url == "" {
+		respondWithError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	feed, follow, err := followFeed(r.Context(), cfg.s, user, params.Url)
+	if err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, followRecord{
+		ID: follow.ID,
+		FeedID: follow.FeedID,
+		FeedName: follow.FeedName,
+		FeedUrl: feed.Url,
+		UserID: follow.UserID,
+		UserName: follow.UserName,
+		CreatedAt: follow.CreatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerFollowsDelete(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid feed id")
+		return
+	}
+
+	feed, err := cfg.s.db.GetFeedByID(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed: "+err.Error())
+		return
+	}
+
+	if _, err := unfollowFeed(r.Context(), cfg.s, user, feed.Url); err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//handlerPostsGet lists posts with the same filters as browse:
+//?limit=, ?feed= (url or name), ?unread=true and ?starred=true, and the
+//user's rules applied as browse does
+func (cfg *apiConfig) handlerPostsGet(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+
+	limit := defaultPostsLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxPostsLimit)
+	}
+
+	feedID := uuid.NullUUID{}
+	if v := query.Get("feed"); v != "" {
+		follow, err := findFollowedFeed(cfg.s, user, v)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+	}
+
+	unread, _ := strconv.ParseBool(query.Get("unread"))
+	starred, _ := strconv.ParseBool(query.Get("starred"))
+
+	posts, err := listPosts(r.Context(), cfg.s, user, database.GetPostsForUserParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		UnreadOnly: unread,
+		StarredOnly: starred,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	records := []postRecord{}
+	for _, post := range posts {
+		records = append(records, newPostRecord(post))
+	}
+	respondWithJSON(w, http.StatusOK, records)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//apiClient sends requests to a server on e's database with a new key of the
+//user logged in when it was made, created with the given apikey create flags
+type apiClient struct {
+	t   *testing.T
+	url string
+	key string
+}
+
+func (e *testEnv) apiClient(flags ...string) *apiClient {
+	e.t.Helper()
+	out := e.mustRun(append([]string{"apikey", "create", "--name", "test"}, flags...)...)
+	lines := strings.Split(out, "\n")
+	server := httptest.NewServer((&apiConfig{s: e.s}).routes())
+	e.t.Cleanup(server.Close)
+	return &apiClient{t: e.t, url: server.URL, key: strings.TrimSpace(lines[1])}
+}
+
+//send makes a request, decoding the response into v if given
+func (c *apiClient) send(method, path, body string, v any) int {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			c.t.Fatalf("decoding %v %v: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIDoesNotCreateUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	c := e.apiClient()
+
+	if code := c.send(http.MethodGet, "/v1/users", "", nil); code != http.StatusOK {
+		t.Fatalf("listing users with alice's key: got %v, want 200", code)
+	}
+	//a read-write key acts as its owner, who may not sign up others
+	if code := c.send(http.MethodPost, "/v1/users", `{"name": "mallory"}`, nil); code == http.StatusCreated {
+		t.Fatal("alice's key created a user")
+	}
+	if counts := e.counts(); counts.Users != 1 {
+		t.Fatalf("there are %v users, want only alice", counts.Users)
+	}
+}
+
+func TestAPIPostsApplyRules(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("sponsored deal", now),
+		testItem("first post", now.Add(-time.Minute)),
+		testItem("second post", now.Add(-2*time.Minute)),
+	)
+	e.mustRun("rule", "add", "sponsored")
+	c := e.apiClient()
+
+	var posts []postRecord
+	if code := c.send(http.MethodGet, "/v1/users/alice/posts?limit=2", "", &posts); code != http.StatusOK {
+		t.Fatalf("listing alice's posts: got %v", code)
+	}
+	titles := []string{}
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	if want := []string{"first post", "second post"}; !slices.Equal(titles, want) {
+		t.Fatalf("the api lists %v, want %v as browse does", titles, want)
+	}
+}
+
+func TestAPIFeedsAndFollows(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	alice := e.apiClient()
+
+	if code := alice.send(http.MethodGet, "/v1/healthz", "", nil); code != http.StatusOK {
+		t.Fatalf("healthz: got %v, want 200", code)
+	}
+	anonymous := &apiClient{t: t, url: alice.url}
+	if code := anonymous.send(http.MethodGet, "/v1/feeds", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("listing feeds without a key: got %v, want 401", code)
+	}
+
+	for _, body := range []string{`{"name": "blog"}`, `{"name": "blog", "url": "x", "extra": 1}`, `{`} {
+		if code := alice.send(http.MethodPost, "/v1/users/alice/feeds", body, nil); code != http.StatusBadRequest {
+			t.Errorf("adding a feed with %v: got %v, want 400", body, code)
+		}
+	}
+	var feed feedRecord
+	code := alice.send(http.MethodPost, "/v1/users/alice/feeds", `{"name": "blog", "url": "http://example.com/feed"}`, &feed)
+	if code != http.StatusCreated || feed.Name != "blog" || feed.AddedBy != "alice" {
+		t.Fatalf("adding a feed: got %v %+v", code, feed)
+	}
+	if code := alice.send(http.MethodPost, "/v1/users/alice/feeds", `{"name": "again", "url": "http://example.com/feed"}`, nil); code != http.StatusConflict {
+		t.Errorf("adding a feed twice: got %v, want 409", code)
+	}
+
+	var feeds []feedRecord
+	if code := alice.send(http.MethodGet, "/v1/feeds", "", &feeds); code != http.StatusOK || len(feeds) != 1 || feeds[0].ID != feed.ID {
+		t.Fatalf("listing feeds: got %v %+v", code, feeds)
+	}
+
+	e.mustRun("register", "bob")
+	bob := e.apiClient()
+	if code := bob.send(http.MethodPost, "/v1/users/bob/follows", `{"url": "http://example.com/none"}`, nil); code != http.StatusNotFound {
+		t.Errorf("following an unknown feed: got %v, want 404", code)
+	}
+	var follow followRecord
+	code = bob.send(http.MethodPost, "/v1/users/bob/follows", `{"url": "http://example.com/feed"}`, &follow)
+	if code != http.StatusCreated || follow.FeedID != feed.ID || follow.UserName != "bob" {
+		t.Fatalf("following blog: got %v %+v", code, follow)
+	}
+
+	var follows []followRecord
+	if code := bob.send(http.MethodGet, "/v1/users/bob/follows", "", &follows); code != http.StatusOK || len(follows) != 1 {
+		t.Fatalf("listing bob's follows: got %v %+v", code, follows)
+	}
+
+	if code := bob.send(http.MethodDelete, "/v1/users/bob/follows/not-an-id", "", nil); code != http.StatusBadRequest {
+		t.Errorf("unfollowing an invalid id: got %v, want 400", code)
+	}
+	if code := bob.send(http.MethodDelete, "/v1/users/bob/follows/"+uuid.NewString(), "", nil); code != http.StatusNotFound {
+		t.Errorf("unfollowing an unknown feed: got %v, want 404", code)
+	}
+	if code := bob.send(http.MethodDelete, "/v1/users/bob/follows/"+feed.ID.String(), "", nil); code != http.StatusNoContent {
+		t.Fatalf("unfollowing blog: got %v, want 204", code)
+	}
+	follows = nil
+	if bob.send(http.MethodGet, "/v1/users/bob/follows", "", &follows); len(follows) != 0 {
+		t.Fatalf("bob still follows %+v", follows)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "bob")
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	if _, err := e.run("apikey", "create", "--expires", "soon"); err == nil {
+		t.Fatal("creating a key with an invalid lifetime succeeded")
+	}
+
+	readOnly := e.apiClient("--read-only")
+	if code := readOnly.send(http.MethodGet, "/v1/users/alice/follows", "", nil); code != http.StatusOK {
+		t.Fatalf("listing follows with a read-only key: got %v, want 200", code)
+	}
+	if code := readOnly.send(http.MethodPost, "/v1/users/alice/follows", `{"url": "http://example.com/feed"}`, nil); code != http.StatusForbidden {
+		t.Fatalf("following with a read-only key: got %v, want 403", code)
+	}
+
+	c := e.apiClient()
+	if code := c.send(http.MethodGet, "/v1/users/bob/follows", "", nil); code != http.StatusForbidden {
+		t.Fatalf("listing bob's follows with alice's key: got %v, want 403", code)
+	}
+	wrong := &apiClient{t: t, url: c.url, key: c.key + "x"}
+	if code := wrong.send(http.MethodGet, "/v1/users/alice/follows", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("listing follows with an unknown key: got %v, want 401", code)
+	}
+
+	expired := e.apiClient("--expires", "1ns")
+	time.Sleep(time.Millisecond)
+	if code := expired.send(http.MethodGet, "/v1/users/alice/follows", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("listing follows with an expired key: got %v, want 401", code)
+	}
+
+	if _, err := e.run("apikey", "revoke", "gtr_nothing"); err == nil {
+		t.Fatal("revoking an unknown key succeeded")
+	}
+	e.mustRun("apikey", "revoke", c.key[:len(apiKeyPrefix)+8]+"...")
+	if code := c.send(http.MethodGet, "/v1/users/alice/follows", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("listing follows with a revoked key: got %v, want 401", code)
+	}
+
+	out := e.mustRun("apikey", "list")
+	for _, want := range []string{"(active)", "(revoked)", "(expired)", "Scope: read-only"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("apikey list doesn't show %q:\n%v", want, out)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//api key scopes; read-only keys may only make GET requests
+const (
+	scopeReadWrite = "read-write"
+	scopeReadOnly = "read-only"
+)
+
+//token prefixes make gator secrets easy to spot in configs and logs
+const (
+	apiKeyPrefix = "gtr_"
+	sessionTokenPrefix = "gts_"
+	feedTokenPrefix = "gtf_"
+	webhookSecretPrefix = "gtw_"
+)
+
+//generateToken returns a new random secret and the hash stored for it
+func generateToken(prefix string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := prefix + hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+//hashToken hashes a secret token for storage; the tokens are random so a
+//plain SHA-256 is enough to keep them unusable if the database leaks
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func handlerAPIKeyCreate(s *state, cmd command, user database.User) error {
+	scope := scopeReadWrite
+	if cmd.flagBool("read-only") {
+		scope = scopeReadOnly
+	}
+
+	expiresAt := sql.NullTime{}
+	if v := cmd.flagString("expires"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("Error parsing duration %v", v)
+		}
+		expiresAt = sql.NullTime{Time: time.Now().Add(d), Valid: true}
+	}
+
+	key, hash, err := generateToken(apiKeyPrefix)
+	if err != nil {
+		return fmt.Errorf("Error generating api key: %v", err)
+	}
+
+	apiKey, err := s.db.CreateAPIKey(context.Background(), database.CreateAPIKeyParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID: user.ID,
+		Name: cmd.flagString("name"),
+		Prefix: key[:len(apiKeyPrefix)+8],
+		KeyHash: hash,
+		Scope: scope,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating api key: %v", err)
+	}
+
+	fmt.Println("New api key (shown once, store it somewhere safe):")
+	fmt.Printf("  %v\n\n", key)
+	fmt.Printf("  - ID: %v\n", apiKey.ID)
+	fmt.Printf("  - Scope: %v\n", apiKey.Scope)
+	if apiKey.ExpiresAt.Valid {
+		fmt.Printf("  - Expires at: %v\n", apiKey.ExpiresAt.Time)
+	}
+	fmt.Println("\nUse it with: Authorization: Bearer <key>")
+	return nil
+}
+
+func handlerAPIKeyList(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.db.GetAPIKeysForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting api keys: %v", err)
+	}
+
+	records := []apiKeyRecord{}
+	for _, key := range keys {
+		records = append(records, newAPIKeyRecord(key))
+	}
+
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	fmt.Println("API keys:")
+	for _, key := range records {
+		fmt.Println()
+		fmt.Printf("  - %v... (%v)\n", key.Prefix, key.Status)
+		fmt.Printf("    ID: %v\n", key.ID)
+		if key.Name != "" {
+			fmt.Printf("    Name: %v\n", key.Name)
+		}
+		fmt.Printf("    Scope: %v\n", key.Scope)
+		fmt.Printf("    Created at: %v\n", key.CreatedAt)
+		if key.ExpiresAt != nil {
+			fmt.Printf("    Expires at: %v\n", *key.ExpiresAt)
+		}
+		if key.LastUsedAt != nil {
+			fmt.Printf("    Last used at: %v\n", *key.LastUsedAt)
+		}
+	}
+
+	return nil
+}
+
+func handlerAPIKeyRevoke(s *state, cmd command, user database.User) error {
+	keys, err := s.db.GetAPIKeysForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting api keys: %v", err)
+	}
+
+	//the key can be given by id or by the prefix shown in "apikey list"
+	var match *database.ApiKey
+	for i, key := range keys {
+		if key.ID.String() == cmd.args[0] || strings.TrimSuffix(cmd.args[0], "...") == key.Prefix {
+			match = &keys[i]
+			break
+		}
+	}
+	if match == nil {
+		return fmt.Errorf("No api key with id or prefix %v", cmd.args[0])
+	}
+
+	n, err := s.db.RevokeAPIKey(context.Background(), database.RevokeAPIKeyParams{
+		ID: match.ID,
+		UserID: user.ID,
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("Error revoking api key: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("Api key %v is already revoked", match.Prefix)
+	}
+
+	fmt.Printf("Revoked api key %v...\n", match.Prefix)
+	return nil
+}
+
+//completeAPIKeys suggests the prefixes of the current user's active keys
+func completeAPIKeys(s *state) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	keys, err := s.db.GetAPIKeysForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	prefixes := []string{}
+	for _, key := range keys {
+		if !key.RevokedAt.Valid {
+			prefixes = append(prefixes, key.Prefix)
+		}
+	}
+	return prefixes
+}
+
+type apiKeyRecord struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scope      string     `json:"scope"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func newAPIKeyRecord(key database.ApiKey) apiKeyRecord {
+	status := "active"
+	switch {
+	case key.RevokedAt.Valid:
+		status = "revoked"
+	case key.ExpiresAt.Valid && key.ExpiresAt.Time.Before(time.Now()):
+		status = "expired"
+	}
+
+	return apiKeyRecord{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scope:      key.Scope,
+		Status:     status,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  nullTimePtr(key.ExpiresAt),
+		LastUsedAt: nullTimePtr(key.LastUsedAt),
+		RevokedAt:  nullTimePtr(key.RevokedAt),
+	}
+}
+
+func (r apiKeyRecord) header() []string {
+	return []string{"id", "name", "prefix", "scope", "status", "created_at", "expires_at", "last_used_at", "revoked_at"}
+}
+
+func (r apiKeyRecord) fields() []string {
+	return []string{r.ID.String(), r.Name, r.Prefix, r.Scope, r.Status, formatTime(r.CreatedAt), formatTimePtr(r.ExpiresAt), formatTimePtr(r.LastUsedAt), formatTimePtr(r.RevokedAt)}
+}
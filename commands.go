@@ -4,10 +4,15 @@ import (
 	"fmt"
 	"time"
 	"os"
-	"log"
+	"io"
+	"flag"
 	"context"
 	"strconv"
 	"strings"
+	"database/sql"
+	"log"
+	"sort"
+	"errors"
 	
 	"golang.org/x/net/html"
 	"github.com/google/uuid"
@@ -16,53 +21,277 @@ import (
 )
 
 type state struct {
-	db *database.Queries
+	db Store
+	sqlDB *sql.DB //the connection behind db, for migrations, nil for memory
+	dbDriver string //driverPostgres, driverSQLite or driverMemory
 	cfg *config.Config
 }
 
 type command struct {
 	name string
 	args []string
+	flags *flag.FlagSet
+}
+
+//commandSpec describes a registered command: how it is documented in help,
+//which flags and how many arguments it takes and the handler that runs it
+type commandSpec struct {
+	name string
+	summary string
+	usage string
+	minArgs int
+	maxArgs int //-1 for no limit
+	loginRequired bool
+	hidden bool
+	rawArgs bool //pass arguments through without parsing flags
+	noSchemaCheck bool //runs without checking the database schema is up to date
+	flags func(fs *flag.FlagSet)
+	complete func(s *state, flagName string, arg int) []string //suggestions for a flag value, or positional argument arg when flagName is ""
+	handler func(*state, command) error
+	userHandler func(*state, command, database.User) error
+	subcommands []commandSpec //dispatched on the first argument, as in "apikey create"
 }
 
 type commands struct {
-	commands map[string]func(*state, command) error
+	commands map[string]commandSpec
 }
 
 func (c *commands) run(s *state, cmd command) error {
 	//checking existence
-	fn, exists := c.commands[cmd.name]
+	spec, exists := c.commands[cmd.name]
 	if !exists {
-		return fmt.Errorf("No function with specified name")
+		if suggestion := c.suggest(cmd.name); suggestion != "" {
+			return fmt.Errorf("Unknown command %q, did you mean %q?", cmd.name, suggestion)
+		}
+		return fmt.Errorf("Unknown command %q, run \"%v help\" for a list of commands", cmd.name, programName)
+	}
+
+	//descending into subcommands
+	spec, cmd.args = resolveSubcommand(spec, cmd.args)
+	if len(spec.subcommands) > 0 {
+		if len(cmd.args) > 0 && (cmd.args[0] == "-h" || cmd.args[0] == "--help") {
+			c.printCommandHelp(os.Stdout, spec)
+			return nil
+		}
+		if len(cmd.args) == 0 {
+			return fmt.Errorf("Missing subcommand\nUsage: %v", spec.usageLine())
+		}
+		if suggestion := suggestName(cmd.args[0], spec.subcommandNames()); suggestion != "" {
+			return fmt.Errorf("Unknown subcommand %q, did you mean %q?", cmd.args[0], suggestion)
+		}
+		return fmt.Errorf("Unknown subcommand %q\nUsage: %v", cmd.args[0], spec.usageLine())
+	}
+
+	//parsing flags, which may be mixed in with the arguments
+	fs := spec.flagSet()
+	if !spec.rawArgs {
+		args, err := parseInterspersed(fs, cmd.args)
+		if err == flag.ErrHelp {
+			c.printCommandHelp(os.Stdout, spec)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v\nUsage: %v", err, spec.usageLine())
+		}
+		cmd.args = args
+	}
+	cmd.flags = fs
+
+	//checking argument count
+	if len(cmd.args) < spec.minArgs || (spec.maxArgs >= 0 && len(cmd.args) > spec.maxArgs) {
+		return fmt.Errorf("Wrong number of arguments\nUsage: %v", spec.usageLine())
 	}
 
-	return fn(s, cmd)
+	if !spec.noSchemaCheck {
+		if err := checkSchema(context.Background(), s); err != nil {
+			return err
+		}
+	}
+
+	return spec.handler(s, cmd)
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	_, exists := c.commands[name]
+func (c *commands) register(spec commandSpec) {
+	_, exists := c.commands[spec.name]
 	if exists {
 		fmt.Println("Function already registered")
 	}
 
-	c.commands[name] = f
+	c.commands[spec.name] = prepareSpec(spec)
 }
 
-func handlerLogin(s *state, cmd command) error {
-	//check for args
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("No arguments provided, need username")
-	} else if len(cmd.args) > 1 {
-		return fmt.Errorf("Too many arguments provided, only need username")
+//prepareSpec wraps handlers that need a user and gives subcommands their
+//full name, recursively
+func prepareSpec(spec commandSpec) commandSpec {
+	if spec.userHandler != nil {
+		spec.loginRequired = true
+		spec.handler = middlewareLoggedIn(spec.userHandler)
+	}
+
+	subcommands := []commandSpec{}
+	for _, sub := range spec.subcommands {
+		sub.name = spec.name + " " + sub.name
+		sub = prepareSpec(sub)
+		subcommands = append(subcommands, sub)
+	}
+	if len(subcommands) > 0 {
+		spec.subcommands = subcommands
+		spec.loginRequired = true
+		for _, sub := range subcommands {
+			spec.loginRequired = spec.loginRequired && sub.loginRequired
+		}
+	}
+
+	return spec
+}
+
+//resolveSubcommand follows leading arguments that name subcommands and
+//returns the deepest spec reached with the remaining arguments
+func resolveSubcommand(spec commandSpec, args []string) (commandSpec, []string) {
+	for len(spec.subcommands) > 0 && len(args) > 0 {
+		sub, ok := spec.subcommand(args[0])
+		if !ok {
+			break
+		}
+		spec, args = sub, args[1:]
+	}
+	return spec, args
+}
+
+func (spec commandSpec) subcommand(name string) (commandSpec, bool) {
+	for _, sub := range spec.subcommands {
+		if sub.name == spec.name+" "+name {
+			return sub, true
+		}
+	}
+	return commandSpec{}, false
+}
+
+//subcommandNames returns the short names of the subcommands
+func (spec commandSpec) subcommandNames() []string {
+	names := []string{}
+	for _, sub := range spec.subcommands {
+		names = append(names, strings.TrimPrefix(sub.name, spec.name+" "))
+	}
+	return names
+}
+
+//flagSet builds a fresh flag set for one invocation of the command
+func (spec commandSpec) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(spec.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if spec.flags != nil {
+		spec.flags(fs)
+	}
+	return fs
+}
+
+func (spec commandSpec) usageLine() string {
+	line := programName + " " + spec.name
+	if len(spec.subcommands) > 0 {
+		return line + " <" + strings.Join(spec.subcommandNames(), "|") + ">"
+	}
+	if spec.flags != nil {
+		line += " [flags]"
+	}
+	if spec.usage != "" {
+		line += " " + spec.usage
+	}
+	return line
+}
+
+//parseInterspersed parses flags wherever they appear among the arguments,
+//stopping at "--", and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	return append(positional, rest...), nil
+}
+
+func (cmd command) flagString(name string) string {
+	if cmd.flags == nil {
+		return ""
+	}
+	f := cmd.flags.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value.String()
+}
+
+func (cmd command) flagBool(name string) bool {
+	v, err := strconv.ParseBool(cmd.flagString(name))
+	return err == nil && v
+}
+
+func (cmd command) flagInt(name string) int {
+	v, err := strconv.Atoi(cmd.flagString(name))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+//flagLimit reads a flag that limits how many rows are listed, refusing
+//values below 1
+func (cmd command) flagLimit(name string) (int, error) {
+	v := cmd.flagInt(name)
+	if v < 1 {
+		return 0, fmt.Errorf("--%v must be at least 1, got %v", name, cmd.flagString(name))
+	}
+	return v, nil
+}
+
+//flagSet reports whether the flag was given, to tell an empty or false value
+//apart from a missing one
+func (cmd command) flagSet(name string) bool {
+	if cmd.flags == nil {
+		return false
 	}
+	set := false
+	cmd.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func handlerLogin(s *state, cmd command) error {
+	ctx := context.Background()
 
 	//check if user exists in database
-	_, err := s.db.GetUser(context.Background(), cmd.args[0])
+	user, err := s.db.GetUser(ctx, cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("No users with specified name")
 	}
 
-	if err := s.cfg.SetUser(cmd.args[0]); err != nil {
+	//password-protected users have to prove who they are
+	if err := unlockUser(user); err != nil {
+		return err
+	}
+
+	if err := logIn(ctx, s, user); err != nil {
 		return err
 	}
 
@@ -71,61 +300,143 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 func handlerRegister(s *state, cmd command) error {
-	//check for args
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("No arguments provided, need name")
-	} else if len(cmd.args) > 1 {
-		return fmt.Errorf("Too many arguments provided, only need name")
+	ctx := context.Background()
+
+	password := ""
+	if cmd.flagBool("password") {
+		var err error
+		password, err = promptNewPassword()
+		if err != nil {
+			return err
+		}
 	}
-	
-	//check if user already exists
-	_, err := s.db.GetUser(context.Background(), cmd.args[0])
-	if err == nil {
-		fmt.Println("User already exists")
-		os.Exit(1)
-	}
-
-	//create User
-	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name: cmd.args[0],
-	})
+
+	user, err := registerUser(ctx, s, cmd.args[0], password)
 	if err != nil {
-		return fmt.Errorf("Error creating User: %v", err)
+		return err
 	}
 
 	//change current user
-	s.cfg.SetUser(cmd.args[0])
+	if err := logIn(ctx, s, user); err != nil {
+		return err
+	}
 
 	fmt.Println("User successfully created")
 	return nil
 }
 
-func handlerReset(s *state, cmd command) error {
-	err := s.db.DeleteRecords(context.Background())
+//getUser finds a user by name for the commands acting on other users
+func getUser(ctx context.Context, s *state, name string) (database.User, error) {
+	user, err := s.db.GetUser(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("User %v %w", name, errNotFound)
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("Error getting user: %v", err)
+	}
+	return user, nil
+}
+
+func handlerDeleteUser(s *state, cmd command) error {
+	ctx := context.Background()
+	user, err := getUser(ctx, s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if err := unlockUser(user); err != nil {
+		return err
+	}
+	if !cmd.flagBool("yes") && !confirm(fmt.Sprintf("Delete %v with the feeds they added and everything else they own?", user.Name)) {
+		return fmt.Errorf("Cancelled, %v was not deleted", user.Name)
+	}
+
+	//the session goes with the user, so check who's logged in first
+	current, err := currentUser(ctx, s)
+	loggedIn := err == nil && current.ID == user.ID
+
+	feeds, err := deleteUser(ctx, s, user, cmd.flagBool("force"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted user %v\n", user.Name)
+	if len(feeds) > 0 {
+		fmt.Printf("Deleted the feeds they added: %v\n", strings.Join(feeds, ", "))
+	}
+	if loggedIn {
+		if err := s.cfg.SetUser(""); err != nil {
+			return err
+		}
+		fmt.Println("Logged out")
+	}
+	return nil
+}
+
+func handlerRenameUser(s *state, cmd command) error {
+	ctx := context.Background()
+	user, err := getUser(ctx, s, cmd.args[0])
 	if err != nil {
-		fmt.Errorf("Error Resetting data: %v", err)
-		os.Exit(1)
+		return err
+	}
+	if err := unlockUser(user); err != nil {
+		return err
 	}
 
-	if err == nil {
-		fmt.Println("successfully reset data")
-		os.Exit(0)
+	renamed, signedOut, err := renameUser(ctx, s, user, cmd.args[1])
+	if err != nil {
+		return err
 	}
 
+	//passwordless logins are saved by name; sessions keep working
+	if s.cfg.SessionToken == "" && s.cfg.CurrentUserName == user.Name {
+		if err := s.cfg.SetUser(renamed.Name); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Renamed user %v to %v\n", user.Name, renamed.Name)
+	if signedOut {
+		fmt.Println("App passwords depend on the user name, so mobile apps are signed out; set a new one with app-password")
+	}
+	return nil
+}
+
+func handlerWhoami(s *state, cmd command) error {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(user.Name)
 	return nil
 }
 
 func handlerUsers(s *state, cmd command) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return fmt.Errorf("Error returning users: %v", err)
 	}
 
+	current := ""
+	if user, err := currentUser(context.Background(), s); err == nil {
+		current = user.Name
+	}
+
+	if format != outputText {
+		records := []userRecord{}
+		for _, user := range users {
+			records = append(records, newUserRecord(user, current))
+		}
+		return writeRecords(os.Stdout, format, records)
+	}
+
 	for _, user := range users {
-		if s.cfg.CurrentUserName == user.Name {
+		if current == user.Name {
 			fmt.Printf("* %v (current)\n", user.Name)
 		} else {
 			fmt.Printf("* %v\n", user.Name)
@@ -136,59 +447,51 @@ func handlerUsers(s *state, cmd command) error {
 }
 
 func handlerAgg(s *state, cmd command) error {
-	//check for args
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("No arguments provided, need time between requests (ex: 1s, 1m, 1h)")
-	} else if len(cmd.args) > 1 {
-		return fmt.Errorf("Too many arguments provided, only need time between requests (ex: 1s, 1m, 1h)")
-	}
-
 	timeBetweenRequests, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("Error parsing duration %v", err)
 	}
 
+	var keep retention
+	if cmd.flagBool("prune") {
+		keep, err = configRetention(s.cfg)
+		if err != nil {
+			return err
+		}
+		if !keep.isSet() {
+			return fmt.Errorf("--prune needs a retention in the config file, see the README")
+		}
+	}
+
 	fmt.Println("Collecting feeds every " + cmd.args[0])
+
+	//webhooks for the new posts go out in the background
+	go runWebhookWorker(context.Background(), s)
 	
 	ticker := time.NewTicker(timeBetweenRequests)
+	lastPruned := time.Time{}
 	for ; ; <-ticker.C {
+		if keep.isSet() && time.Since(lastPruned) >= pruneInterval {
+			old, excess, err := prunePosts(context.Background(), s, keep)
+			if err != nil {
+				log.Println(err)
+			} else if old+excess > 0 {
+				log.Printf("Pruned %v posts", old+excess)
+			}
+			lastPruned = time.Now()
+		}
+
 		err = scrapeFeeds(s)
 		if err != nil {
 			return err
 		}
 	}
-
-	return nil
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 2 {
-		return fmt.Errorf("Need to provide name and url")
-	}
-
-	//Create feed
-	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name: cmd.args[0],
-		Url: cmd.args[1],
-		UserID: user.ID,
-	})
+	feed, err := addFeed(context.Background(), s, user, cmd.args[0], cmd.args[1])
 	if err != nil {
-		return fmt.Errorf("Error creating feed: %v", err)
-	}
-
-	//Create Feed_Follow record
-	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID: user.ID,
-		FeedID: feed.ID,
-	})
-	if err != nil {
-		return fmt.Errorf("Error creating feed_follow record: %v", err)
+		return err
 	}
 
 	fmt.Println("New feed:")
@@ -202,51 +505,62 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 }
 
 func handlerFeeds(s *state, cmd command) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
 	feeds, err := s.db.GetFeeds(context.Background())
 	if err != nil {
 		return fmt.Errorf("Error getting feeds: %v", err)
 	}
 	
-	fmt.Println("Feeds:")
+	records := []feedRecord{}
 	for _, feed := range feeds {
 		name, err := s.db.GetUserNameFromID(context.Background(), feed.UserID)
 		if err != nil {
 			return fmt.Errorf("Error getting user name from id: %v", err)
 		}
+		records = append(records, newFeedRecord(feed, name))
+	}
 
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	fmt.Println("Feeds:")
+	for _, feed := range records {
 		fmt.Printf("  - Name: %v\n", feed.Name)
 		fmt.Printf("  - Url: %v\n", feed.Url)
-		fmt.Printf("  - Added By: %v\n\n", name)
+		fmt.Printf("  - Added By: %v\n\n", feed.AddedBy)
 	}
 
 	return nil
 }
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	//check for args
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("No arguments provided, need url")
-	} else if len(cmd.args) > 1 {
-		return fmt.Errorf("Too many arguments provided, only need url")
-	}
+	ctx := context.Background()
 
-	//Get FeedID
-	feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
-	if err != nil {
-		return fmt.Errorf("Error getting feed: %v", err)
-	}
-	feedID := feed.ID
+	//follow and file the feed together, so a missing folder follows nothing
+	var feed database.Feed
+	err := inTx(ctx, s, func(tx *state) error {
+		var err error
+		feed, _, err = followFeed(ctx, tx, user, cmd.args[0])
+		if err != nil {
+			return err
+		}
 
-	//Create Feed_Follow record
-	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID: user.ID,
-		FeedID: feedID,
+		if cmd.flagString("folder") == "" {
+			return nil
+		}
+		folder, err := getFolder(ctx, tx, user, cmd.flagString("folder"))
+		if err != nil {
+			return err
+		}
+		return setFolder(ctx, tx, user, feed.ID, folder)
 	})
 	if err != nil {
-		return fmt.Errorf("Error creating feed_follow record: %v", err)
+		return err
 	}
 
 	fmt.Printf("Feed name: %v\n", feed.Name)
@@ -255,48 +569,113 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 }
 
 func handlerFollowing(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
 	//get following feeds for current user
 	followingFeeds, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return fmt.Errorf("Error getting following feeds: %v", err)
 	}
 
+	if format != outputText {
+		records := []followRecord{}
+		for _, feedfollow := range followingFeeds {
+			records = append(records, newFollowRecord(feedfollow))
+		}
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	//feeds outside any folder first, then each folder by name; within them
+	//the order is by priority
+	folders := map[string][]string{}
+	names := []string{}
 	fmt.Println("Following:")
 	for _, feedfollow := range followingFeeds {
-		fmt.Println("  - " + feedfollow.FeedName)
+		name := followName(feedfollow)
+		if feedfollow.Muted {
+			name += " (muted)"
+		}
+		if !feedfollow.FolderName.Valid {
+			fmt.Println("  - " + name)
+			continue
+		}
+		folder := feedfollow.FolderName.String
+		if _, ok := folders[folder]; !ok {
+			names = append(names, folder)
+		}
+		folders[folder] = append(folders[folder], name)
+	}
+	sort.Strings(names)
+	for _, folder := range names {
+		fmt.Printf("  %v/\n", folder)
+		for _, feedName := range folders[folder] {
+			fmt.Println("    - " + feedName)
+		}
 	}
 
 	return nil
 }
 
 func handlerUnfollow(s *state, cmd command, user database.User) error {
-	//check for args
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("No arguments provided, need url")
-	} else if len(cmd.args) > 1 {
-		return fmt.Errorf("Too many arguments provided, only need url")
+	feed, err := unfollowFeed(context.Background(), s, user, cmd.args[0])
+	if err != nil {
+		return err
 	}
+	
+	fmt.Printf("Unfollowing %v\n", feed.Name)
+	return nil
+}
 
-	//Get FeedID
-	feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
+func handlerRemoveFeed(s *state, cmd command, user database.User) error {
+	feed, others, err := removeFeed(context.Background(), s, user, cmd.args[0], cmd.flagBool("force"))
 	if err != nil {
-		return fmt.Errorf("Error getting feed: %v", err)
+		return err
 	}
 
-	//delete record of feed_follow
-	err = s.db.Unfollow(context.Background(), database.UnfollowParams{
-		UserID: user.ID,
-		FeedID: feed.ID,
-	})
+	fmt.Printf("Deleted feed %v and its posts\n", feed.Name)
+	if len(others) > 0 {
+		fmt.Printf("Unfollowed it for %v\n", strings.Join(others, ", "))
+	}
+	return nil
+}
+
+func handlerEditFeed(s *state, cmd command, user database.User) error {
+	name := cmd.flagString("name")
+	url := cmd.flagString("url")
+	if name == "" && url == "" {
+		return fmt.Errorf("Nothing to change, pass --name and/or --url")
+	}
+
+	feed, err := editFeed(context.Background(), s, user, cmd.args[0], name, url)
 	if err != nil {
-		return fmt.Errorf("Error unfollowing: %v", err)
+		return err
 	}
-	
-	fmt.Printf("Unfollowing %v\n", feed.Name)
+
+	fmt.Println("Updated feed:")
+	fmt.Printf("  - Name: %v\n", feed.Name)
+	fmt.Printf("  - Url: %v\n", feed.Url)
+	return nil
+}
+
+func handlerTransferFeed(s *state, cmd command, user database.User) error {
+	feed, owner, err := transferFeed(context.Background(), s, user, cmd.args[0], cmd.args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Feed %v now belongs to %v\n", feed.Name, owner.Name)
 	return nil
 }
 
 func handlerBrowse(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
 	//check for limit arg
 	var limit int32
 	if len(cmd.args) == 0 {
@@ -311,22 +690,118 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 		}
 	}
 
-	//Print posts for user with provided limit arg
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+	//restrict to a single followed feed if asked, listed in the order set
+	//for it with follow-settings unless --sort says otherwise
+	feedID := uuid.NullUUID{}
+	sortOrder := sortNewest
+	if cmd.flagString("feed") != "" {
+		follow, err := findFollowedFeed(s, user, cmd.flagString("feed"))
+		if err != nil {
+			return err
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+		sortOrder = follow.SortOrder
+	}
+	if cmd.flagSet("sort") {
+		sortOrder = cmd.flagString("sort")
+		if err := checkSort(sortOrder); err != nil {
+			return err
+		}
+	}
+
+	//or to the feeds in one folder
+	folderID, err := folderFilter(context.Background(), s, user, cmd.flagString("folder"))
+	if err != nil {
+		return err
+	}
+
+	//Print posts for user with provided limit arg, after their rules
+	posts, err := listPosts(context.Background(), s, user, database.GetPostsForUserParams{
 		UserID: user.ID,
+		FeedID: feedID,
+		FolderID: folderID,
+		UnreadOnly: cmd.flagBool("unread"),
+		StarredOnly: cmd.flagBool("starred"),
+		OldestFirst: sortOrder == sortOldest,
 		Limit: limit,
 	})
 	if err != nil {
-		return fmt.Errorf("Error getting posts for user: %v", err)
+		return err
+	}
+
+	if format != outputText {
+		records := []postRecord{}
+		for _, post := range posts {
+			records = append(records, newPostRecord(post))
+		}
+		return writeRecords(os.Stdout, format, records)
 	}
 
-	fmt.Printf("Most recent %v posts:\n", limit)
+	if sortOrder == sortOldest {
+		fmt.Printf("Oldest %v posts:\n", limit)
+	} else {
+		fmt.Printf("Most recent %v posts:\n", limit)
+	}
 	for _, post := range posts {
 		fmt.Println()
 		fmt.Printf("  - Title: %v\n", post.Title)
 		fmt.Printf("  - Published at: %v\n", post.PublishedAt)
 		fmt.Printf("  - Link: %v\n", post.Url)
 		fmt.Printf("  - Description: %v\n", stripHTML(post.Description.String))
+		if len(post.Tags) > 0 {
+			fmt.Printf("  - Tags: %v\n", strings.Join(post.Tags, ", "))
+		}
+	}
+
+	return nil
+}
+
+//findFollowedFeed looks up one of the user's followed feeds by url or name
+func findFollowedFeed(s *state, user database.User, urlOrName string) (database.GetFeedFollowsForUserRow, error) {
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return database.GetFeedFollowsForUserRow{}, fmt.Errorf("Error getting following feeds: %v", err)
+	}
+
+	for _, follow := range follows {
+		if follow.FeedUrl == urlOrName || follow.FeedName == urlOrName || follow.Title.String == urlOrName {
+			return follow, nil
+		}
+	}
+
+	return database.GetFeedFollowsForUserRow{}, fmt.Errorf("Not following a feed with url or name %q", urlOrName)
+}
+
+func handlerSearch(s *state, cmd command, user database.User) error {
+	query := strings.Join(cmd.args, " ")
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+
+	//search posts from followed feeds, best matches first
+	results, err := s.db.SearchPostsForUser(context.Background(), database.SearchPostsForUserParams{
+		Query: query,
+		UserID: user.ID,
+		MaxRows: int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("Error searching posts: %v", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No posts matching %q\n", query)
+		return nil
+	}
+
+	fmt.Printf("Top %v results for %q:\n", len(results), query)
+	for _, result := range results {
+		fmt.Println()
+		fmt.Printf("  - Title: %v\n", result.Title)
+		fmt.Printf("  - Feed: %v\n", result.FeedName)
+		fmt.Printf("  - Published at: %v\n", result.PublishedAt)
+		fmt.Printf("  - Link: %v\n", result.Url)
+		fmt.Printf("  - Match: %v\n", strings.Join(strings.Fields(stripHTML(result.Snippet)), " "))
 	}
 
 	return nil
@@ -365,9 +840,9 @@ func recursivestripHTML(node *html.Node, text *string) {
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
 		//get user
-		u, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+		u, err := currentUser(context.Background(), s)
 		if err != nil {
-			return fmt.Errorf("Error getting user: %v", err)
+			return err
 		}
 		
 		//pass in user to logged in handlers
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.mustRun("register", "alice")
+	if e.s.cfg.CurrentUserName != "alice" {
+		t.Fatalf("register logged in as %q, want alice", e.s.cfg.CurrentUserName)
+	}
+	if _, err := e.run("register", "alice"); !errors.Is(err, errConflict) {
+		t.Fatalf("registering alice twice: got %v, want a conflict", err)
+	}
+
+	e.input("correct horse", "correct horse")
+	e.mustRun("register", "bob", "--password")
+	if e.s.cfg.SessionToken == "" || e.s.cfg.CurrentUserName != "" {
+		t.Fatalf("a user with a password should log in with a session, got %+v", e.s.cfg)
+	}
+	if out := e.mustRun("whoami"); strings.TrimSpace(out) != "bob" {
+		t.Fatalf("whoami printed %q, want bob", out)
+	}
+
+	e.mustRun("login", "alice")
+	if out := e.mustRun("whoami"); strings.TrimSpace(out) != "alice" {
+		t.Fatalf("whoami printed %q, want alice", out)
+	}
+
+	e.input("wrong")
+	if _, err := e.run("login", "bob"); err == nil {
+		t.Fatal("logging in as bob with the wrong password succeeded")
+	}
+	e.input("correct horse")
+	e.mustRun("login", "bob")
+	if out := e.mustRun("whoami"); strings.TrimSpace(out) != "bob" {
+		t.Fatalf("whoami printed %q, want bob", out)
+	}
+
+	if _, err := e.run("login", "carol"); err == nil {
+		t.Fatal("logging in as a user who doesn't exist succeeded")
+	}
+}
+
+//failingPasswordStore is a Store that can't set passwords
+type failingPasswordStore struct {
+	Store
+}
+
+func (f failingPasswordStore) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	return errors.New("disk full")
+}
+
+func (f failingPasswordStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	return f.Store.InTx(ctx, func(tx Store) error {
+		return fn(failingPasswordStore{tx})
+	})
+}
+
+func TestRegisterWithoutPasswordSaved(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.db = failingPasswordStore{e.s.db}
+
+	e.input("correct horse", "correct horse")
+	if _, err := e.run("register", "bob", "--password"); err == nil {
+		t.Fatal("register succeeded without saving the password")
+	}
+	//no account is left behind without its password
+	if _, err := e.s.db.GetUser(context.Background(), "bob"); err == nil {
+		t.Fatal("bob was created without a password")
+	}
+}
+
+func TestFollowAndUnfollow(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("register", "bob")
+
+	e.mustRun("follow", "http://example.com/feed")
+	if _, err := e.run("follow", "http://example.com/feed"); !errors.Is(err, errConflict) {
+		t.Fatalf("following twice: got %v, want a conflict", err)
+	}
+	if _, err := e.run("follow", "http://example.com/missing"); !errors.Is(err, errNotFound) {
+		t.Fatalf("following a missing feed: got %v, want not found", err)
+	}
+
+	e.fetch("http://example.com/feed", testItem("first post", time.Now()))
+	if posts := e.posts("bob"); len(posts) != 1 {
+		t.Fatalf("bob sees %v posts, want 1", len(posts))
+	}
+
+	e.mustRun("unfollow", "http://example.com/feed")
+	if posts := e.posts("bob"); len(posts) != 0 {
+		t.Fatalf("bob still sees %v posts after unfollowing", len(posts))
+	}
+	if posts := e.posts("alice"); len(posts) != 1 {
+		t.Fatalf("alice sees %v posts, want 1", len(posts))
+	}
+}
+
+func TestRemoveAndTransferFeed(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("register", "bob")
+	e.mustRun("follow", "http://example.com/feed")
+
+	if _, err := e.run("rmfeed", "blog"); err == nil || !strings.Contains(err.Error(), "belongs to alice") {
+		t.Fatalf("bob removing alice's feed: got %v", err)
+	}
+	if _, err := e.run("transfer-feed", "blog", "bob"); err == nil {
+		t.Fatal("bob transferring alice's feed succeeded")
+	}
+
+	e.mustRun("login", "alice")
+	if _, err := e.run("rmfeed", "blog"); err == nil || !strings.Contains(err.Error(), "still followed by bob") {
+		t.Fatalf("removing a feed bob follows: got %v", err)
+	}
+	if _, err := e.run("transfer-feed", "blog", "carol"); !errors.Is(err, errNotFound) {
+		t.Fatalf("transferring to a missing user: got %v, want not found", err)
+	}
+
+	e.mustRun("transfer-feed", "blog", "bob")
+	if feed := e.feed("http://example.com/feed"); feed.UserID != e.user("bob").ID {
+		t.Fatal("the feed still belongs to alice after the transfer")
+	}
+	if _, err := e.run("rmfeed", "blog"); err == nil {
+		t.Fatal("alice removing the feed she gave away succeeded")
+	}
+
+	e.mustRun("login", "bob")
+	e.fetch("http://example.com/feed", testItem("first post", time.Now()))
+	e.mustRun("rmfeed", "blog", "--force")
+	if _, err := e.s.db.GetFeed(context.Background(), "http://example.com/feed"); err == nil {
+		t.Fatal("the feed is still there after rmfeed --force")
+	}
+	if counts := e.counts(); counts.Follows != 0 || counts.Posts != 0 {
+		t.Fatalf("rmfeed left %v follows and %v posts", counts.Follows, counts.Posts)
+	}
+}
+
+func TestEditFeed(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.fetch("http://example.com/feed", testItem("first post", time.Now()))
+	e.mustRun("register", "bob")
+	e.mustRun("follow", "http://example.com/feed")
+
+	if _, err := e.run("editfeed", "blog", "--name", "mine"); err == nil || !strings.Contains(err.Error(), "belongs to alice") {
+		t.Fatalf("bob editing alice's feed: got %v", err)
+	}
+
+	e.mustRun("login", "alice")
+	if _, err := e.run("editfeed", "blog"); err == nil {
+		t.Fatal("editfeed without --name or --url succeeded")
+	}
+	if _, err := e.run("editfeed", "blog", "--url", "http://example.com/news"); !errors.Is(err, errConflict) {
+		t.Fatalf("moving blog to news' url: got %v, want a conflict", err)
+	}
+
+	//the feed keeps its follows and posts under the new name and url
+	e.mustRun("editfeed", "blog", "--name", "journal", "--url", "http://example.com/journal")
+	feed := e.feed("http://example.com/journal")
+	if feed.Name != "journal" {
+		t.Fatalf("the feed is named %v, want journal", feed.Name)
+	}
+	if posts := e.posts("bob"); len(posts) != 1 || posts[0].FeedName != "journal" {
+		t.Fatalf("bob sees %+v, want the first post of journal", posts)
+	}
+	e.mustRun("editfeed", "journal", "--name", "diary")
+	if feed := e.feed("http://example.com/journal"); feed.Name != "diary" {
+		t.Fatalf("renaming alone changed the feed to %+v", feed)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("register", "bob")
+	e.mustRun("follow", "http://example.com/feed")
+
+	e.input("n")
+	if _, err := e.run("deluser", "alice"); err == nil || !strings.Contains(err.Error(), "Cancelled") {
+		t.Fatalf("answering no to deluser: got %v", err)
+	}
+	if _, err := e.run("deluser", "alice", "--yes"); err == nil || !strings.Contains(err.Error(), "bob") {
+		t.Fatalf("deleting alice while bob follows her feed: got %v", err)
+	}
+
+	e.input("y")
+	e.mustRun("deluser", "alice", "--force")
+	if counts := e.counts(); counts.Users != 1 || counts.Feeds != 0 || counts.Follows != 0 {
+		t.Fatalf("after deleting alice: %+v, want only bob left", counts)
+	}
+
+	e.mustRun("deluser", "bob", "--yes")
+	if e.s.cfg.CurrentUserName != "" {
+		t.Fatalf("deleting the current user left %q logged in", e.s.cfg.CurrentUserName)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("register", "bob")
+
+	if _, err := e.run("renameuser", "alice", "bob"); !errors.Is(err, errConflict) {
+		t.Fatalf("renaming to a taken name: got %v, want a conflict", err)
+	}
+	if _, err := e.run("renameuser", "alice", "  "); err == nil {
+		t.Fatal("renaming to a blank name succeeded")
+	}
+
+	e.mustRun("renameuser", "bob", "robert")
+	if e.s.cfg.CurrentUserName != "robert" {
+		t.Fatalf("renaming the current user left the config on %q", e.s.cfg.CurrentUserName)
+	}
+	if out := e.mustRun("whoami"); strings.TrimSpace(out) != "robert" {
+		t.Fatalf("whoami printed %q, want robert", out)
+	}
+}
+
+func TestManagePasswordUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.input("correct horse", "correct horse")
+	e.mustRun("register", "bob", "--password")
+	e.input("app secret", "app secret")
+	e.mustRun("app-password")
+
+	e.input("wrong")
+	if _, err := e.run("renameuser", "bob", "robert"); err == nil {
+		t.Fatal("renaming bob with the wrong password succeeded")
+	}
+	e.input("correct horse")
+	if out := e.mustRun("renameuser", "bob", "robert"); !strings.Contains(out, "mobile apps are signed out") {
+		t.Fatalf("renaming a user with an app password printed %q", out)
+	}
+	//the session survives the rename
+	if out := e.mustRun("whoami"); strings.TrimSpace(out) != "robert" {
+		t.Fatalf("whoami printed %q, want robert", out)
+	}
+
+	e.input("wrong")
+	if _, err := e.run("deluser", "robert", "--yes"); err == nil {
+		t.Fatal("deleting robert with the wrong password succeeded")
+	}
+	e.input("correct horse")
+	e.mustRun("deluser", "robert", "--yes")
+	if _, err := e.run("whoami"); err == nil {
+		t.Fatal("whoami succeeded after the current user was deleted")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("Go generics", now),
+		testItem("Go modules", now.Add(-time.Minute)),
+		testItem("Rust traits", now.Add(-2*time.Minute)),
+	)
+
+	if out := e.mustRun("search", "go"); !strings.Contains(out, "Top 2 results") {
+		t.Fatalf("searching for go printed %q", out)
+	}
+	if out := e.mustRun("search", "go", "--limit", "1"); !strings.Contains(out, "Top 1 results") {
+		t.Fatalf("searching for go with --limit 1 printed %q", out)
+	}
+	for _, limit := range []string{"0", "-1", "many"} {
+		if _, err := e.run("search", "go", "--limit", limit); err == nil {
+			t.Errorf("search --limit %v succeeded", limit)
+		}
+	}
+}
+
+func TestSearchSyntax(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("register", "bob")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("Go generics in depth", now),
+		testItem("Generics in Rust", now.Add(-time.Minute)),
+		testItem("Go modules", now.Add(-2*time.Minute)),
+	)
+	e.fetch("http://example.com/news", testItem("Go generics land", now))
+
+	search := func(query string) []string {
+		t.Helper()
+		got := printedTitles(e.mustRun("search", query))
+		slices.Sort(got)
+		return got
+	}
+	//only the feeds alice follows are searched
+	e.mustRun("login", "alice")
+	if got, want := search("generics"), []string{"Generics in Rust", "Go generics in depth"}; !slices.Equal(got, want) {
+		t.Fatalf("searching generics found %v, want %v", got, want)
+	}
+	if got, want := search(`"go generics"`), []string{"Go generics in depth"}; !slices.Equal(got, want) {
+		t.Fatalf("searching a phrase found %v, want %v", got, want)
+	}
+	if got, want := search("go -modules"), []string{"Go generics in depth"}; !slices.Equal(got, want) {
+		t.Fatalf("searching with an excluded word found %v, want %v", got, want)
+	}
+	if out := e.mustRun("search", "haskell"); !strings.Contains(out, "No posts matching") {
+		t.Fatalf("searching for nothing printed %q", out)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+		feed string
+	}{
+		{[]string{"5"}, []string{"5"}, ""},
+		{[]string{"--feed", "blog", "5"}, []string{"5"}, "blog"},
+		{[]string{"5", "--feed=blog"}, []string{"5"}, "blog"},
+		{[]string{"5", "--", "--feed", "blog"}, []string{"5", "--feed", "blog"}, ""},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+		feed := fs.String("feed", "", "")
+		got, err := parseInterspersed(fs, tt.args)
+		if err != nil || !slices.Equal(got, tt.want) || *feed != tt.feed {
+			t.Errorf("parseInterspersed(%q) = %q, --feed %q, %v; want %q, --feed %q", tt.args, got, *feed, err, tt.want, tt.feed)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"registr", "bob"}, `did you mean "register"?`},
+		{[]string{"frobnicate"}, "help"},
+		{[]string{"rule"}, "Missing subcommand"},
+		{[]string{"rule", "ad", "x"}, `did you mean "add"?`},
+		{[]string{"addfeed", "blog"}, "Wrong number of arguments\nUsage: "},
+		{[]string{"browse", "--colour", "red"}, "not defined: -colour\nUsage: "},
+	}
+	for _, tt := range tests {
+		if _, err := e.run(tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want an error containing %q", strings.Join(tt.args, " "), err, tt.want)
+		}
+	}
+
+	//-h anywhere prints the command's help instead of running it
+	out := e.mustRun("browse", "5", "-h")
+	if !strings.Contains(out, "Usage: ") || !strings.Contains(out, "-unread") {
+		t.Errorf("browse -h printed %q", out)
+	}
+	if out := e.mustRun("help"); !strings.Contains(out, "register") || !strings.Contains(out, "browse") {
+		t.Errorf("help printed %q", out)
+	}
+	if out := e.mustRun("help", "rule", "add"); !strings.Contains(out, "-action") {
+		t.Errorf("help rule add printed %q", out)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//completeCommand is the hidden command shell completion scripts call back into
+const completeCommand = "__complete"
+
+var shells = []string{"bash", "zsh", "fish"}
+
+func (c *commands) handlerCompletion(s *state, cmd command) error {
+	name := programName
+	funcName := "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(name, "_")
+
+	switch cmd.args[0] {
+	case "bash":
+		fmt.Printf(bashCompletion, name, funcName, completeCommand)
+	case "zsh":
+		fmt.Printf(zshCompletion, name, funcName, completeCommand)
+	case "fish":
+		fmt.Printf(fishCompletion, name, funcName, completeCommand)
+	default:
+		return fmt.Errorf("Unsupported shell %q, expected one of %v", cmd.args[0], strings.Join(shells, "|"))
+	}
+
+	return nil
+}
+
+//handlerComplete prints one suggestion per line for the word being typed;
+//args are the words after the program name, the last one being incomplete
+func (c *commands) handlerComplete(s *state, cmd command) error {
+	for _, suggestion := range c.complete(s, cmd.args) {
+		fmt.Println(suggestion)
+	}
+	return nil
+}
+
+func (c *commands) complete(s *state, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	//completing the command name itself
+	if len(words) == 1 {
+		names := []string{}
+		for _, spec := range c.sorted() {
+			names = append(names, spec.name)
+		}
+		return filterPrefix(names, current)
+	}
+
+	spec, exists := c.commands[words[0]]
+	if !exists {
+		return nil
+	}
+
+	//descending into subcommands that are already typed out
+	for len(spec.subcommands) > 0 && len(words) > 2 {
+		sub, ok := spec.subcommand(words[1])
+		if !ok {
+			return nil
+		}
+		spec, words = sub, words[1:]
+	}
+	if len(spec.subcommands) > 0 {
+		return filterPrefix(spec.subcommandNames(), current)
+	}
+	fs := spec.flagSet()
+
+	//work out which positional argument is being typed, or which flag
+	//is waiting for its value
+	arg := 0
+	pendingFlag := ""
+	for _, word := range words[1 : len(words)-1] {
+		if pendingFlag != "" {
+			pendingFlag = ""
+			continue
+		}
+		if name, ok := flagName(word); ok {
+			f := fs.Lookup(name)
+			if f != nil && !isBoolFlag(f) && !strings.Contains(word, "=") {
+				pendingFlag = name
+			}
+			continue
+		}
+		arg++
+	}
+
+	switch {
+	case pendingFlag != "":
+		return filterPrefix(completeFlagValue(s, spec, pendingFlag), current)
+	case strings.HasPrefix(current, "-") && strings.Contains(current, "="):
+		name, _ := flagName(current)
+		prefix := current[:strings.Index(current, "=")+1]
+		values := []string{}
+		for _, value := range completeFlagValue(s, spec, name) {
+			values = append(values, prefix+value)
+		}
+		return filterPrefix(values, current)
+	case strings.HasPrefix(current, "-"):
+		names := []string{}
+		fs.VisitAll(func(f *flag.Flag) {
+			if len(f.Name) > 1 {
+				names = append(names, "--"+f.Name)
+			}
+		})
+		return filterPrefix(names, current)
+	case spec.complete != nil:
+		return filterPrefix(spec.complete(s, "", arg), current)
+	}
+
+	return nil
+}
+
+func completeFlagValue(s *state, spec commandSpec, name string) []string {
+	if name == "output" || name == "o" {
+		return outputFormats
+	}
+	if spec.complete == nil {
+		return nil
+	}
+	return spec.complete(s, name, -1)
+}
+
+//flagName returns the name of a -flag, --flag or --flag=value word
+func flagName(word string) (string, bool) {
+	if len(word) < 2 || word[0] != '-' || word == "--" {
+		return "", false
+	}
+	name := strings.TrimLeft(word, "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		name = name[:i]
+	}
+	return name, true
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	matches := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+//completeUserNames suggests registered user names
+func completeUserNames(s *state) []string {
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names
+}
+
+//completeFeedURLs suggests the url of every feed
+func completeFeedURLs(s *state) []string {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	urls := []string{}
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+	return urls
+}
+
+//completeOwnedFeeds suggests the urls of the feeds the current user added,
+//or was handed
+func completeOwnedFeeds(s *state) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	urls := []string{}
+	for _, feed := range feeds {
+		if feed.UserID == user.ID {
+			urls = append(urls, feed.Url)
+		}
+	}
+	return urls
+}
+
+//completeFollowedFeeds suggests the urls, and optionally names and the
+//user's titles, of the feeds the current user follows
+func completeFollowedFeeds(s *state, withNames bool) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	suggestions := []string{}
+	for _, follow := range follows {
+		suggestions = append(suggestions, follow.FeedUrl)
+		if withNames {
+			suggestions = append(suggestions, follow.FeedName)
+			if follow.Title.Valid {
+				suggestions = append(suggestions, follow.Title.String)
+			}
+		}
+	}
+	return suggestions
+}
+
+//bashCompletion is formatted with the program name, a function name safe
+//for the shell and the hidden completion command
+const bashCompletion = `# bash completion for %[1]s
+# load with: source <(%[1]s completion bash)
+%[2]s() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        _get_comp_words_by_ref -n : cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($("${words[0]}" %[3]s "${words[@]:1:cword}" 2>/dev/null))
+
+    if declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F %[2]s %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+# load with: source <(%[1]s completion zsh)
+%[2]s() {
+    local -a suggestions
+    suggestions=(${(f)"$("${words[1]}" %[3]s "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    compadd -Q -- "${suggestions[@]}"
+}
+compdef %[2]s %[1]s
+`
+
+const fishCompletion = `# fish completion for %[1]s
+# load with: %[1]s completion fish | source
+function %[2]s
+    set -l tokens (commandline -opc) (commandline -ct)
+    $tokens[1] %[3]s $tokens[2..-1] 2>/dev/null
+end
+complete -c %[1]s -f -a '(%[2]s)'
+`
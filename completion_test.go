@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("register", "bob")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"reg"}, []string{"register"}},
+		{[]string{"login", ""}, []string{"alice", "bob"}},
+		{[]string{"login", "a"}, []string{"alice"}},
+		{[]string{"rule", "r"}, []string{"rm"}},
+		{[]string{"browse", "--uns"}, nil},
+		{[]string{"browse", "--un"}, []string{"--unread"}},
+		{[]string{"browse", "--output", "j"}, []string{"json"}},
+		{[]string{"browse", "--output=nd"}, []string{"--output=ndjson"}},
+		{[]string{"browse", "--feed", ""}, []string{"blog", "http://example.com/feed"}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		{[]string{"frobnicate", ""}, nil},
+	}
+	for _, tt := range tests {
+		got := e.cmds.complete(e.s, tt.words)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("completing %q: got %q, want %q", tt.words, got, tt.want)
+		}
+	}
+
+	//the hidden command the scripts call prints one suggestion a line
+	if out := e.mustRun(completeCommand, "login", "b"); out != "bob\n" {
+		t.Errorf("%v login b printed %q", completeCommand, out)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	e := newTestEnv(t)
+	for _, shell := range shells {
+		out := e.mustRun("completion", shell)
+		if !strings.Contains(out, completeCommand) || strings.Contains(out, "%!") {
+			t.Errorf("the %v script doesn't call %v, or is badly formatted: %q", shell, completeCommand, out)
+		}
+	}
+	if _, err := e.run("completion", "powershell"); err == nil {
+		t.Error("a powershell script was printed")
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/config"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+const (
+	//defaultDigestWindow is how far back a user's first digest goes
+	defaultDigestWindow = 24 * time.Hour
+	//dryRunFrom is the sender used by --dry-run without smtp settings
+	dryRunFrom = "gator@localhost"
+	smtpTimeout = 30 * time.Second
+)
+
+var digestFuncs = map[string]any{
+	"date": func(t time.Time) string {
+		return t.Format("Jan 2, 2006 15:04")
+	},
+}
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(digestFuncs).ParseFS(webFS, "web/email/digest.html"))
+var digestText = texttemplate.Must(texttemplate.New("digest.txt").Funcs(digestFuncs).ParseFS(webFS, "web/email/digest.txt"))
+
+type digest struct {
+	Subject string
+	UserName string
+	Since time.Time
+	Count int
+	//More is set when there were more unread posts than the digest lists;
+	//those go in the next digest
+	More bool
+	Feeds []digestFeed
+	//Until is when the newest listed post was saved, the next digest lists
+	//the posts saved after it
+	Until time.Time
+}
+
+type digestFeed struct {
+	Name string
+	URL string
+	Posts []digestPost
+}
+
+type digestPost struct {
+	Title string
+	URL string
+	Summary string
+	PublishedAt time.Time
+}
+
+//buildDigest collects a user's unread posts saved since the given time,
+//grouped by feed. Past the limit, it lists the oldest posts and leaves the
+//rest for the next digest.
+func buildDigest(ctx context.Context, s *state, userID uuid.UUID, userName string, since time.Time, limit int) (digest, error) {
+	items, err := s.db.GetItemsForUser(ctx, database.GetItemsForUserParams{
+		UserID: userID,
+		UnreadOnly: true,
+		ForDigest: true,
+		NewerThan: sql.NullTime{Time: since, Valid: true},
+		OldestFirst: true,
+		Limit: int32(limit + 1),
+	})
+	if err != nil {
+		return digest{}, fmt.Errorf("Error getting posts for user: %v", err)
+	}
+
+	d := digest{
+		UserName: userName,
+		Since: since,
+	}
+	if len(items) > limit {
+		items = items[:limit]
+		d.More = true
+	}
+	for _, item := range items {
+		//the window includes its start, so start just after the post
+		if until := item.CreatedAt.Add(time.Microsecond); until.After(d.Until) {
+			d.Until = until
+		}
+	}
+	slices.Reverse(items)
+
+	//feeds are listed in the order of their newest post
+	byFeed := map[uuid.UUID]int{}
+	for _, item := range items {
+		i, ok := byFeed[item.FeedID]
+		if !ok {
+			i = len(d.Feeds)
+			byFeed[item.FeedID] = i
+			d.Feeds = append(d.Feeds, digestFeed{Name: item.FeedName, URL: item.FeedUrl})
+		}
+		d.Feeds[i].Posts = append(d.Feeds[i].Posts, digestPost{
+			Title: item.Title,
+			URL: item.Url,
+			Summary: summarize(stripHTML(item.Description.String), 280),
+			PublishedAt: item.PublishedAt,
+		})
+	}
+
+	d.Count = len(items)
+	noun := "posts"
+	if d.Count == 1 {
+		noun = "post"
+	}
+	d.Subject = fmt.Sprintf("Your gator digest: %v new %v", d.Count, noun)
+	return d, nil
+}
+
+//renderDigest writes the digest as a multipart email with plain text and
+//HTML versions
+func renderDigest(d digest, from, to string) ([]byte, error) {
+	text := &bytes.Buffer{}
+	if err := digestText.Execute(text, d); err != nil {
+		return nil, fmt.Errorf("Error rendering digest: %v", err)
+	}
+	html := &bytes.Buffer{}
+	if err := digestHTML.Execute(html, d); err != nil {
+		return nil, fmt.Errorf("Error rendering digest: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, part := range []struct {
+		contentType string
+		content []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		domain = addr.Address[strings.LastIndex(addr.Address, "@")+1:]
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	msg := &bytes.Buffer{}
+	headers := [][2]string{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", d.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(id) + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + mw.Boundary() + `"`},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(msg, "%v: %v\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+//sendMail delivers a message through the configured SMTP server, upgrading
+//to TLS when the server offers it unless tls is "none"
+func sendMail(cfg *config.SMTP, to string, msg []byte) error {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("Invalid smtp from address %q: %v", cfg.From, err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("Invalid email address %q: %v", to, err)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if cfg.TLS == "tls" {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+
+	var conn net.Conn
+	switch cfg.TLS {
+	case "tls":
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpTimeout}, "tcp", addr, tlsConfig)
+	case "", "starttls", "none":
+		conn, err = net.DialTimeout("tcp", addr, smtpTimeout)
+	default:
+		return fmt.Errorf("Unknown smtp tls setting %q, use starttls, tls or none", cfg.TLS)
+	}
+	if err != nil {
+		return fmt.Errorf("Error connecting to %v: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("Error talking to %v: %v", addr, err)
+	}
+	defer c.Close()
+
+	if cfg.TLS != "tls" && cfg.TLS != "none" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("Error starting tls: %v", err)
+			}
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("Error logging in to %v: %v", addr, err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("Error sending mail: %v", err)
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return fmt.Errorf("Error sending mail: %v", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("Error sending mail: %v", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("Error sending mail: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Error sending mail: %v", err)
+	}
+	return c.Quit()
+}
+
+//handlerDigest emails every subscribed user their unread posts since their
+//last digest; meant to be run from cron
+func handlerDigest(s *state, cmd command) error {
+	ctx := context.Background()
+	dryRun := cmd.flagBool("dry-run")
+
+	from := dryRunFrom
+	if s.cfg.SMTP != nil && s.cfg.SMTP.From != "" {
+		from = s.cfg.SMTP.From
+	}
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+	if !dryRun && (s.cfg.SMTP == nil || s.cfg.SMTP.Host == "" || s.cfg.SMTP.From == "") {
+		return fmt.Errorf("No smtp host and from address in the config file, see the README or use --dry-run")
+	}
+
+	subs, err := s.db.GetDigestSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("Error getting digest subscriptions: %v", err)
+	}
+
+	only := cmd.flagString("user")
+	failed := 0
+	found := false
+	for _, sub := range subs {
+		if only != "" && sub.UserName != only {
+			continue
+		}
+		found = true
+
+		since := time.Now().Add(-defaultDigestWindow)
+		if sub.LastSentAt.Valid {
+			since = sub.LastSentAt.Time
+		}
+		d, err := buildDigest(ctx, s, sub.UserID, sub.UserName, since, limit)
+		if err != nil {
+			return err
+		}
+		if d.Count == 0 {
+			fmt.Printf("No new posts for %v\n", sub.UserName)
+			continue
+		}
+
+		msg, err := renderDigest(d, from, sub.Email)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			path := filepath.Join(cmd.flagString("out"), digestFileName(sub.UserName, sub.UserID))
+			if err := os.WriteFile(path, msg, 0o644); err != nil {
+				return fmt.Errorf("Error writing %v: %v", path, err)
+			}
+			fmt.Printf("Wrote %v's digest of %v posts to %v\n", sub.UserName, d.Count, path)
+			continue
+		}
+
+		//one bad address shouldn't keep everyone else's digest back
+		if err := sendMail(s.cfg.SMTP, sub.Email, msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error sending %v's digest: %v\n", sub.UserName, err)
+			failed++
+			continue
+		}
+		err = s.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+			UserID: sub.UserID,
+			LastSentAt: sql.NullTime{Time: d.Until, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("Error marking digest sent: %v", err)
+		}
+		fmt.Printf("Sent %v's digest of %v posts to %v\n", sub.UserName, d.Count, sub.Email)
+	}
+
+	if only != "" && !found {
+		return fmt.Errorf("%v isn't subscribed to digests, see digest-email", only)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v digests couldn't be sent", failed)
+	}
+	return nil
+}
+
+//digestFileName names a user's --dry-run digest after them. Characters that
+//aren't safe in a file name are replaced, and the id added so that the name
+//still points at one user and never leaves --out.
+func digestFileName(userName string, userID uuid.UUID) string {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, userName)
+	if safe != userName {
+		safe += "-" + shortID(userID)
+	}
+	return "digest-" + safe + ".eml"
+}
+
+//handlerDigestEmail shows, sets or removes the address the current user's
+//digests go to
+func handlerDigestEmail(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+
+	if cmd.flagBool("remove") {
+		n, err := s.db.DeleteDigestSubscription(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error removing digest email: %v", err)
+		}
+		if n == 0 {
+			fmt.Println("You weren't getting digests")
+			return nil
+		}
+		fmt.Println("You won't get digests anymore")
+		return nil
+	}
+
+	if len(cmd.args) == 0 {
+		sub, err := s.db.GetDigestSubscription(ctx, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("No digest email set, set one with: digest-email <address>")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error getting digest email: %v", err)
+		}
+		fmt.Printf("Digests go to %v\n", sub.Email)
+		if sub.LastSentAt.Valid {
+			fmt.Printf("Last sent at: %v\n", sub.LastSentAt.Time)
+		}
+		return nil
+	}
+
+	addr, err := mail.ParseAddress(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid email address %q: %v", cmd.args[0], err)
+	}
+	sub, err := s.db.SetDigestEmail(ctx, database.SetDigestEmailParams{
+		UserID: user.ID,
+		CreatedAt: time.Now(),
+		Email: addr.Address,
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting digest email: %v", err)
+	}
+
+	fmt.Printf("Digests will go to %v\n", sub.Email)
+	return nil
+}
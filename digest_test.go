@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDigestFileName(t *testing.T) {
+	id := uuid.MustParse("0123abcd-0000-0000-0000-000000000000")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"alice", "digest-alice.eml"},
+		{"jean-luc_2", "digest-jean-luc_2.eml"},
+		{"../../etc/cron.d/x", "digest-______etc_cron_d_x-0123abcd.eml"},
+		{"a/b", "digest-a_b-0123abcd.eml"},
+		{"..", "digest-__-0123abcd.eml"},
+	}
+	for _, tt := range tests {
+		if got := digestFileName(tt.name, id); got != tt.want {
+			t.Errorf("digestFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDigestDryRunWritesInOut(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "../evil")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("digest-email", "evil@example.com")
+	e.fetch("http://example.com/feed", testItem("new post", time.Now()))
+
+	out := filepath.Join(t.TempDir(), "digests", "out")
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e.mustRun("digest", "--dry-run", "--out", out)
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "digest-") {
+		t.Fatalf("digest wrote %v in --out, want the digest", entries)
+	}
+	//nothing else was written under the temporary directory
+	if entries, _ := os.ReadDir(filepath.Dir(out)); len(entries) != 1 {
+		t.Fatalf("digest wrote %v next to --out", entries)
+	}
+}
+
+func TestDigestLimitLeavesTheRest(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	for _, title := range []string{"first", "second", "third"} {
+		e.fetch("http://example.com/feed", testItem(title, time.Now()))
+	}
+	user := e.user("alice")
+	since := time.Now().Add(-time.Hour)
+
+	if _, err := e.run("digest", "--dry-run", "--limit", "0"); err == nil {
+		t.Fatal("digest --limit 0 succeeded")
+	}
+
+	titles := func(d digest) []string {
+		titles := []string{}
+		for _, feed := range d.Feeds {
+			for _, post := range feed.Posts {
+				titles = append(titles, post.Title)
+			}
+		}
+		return titles
+	}
+
+	d, err := buildDigest(context.Background(), e.s, user.ID, user.Name, since, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := titles(d), []string{"second", "first"}; !d.More || !slices.Equal(got, want) {
+		t.Fatalf("the first digest lists %v (more: %v), want %v and more", got, d.More, want)
+	}
+
+	//the next digest starts where this one stopped
+	d, err = buildDigest(context.Background(), e.s, user.ID, user.Name, d.Until, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := titles(d), []string{"third"}; d.More || !slices.Equal(got, want) {
+		t.Fatalf("the next digest lists %v (more: %v), want %v", got, d.More, want)
+	}
+}
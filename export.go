@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//timeline export formats
+const (
+	exportRSS = "rss"
+	exportAtom = "atom"
+)
+
+//defaultExportLimit is the number of posts in an exported timeline
+const defaultExportLimit = 50
+
+//timeline is a user's aggregated stream, ready to be rendered as a feed
+type timeline struct {
+	user database.User
+	title string
+	selfURL string
+	posts []database.GetPostsForUserRow
+}
+
+//timelineFilter narrows a timeline, like the browse flags
+type timelineFilter struct {
+	feed string //followed feed url or name, all feeds if empty
+	limit int
+}
+
+func loadTimeline(ctx context.Context, s *state, user database.User, filter timelineFilter) (timeline, error) {
+	t := timeline{
+		user: user,
+		title: "gator - " + user.Name,
+	}
+
+	feedID := uuid.NullUUID{}
+	if filter.feed != "" {
+		follow, err := findFollowedFeed(s, user, filter.feed)
+		if err != nil {
+			return timeline{}, fmt.Errorf("%w: %v", errNotFound, err)
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+		t.title += " - " + follow.FeedName
+	}
+
+	limit := filter.limit
+	if limit <= 0 {
+		limit = defaultExportLimit
+	}
+
+	posts, err := listPosts(ctx, s, user, database.GetPostsForUserParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return timeline{}, err
+	}
+	t.posts = posts
+
+	return t, nil
+}
+
+//updated is the time of the newest post, or now for an empty timeline
+func (t timeline) updated() time.Time {
+	updated := time.Time{}
+	for _, post := range t.posts {
+		if post.PublishedAt.After(updated) {
+			updated = post.PublishedAt
+		}
+	}
+	if updated.IsZero() {
+		return time.Now()
+	}
+	return updated
+}
+
+//postGUID identifies a post across exports; post ids never change
+func postGUID(id uuid.UUID) string {
+	return "urn:uuid:" + id.String()
+}
+
+//postHTML is the richest HTML we have for a post
+func postHTML(post database.GetPostsForUserRow) string {
+	if post.Content.Valid && post.Content.String != "" {
+		return post.Content.String
+	}
+	return post.Description.String
+}
+
+type rssExport struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string `xml:"version,attr"`
+	AtomNS string `xml:"xmlns:atom,attr"`
+	Channel rssExportChannel `xml:"channel"`
+}
+
+type rssExportChannel struct {
+	Title string `xml:"title"`
+	Link string `xml:"link"`
+	Description string `xml:"description"`
+	LastBuildDate string `xml:"lastBuildDate"`
+	Generator string `xml:"generator"`
+	AtomLink *atomLink `xml:"atom:link,omitempty"`
+	Items []rssExportItem `xml:"item"`
+}
+
+type rssExportItem struct {
+	Title string `xml:"title"`
+	Link string `xml:"link"`
+	GUID rssGUID `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Description string `xml:"description"`
+	Source rssSource `xml:"source"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool `xml:"isPermaLink,attr"`
+	Value string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+func writeRSS(w io.Writer, t timeline) error {
+	channel := rssExportChannel{
+		Title: t.title,
+		Link: t.selfURL,
+		Description: "Posts from the feeds " + t.user.Name + " follows",
+		LastBuildDate: t.updated().Format(time.RFC1123Z),
+		Generator: "gator",
+		Items: []rssExportItem{},
+	}
+	if t.selfURL != "" {
+		channel.AtomLink = &atomLink{Href: t.selfURL, Rel: "self", Type: "application/rss+xml"}
+	}
+
+	for _, post := range t.posts {
+		channel.Items = append(channel.Items, rssExportItem{
+			Title: post.Title,
+			Link: post.Url,
+			GUID: rssGUID{Value: postGUID(post.ID)},
+			PubDate: post.PublishedAt.Format(time.RFC1123Z),
+			Description: postHTML(post),
+			Source: rssSource{URL: post.FeedUrl, Name: post.FeedName},
+		})
+	}
+
+	return writeXML(w, rssExport{
+		Version: "2.0",
+		AtomNS: "http://www.w3.org/2005/Atom",
+		Channel: channel,
+	})
+}
+
+type atomExport struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID string `xml:"id"`
+	Title string `xml:"title"`
+	Updated string `xml:"updated"`
+	Author atomPerson `xml:"author"`
+	Generator string `xml:"generator"`
+	Links []atomLink `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID string `xml:"id"`
+	Title string `xml:"title"`
+	Link atomLink `xml:"link"`
+	Published string `xml:"published"`
+	Updated string `xml:"updated"`
+	Summary atomText `xml:"summary"`
+	Source atomSource `xml:"source"`
+}
+
+type atomSource struct {
+	ID string `xml:"id"`
+	Title string `xml:"title"`
+	Link atomLink `xml:"link"`
+}
+
+func writeAtom(w io.Writer, t timeline) error {
+	feed := atomExport{
+		ID: postGUID(t.user.ID),
+		Title: t.title,
+		Updated: t.updated().UTC().Format(time.RFC3339),
+		Author: atomPerson{Name: t.user.Name},
+		Generator: "gator",
+		Entries: []atomEntry{},
+	}
+	if t.selfURL != "" {
+		feed.Links = append(feed.Links, atomLink{Href: t.selfURL, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, post := range t.posts {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID: postGUID(post.ID),
+			Title: post.Title,
+			Link: atomLink{Href: post.Url, Rel: "alternate"},
+			Published: post.PublishedAt.UTC().Format(time.RFC3339),
+			Updated: post.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary: atomText{Type: "html", Body: postHTML(post)},
+			Source: atomSource{
+				ID: postGUID(post.FeedID),
+				Title: post.FeedName,
+				Link: atomLink{Href: post.FeedUrl, Rel: "self"},
+			},
+		})
+	}
+
+	return writeXML(w, feed)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeTimeline(w io.Writer, format string, t timeline) error {
+	switch format {
+	case exportRSS:
+		return writeRSS(w, t)
+	case exportAtom:
+		return writeAtom(w, t)
+	}
+	return fmt.Errorf("Unknown feed format %q, expected rss or atom", format)
+}
+
+func handlerExportFeed(s *state, cmd command, user database.User) error {
+	format := cmd.flagString("format")
+	if format != exportRSS && format != exportAtom {
+		return fmt.Errorf("Unknown feed format %q, expected rss or atom", format)
+	}
+
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+
+	t, err := loadTimeline(context.Background(), s, user, timelineFilter{
+		feed: cmd.flagString("feed"),
+		limit: limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if path := cmd.flagString("out"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Error creating %v: %v", path, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return writeTimeline(w, format, t)
+}
+
+//handlerFeedToken creates (or replaces) the secret token that protects the
+//user's timeline feeds served by "serve"
+func handlerFeedToken(s *state, cmd command, user database.User) error {
+	token, hash, err := generateToken(feedTokenPrefix)
+	if err != nil {
+		return fmt.Errorf("Error generating feed token: %v", err)
+	}
+
+	err = s.db.SetFeedToken(context.Background(), database.SetFeedTokenParams{
+		UserID: user.ID,
+		CreatedAt: time.Now(),
+		TokenHash: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("Error saving feed token: %v", err)
+	}
+
+	base := strings.TrimSuffix(cmd.flagString("base-url"), "/")
+	fmt.Println("New feed token (any previous token no longer works):")
+	fmt.Printf("  RSS:  %v/feeds/%v/rss.xml\n", base, token)
+	fmt.Printf("  Atom: %v/feeds/%v/atom.xml\n", base, token)
+	fmt.Println("\nAdd ?feed=<url or name> for a single followed feed and ?limit=<n> for more posts.")
+	return nil
+}
+
+//handlerTimelineFeed serves /feeds/{token}/{rss.xml|atom.xml}
+func (cfg *apiConfig) handlerTimelineFeed(w http.ResponseWriter, r *http.Request) {
+	format := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	contentType := map[string]string{
+		exportRSS: "application/rss+xml; charset=utf-8",
+		exportAtom: "application/atom+xml; charset=utf-8",
+	}[format]
+	if contentType == "" {
+		respondWithError(w, http.StatusNotFound, "Feeds are served as rss.xml or atom.xml")
+		return
+	}
+
+	row, err := cfg.s.db.GetUserByFeedToken(r.Context(), hashToken(r.PathValue("token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unknown feed token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed token: "+err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	t, err := loadTimeline(r.Context(), cfg.s, row.User, timelineFilter{
+		feed: r.URL.Query().Get("feed"),
+		limit: min(limit, maxPostsLimit),
+	})
+	if err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+	t.selfURL = requestURL(r)
+
+	w.Header().Set("Content-Type", contentType)
+	if err := writeTimeline(w, format, t); err != nil {
+		log.Printf("Error writing %v feed: %v", format, err)
+	}
+}
+
+//requestURL rebuilds the absolute URL of a request, honouring a proxy's
+//X-Forwarded-Proto
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
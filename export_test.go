@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportFeed(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("first", now), testItem("second", now.Add(-time.Minute)))
+	e.fetch("http://example.com/news", testItem("headline", now.Add(-2*time.Minute)))
+
+	if _, err := e.run("export-feed", "--format", "json"); err == nil {
+		t.Fatal("exporting as json succeeded")
+	}
+	if _, err := e.run("export-feed", "--limit", "0"); err == nil {
+		t.Fatal("export-feed --limit 0 succeeded")
+	}
+	if _, err := e.run("export-feed", "--feed", "missing"); err == nil {
+		t.Fatal("exporting a feed alice doesn't follow succeeded")
+	}
+
+	var rss rssExport
+	if err := xml.Unmarshal([]byte(e.mustRun("export-feed", "--limit", "2")), &rss); err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, item := range rss.Channel.Items {
+		titles = append(titles, item.Title)
+	}
+	if want := []string{"first", "second"}; !slices.Equal(titles, want) {
+		t.Fatalf("the rss feed has %v, want %v", titles, want)
+	}
+	if item := rss.Channel.Items[0]; item.Source.Name != "blog" || !strings.HasPrefix(item.GUID.Value, "urn:uuid:") {
+		t.Errorf("the first item is %+v", item)
+	}
+
+	path := filepath.Join(t.TempDir(), "news.xml")
+	e.mustRun("export-feed", "--format", "atom", "--feed", "news", "--out", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var atom atomExport
+	if err := xml.Unmarshal(data, &atom); err != nil {
+		t.Fatal(err)
+	}
+	if atom.Title != "gator - alice - news" || len(atom.Entries) != 1 || atom.Entries[0].Title != "headline" {
+		t.Fatalf("the atom feed of news is %+v", atom)
+	}
+}
+
+func TestTimelineFeedTokens(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.fetch("http://example.com/feed", testItem("first", time.Now()))
+	c := e.apiClient()
+
+	feedURL := func() string {
+		for _, line := range strings.Split(e.mustRun("feed-token", "--base-url", c.url), "\n") {
+			if url, ok := strings.CutPrefix(strings.TrimSpace(line), "Atom: "); ok {
+				return strings.TrimSpace(url)
+			}
+		}
+		t.Fatal("feed-token printed no atom url")
+		return ""
+	}
+	get := func(url string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	old := feedURL()
+	code, body := get(old)
+	if code != http.StatusOK || !strings.Contains(body, "<title>first</title>") {
+		t.Fatalf("getting the atom feed: got %v\n%v", code, body)
+	}
+	if !strings.Contains(body, `rel="self"`) {
+		t.Error("the served feed doesn't link to itself")
+	}
+	if code, _ := get(strings.Replace(old, "atom.xml", "feed.json", 1)); code != http.StatusNotFound {
+		t.Errorf("getting feed.json: got %v, want 404", code)
+	}
+
+	//rotating the token retires the old url
+	current := feedURL()
+	if code, _ := get(old); code != http.StatusNotFound {
+		t.Errorf("getting the feed with a rotated token: got %v, want 404", code)
+	}
+	if code, _ := get(strings.Replace(current, "atom.xml", "rss.xml", 1)); code != http.StatusOK {
+		t.Errorf("getting the rss feed: got %v, want 200", code)
+	}
+}
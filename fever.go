@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//Fever API (version 3), as spoken by Reeder and other mobile readers
+const (
+	feverAPIVersion = 3
+	feverMaxItems = 50
+)
+
+//feverGroupID numbers a folder as a Fever group. Groups have numbers where
+//folders have uuids, so the number is taken from the uuid; it stays the
+//same across renames and fits in a javascript number.
+func feverGroupID(folderID uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(folderID[:8]) >> 16)
+}
+
+type feverGroup struct {
+	ID int64 `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64 `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID int64 `json:"id"`
+	FaviconID int64 `json:"favicon_id"`
+	Title string `json:"title"`
+	URL string `json:"url"`
+	SiteURL string `json:"site_url"`
+	IsSpark int `json:"is_spark"`
+	LastUpdatedOnTime int64 `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID int64 `json:"id"`
+	FeedID int64 `json:"feed_id"`
+	Title string `json:"title"`
+	Author string `json:"author"`
+	HTML string `json:"html"`
+	URL string `json:"url"`
+	IsSaved int `json:"is_saved"`
+	IsRead int `json:"is_read"`
+	CreatedOnTime int64 `json:"created_on_time"`
+}
+
+//handlerFever serves the whole Fever API: the request's parameters (query
+//string or form) say what to change and which lists to return
+func (cfg *apiConfig) handlerFever(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"api_version": feverAPIVersion,
+		"auth": 0,
+	}
+
+	if err := r.ParseForm(); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	//clients expect auth=0 rather than an error status for a bad key
+	user, err := userByAppKey(r.Context(), cfg.s, r.FormValue("api_key"))
+	if err != nil {
+		if !errors.Is(err, errNotFound) {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp["auth"] = 1
+
+	if err := cfg.feverRespond(r, user, resp); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) feverRespond(r *http.Request, user database.User, resp map[string]interface{}) error {
+	ctx := r.Context()
+	has := func(key string) bool {
+		_, ok := r.Form[key]
+		return ok
+	}
+
+	feeds, err := cfg.s.db.GetFeedsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting feeds for user: %v", err)
+	}
+
+	if has("mark") {
+		if err := cfg.feverMark(ctx, r, user, feeds); err != nil {
+			return err
+		}
+	}
+
+	lastRefreshed := int64(0)
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid {
+			lastRefreshed = max(lastRefreshed, feed.LastFetchedAt.Time.Unix())
+		}
+	}
+	resp["last_refreshed_on_time"] = lastRefreshed
+
+	//groups are the user's folders, feeds outside one are in none
+	if has("groups") || has("feeds") {
+		folders, err := cfg.s.db.GetFoldersForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error getting folders: %v", err)
+		}
+		feedFolder, err := feedFolders(ctx, cfg.s, user)
+		if err != nil {
+			return err
+		}
+
+		groups := []feverGroup{}
+		feedsGroups := []feverFeedsGroup{}
+		for _, folder := range folders {
+			ids := []string{}
+			for _, feed := range feeds {
+				if name, ok := feedFolder[feed.ID]; ok && name == folder.Name {
+					ids = append(ids, strconv.FormatInt(feed.Seq, 10))
+				}
+			}
+			groups = append(groups, feverGroup{ID: feverGroupID(folder.ID), Title: folder.Name})
+			feedsGroups = append(feedsGroups, feverFeedsGroup{GroupID: feverGroupID(folder.ID), FeedIDs: strings.Join(ids, ",")})
+		}
+		resp["feeds_groups"] = feedsGroups
+		if has("groups") {
+			resp["groups"] = groups
+		}
+	}
+
+	if has("feeds") {
+		records := []feverFeed{}
+		for _, feed := range feeds {
+			updated := int64(0)
+			if feed.LastFetchedAt.Valid {
+				updated = feed.LastFetchedAt.Time.Unix()
+			}
+			records = append(records, feverFeed{
+				ID: feed.Seq,
+				Title: feed.Name,
+				URL: feed.Url,
+				SiteURL: feed.Url,
+				LastUpdatedOnTime: updated,
+			})
+		}
+		resp["feeds"] = records
+	}
+
+	//gator doesn't keep favicons or hot links
+	if has("favicons") {
+		resp["favicons"] = []struct{}{}
+	}
+	if has("links") {
+		resp["links"] = []struct{}{}
+	}
+
+	if has("items") {
+		items, err := cfg.feverItems(ctx, r, user)
+		if err != nil {
+			return err
+		}
+		total, err := cfg.s.db.CountPostsForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error counting posts: %v", err)
+		}
+		resp["items"] = items
+		resp["total_items"] = total
+	}
+
+	if has("unread_item_ids") {
+		ids, err := cfg.feverItemIDs(ctx, database.GetItemsForUserParams{UserID: user.ID, UnreadOnly: true})
+		if err != nil {
+			return err
+		}
+		resp["unread_item_ids"] = ids
+	}
+
+	if has("saved_item_ids") {
+		ids, err := cfg.feverItemIDs(ctx, database.GetItemsForUserParams{UserID: user.ID, StarredOnly: true})
+		if err != nil {
+			return err
+		}
+		resp["saved_item_ids"] = ids
+	}
+
+	return nil
+}
+
+//feverItems pages through items by id: after since_id, before max_id, or
+//the ones listed in with_ids
+func (cfg *apiConfig) feverItems(ctx context.Context, r *http.Request, user database.User) ([]feverItem, error) {
+	params := database.GetItemsForUserParams{
+		UserID: user.ID,
+		OldestFirst: true,
+		Limit: feverMaxItems,
+	}
+
+	switch {
+	case r.FormValue("with_ids") != "":
+		for _, v := range strings.Split(r.FormValue("with_ids"), ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err == nil && len(params.Seqs) < feverMaxItems {
+				params.Seqs = append(params.Seqs, id)
+			}
+		}
+		if len(params.Seqs) == 0 {
+			return []feverItem{}, nil
+		}
+	case r.FormValue("max_id") != "":
+		id, _ := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+		params.BeforeSeq = sql.NullInt64{Int64: id, Valid: true}
+		params.OldestFirst = false
+	default:
+		id, _ := strconv.ParseInt(r.FormValue("since_id"), 10, 64)
+		params.AfterSeq = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	items, err := cfg.s.db.GetItemsForUser(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting items: %v", err)
+	}
+
+	records := []feverItem{}
+	for _, item := range items {
+		records = append(records, feverItem{
+			ID: item.Seq,
+			FeedID: item.FeedSeq,
+			Title: item.Title,
+			HTML: itemHTML(item),
+			URL: item.Url,
+			IsSaved: boolInt(item.StarredAt.Valid),
+			IsRead: boolInt(item.ReadAt.Valid),
+			CreatedOnTime: item.PublishedAt.Unix(),
+		})
+	}
+	return records, nil
+}
+
+//feverItemIDs lists the ids of matching items, comma separated
+func (cfg *apiConfig) feverItemIDs(ctx context.Context, params database.GetItemsForUserParams) (string, error) {
+	params.Limit = maxItemIDs
+	items, err := cfg.s.db.GetItemsForUser(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("Error getting items: %v", err)
+	}
+
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, strconv.FormatInt(item.Seq, 10))
+	}
+	return strings.Join(ids, ","), nil
+}
+
+//feverMark handles mark=item|feed|group&as=...&id=...; requests the API
+//doesn't define are ignored, like Fever does
+func (cfg *apiConfig) feverMark(ctx context.Context, r *http.Request, user database.User, feeds []database.Feed) error {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	as := r.FormValue("as")
+
+	if r.FormValue("mark") == "item" {
+		items, err := getItemsBySeq(ctx, cfg.s, user, []int64{id})
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		switch as {
+		case "read", "unread":
+			return setItemRead(ctx, cfg.s, user, items[0].ID, as == "read")
+		case "saved", "unsaved":
+			return setItemStarred(ctx, cfg.s, user, items[0].ID, as == "saved")
+		}
+		return nil
+	}
+
+	if as != "read" {
+		return nil
+	}
+	before := time.Now()
+	if v, err := strconv.ParseInt(r.FormValue("before"), 10, 64); err == nil && v > 0 {
+		before = time.Unix(v, 0)
+	}
+
+	switch r.FormValue("mark") {
+	case "feed":
+		for _, feed := range feeds {
+			if feed.Seq == id {
+				return markRead(ctx, cfg.s, user, uuid.NullUUID{UUID: feed.ID, Valid: true}, uuid.NullUUID{}, before)
+			}
+		}
+	case "group":
+		//group 0 is Fever's "Kindling", every feed
+		if id == 0 {
+			return markRead(ctx, cfg.s, user, uuid.NullUUID{}, uuid.NullUUID{}, before)
+		}
+		folders, err := cfg.s.db.GetFoldersForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error getting folders: %v", err)
+		}
+		for _, folder := range folders {
+			if feverGroupID(folder.ID) == id {
+				return markRead(ctx, cfg.s, user, uuid.NullUUID{}, uuid.NullUUID{UUID: folder.ID, Valid: true}, before)
+			}
+		}
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//feverClient returns a function making Fever API calls with the api key
+//against a server on e's database: the query lists what to return, the form
+//what to change
+func (e *testEnv) feverClient(key string) func(query string, form url.Values) map[string]json.RawMessage {
+	server := httptest.NewServer((&apiConfig{s: e.s}).routes())
+	e.t.Cleanup(server.Close)
+	return func(query string, form url.Values) map[string]json.RawMessage {
+		e.t.Helper()
+		values := url.Values{"api_key": {key}}
+		for k, v := range form {
+			values[k] = v
+		}
+		resp, err := http.PostForm(server.URL+"/fever/?api&"+query, values)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body := map[string]json.RawMessage{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			e.t.Fatal(err)
+		}
+		return body
+	}
+}
+
+func TestFeverItems(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "bob")
+	e.mustRun("addfeed", "other", "http://example.com/other")
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("older", now.Add(-time.Hour)), testItem("newer", now))
+	e.fetch("http://example.com/other", testItem("elsewhere", now))
+	e.input("correct horse", "correct horse")
+	e.mustRun("app-password")
+
+	if body := e.feverClient(appPasswordKey("alice", "wrong"))("items", nil); string(body["auth"]) != "0" || body["items"] != nil {
+		t.Fatalf("a wrong api key got %v", body)
+	}
+	fever := e.feverClient(appPasswordKey("alice", "correct horse"))
+
+	seqs := map[string]int64{}
+	for _, post := range e.posts("alice") {
+		seqs[post.Title] = post.Seq
+	}
+	items := func(query string) []string {
+		t.Helper()
+		body := fever(query, nil)
+		var items []feverItem
+		if err := json.Unmarshal(body["items"], &items); err != nil {
+			t.Fatal(err)
+		}
+		titles := []string{}
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+	ids := func(key string) string {
+		t.Helper()
+		var ids string
+		if err := json.Unmarshal(fever(key, nil)[key], &ids); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	seq := func(title string) string {
+		return strconv.FormatInt(seqs[title], 10)
+	}
+
+	//only followed feeds' items, oldest first from since_id, newest first
+	//below max_id
+	if got, want := items("items&since_id=0"), []string{"older", "newer"}; !slices.Equal(got, want) {
+		t.Fatalf("items since 0 are %v, want %v", got, want)
+	}
+	if got, want := items("items&max_id="+seq("newer")), []string{"older"}; !slices.Equal(got, want) {
+		t.Fatalf("items below newer are %v, want %v", got, want)
+	}
+	if got, want := items("items&with_ids="+seq("newer")+",x"), []string{"newer"}; !slices.Equal(got, want) {
+		t.Fatalf("items with newer's id are %v, want %v", got, want)
+	}
+
+	fever("", url.Values{"mark": {"item"}, "as": {"saved"}, "id": {seq("older")}})
+	fever("", url.Values{"mark": {"item"}, "as": {"read"}, "id": {seq("older")}})
+	if got := ids("saved_item_ids"); got != seq("older") {
+		t.Errorf("the saved items are %q, want older", got)
+	}
+	if got := ids("unread_item_ids"); got != seq("newer") {
+		t.Errorf("the unread items are %q, want newer", got)
+	}
+
+	//marking the feed read stops at before
+	feedID := strconv.FormatInt(e.feed("http://example.com/feed").Seq, 10)
+	fever("", url.Values{"mark": {"feed"}, "as": {"read"}, "id": {feedID}, "before": {strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)}})
+	if got := ids("unread_item_ids"); got != seq("newer") {
+		t.Errorf("after marking the feed read before newer the unread items are %q", got)
+	}
+	fever("", url.Values{"mark": {"feed"}, "as": {"read"}, "id": {feedID}})
+	if got := ids("unread_item_ids"); got != "" {
+		t.Errorf("after marking the feed read the unread items are %q", got)
+	}
+}
+
+func TestFeverGroupsAreFolders(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("move", "blog", "tech")
+	e.fetch("http://example.com/feed", testItem("blog post", time.Now()))
+	e.fetch("http://example.com/news", testItem("news item", time.Now()))
+	e.input("correct horse", "correct horse")
+	e.mustRun("app-password")
+
+	fever := e.feverClient(appPasswordKey("alice", "correct horse"))
+
+	body := fever("groups", nil)
+	var groups []feverGroup
+	var feedsGroups []feverFeedsGroup
+	if err := json.Unmarshal(body["groups"], &groups); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body["feeds_groups"], &feedsGroups); err != nil {
+		t.Fatal(err)
+	}
+	blog := e.feed("http://example.com/feed")
+	if len(groups) != 1 || groups[0].Title != "tech" {
+		t.Fatalf("the groups are %+v, want the tech folder", groups)
+	}
+	if len(feedsGroups) != 1 || feedsGroups[0].GroupID != groups[0].ID || feedsGroups[0].FeedIDs != strconv.FormatInt(blog.Seq, 10) {
+		t.Fatalf("the feeds groups are %+v, want only blog in tech", feedsGroups)
+	}
+
+	//marking the group read leaves the feed outside it
+	fever("", url.Values{"mark": {"group"}, "as": {"read"}, "id": {strconv.FormatInt(groups[0].ID, 10)}})
+	for _, post := range e.posts("alice") {
+		if read := post.ReadAt.Valid; read != (post.Title == "blog post") {
+			t.Errorf("%v read: %v after marking the tech group read", post.Title, read)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//folders are per user; each of the user's follows is in at most one of them
+
+//folderName trims a folder name given on the command line or in an OPML file
+func folderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("The folder name can't be empty")
+	}
+	return name, nil
+}
+
+//getFolder finds one of the user's folders by name
+func getFolder(ctx context.Context, s *state, user database.User, name string) (database.Folder, error) {
+	folder, err := s.db.GetFolder(ctx, database.GetFolderParams{
+		UserID: user.ID,
+		Name: name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Folder{}, fmt.Errorf("Folder %v %w, create it with: folder create %v", name, errNotFound, name)
+	}
+	if err != nil {
+		return database.Folder{}, fmt.Errorf("Error getting folder: %v", err)
+	}
+	return folder, nil
+}
+
+//createFolder makes a new, empty folder for the user
+func createFolder(ctx context.Context, s *state, user database.User, name string) (database.Folder, error) {
+	folder, err := s.db.CreateFolder(ctx, database.CreateFolderParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID: user.ID,
+		Name: name,
+	})
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return database.Folder{}, fmt.Errorf("Folder %v %w", name, errConflict)
+		}
+		return database.Folder{}, fmt.Errorf("Error creating folder: %v", err)
+	}
+	return folder, nil
+}
+
+//ensureFolder gets one of the user's folders, creating it if it's missing
+func ensureFolder(ctx context.Context, s *state, user database.User, name string) (database.Folder, error) {
+	folder, err := getFolder(ctx, s, user, name)
+	if errors.Is(err, errNotFound) {
+		return createFolder(ctx, s, user, name)
+	}
+	return folder, err
+}
+
+//setFolder moves the user's follow of a feed into a folder
+func setFolder(ctx context.Context, s *state, user database.User, feedID uuid.UUID, folder database.Folder) error {
+	n, err := s.db.SetFollowFolder(ctx, database.SetFollowFolderParams{
+		FolderID: folder.ID,
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error moving feed to folder: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("Not following that feed")
+	}
+	return nil
+}
+
+//folderFilter turns a --folder flag into the folder id posts are filtered by
+func folderFilter(ctx context.Context, s *state, user database.User, name string) (uuid.NullUUID, error) {
+	if name == "" {
+		return uuid.NullUUID{}, nil
+	}
+	folder, err := getFolder(ctx, s, user, name)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: folder.ID, Valid: true}, nil
+}
+
+func handlerFolderCreate(s *state, cmd command, user database.User) error {
+	name, err := folderName(cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	folder, err := createFolder(context.Background(), s, user, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created folder %v\n", folder.Name)
+	return nil
+}
+
+func handlerFolderRename(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	folder, err := getFolder(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	name, err := folderName(cmd.args[1])
+	if err != nil {
+		return err
+	}
+
+	err = s.db.RenameFolder(ctx, database.RenameFolderParams{
+		ID: folder.ID,
+		Name: name,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return fmt.Errorf("Folder %v %w", name, errConflict)
+		}
+		return fmt.Errorf("Error renaming folder: %v", err)
+	}
+
+	fmt.Printf("Renamed folder %v to %v\n", folder.Name, name)
+	return nil
+}
+
+func handlerFolderRemove(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	folder, err := getFolder(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	//the feeds in it stay followed, just outside any folder
+	err = s.db.DeleteFolder(ctx, folder.ID)
+	if err != nil {
+		return fmt.Errorf("Error deleting folder: %v", err)
+	}
+
+	fmt.Printf("Removed folder %v, its feeds are still followed\n", folder.Name)
+	return nil
+}
+
+func handlerFolderList(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	folders, err := s.db.GetFoldersForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting folders: %v", err)
+	}
+
+	records := []folderRecord{}
+	for _, folder := range folders {
+		records = append(records, newFolderRecord(folder))
+	}
+
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No folders yet, add one with: folder create <name>")
+		return nil
+	}
+	fmt.Println("Folders:")
+	for _, folder := range records {
+		fmt.Printf("  - %v (%v feeds)\n", folder.Name, folder.Feeds)
+	}
+	return nil
+}
+
+//handlerMove puts a followed feed in a folder, or takes it out of its
+//folder when no folder is given
+func handlerMove(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	follow, err := findFollowedFeed(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(cmd.args) == 1 {
+		err = s.db.ClearFollowFolder(ctx, database.ClearFollowFolderParams{
+			UserID: user.ID,
+			FeedID: follow.FeedID,
+		})
+		if err != nil {
+			return fmt.Errorf("Error taking feed out of folder: %v", err)
+		}
+		fmt.Printf("%v is no longer in a folder\n", follow.FeedName)
+		return nil
+	}
+
+	folder, err := getFolder(ctx, s, user, cmd.args[1])
+	if err != nil {
+		return err
+	}
+	if err := setFolder(ctx, s, user, follow.FeedID, folder); err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %v to %v\n", follow.FeedName, folder.Name)
+	return nil
+}
+
+//handlerMarkAllRead marks every post from the feeds the user follows as
+//read, or just those of one feed or folder
+func handlerMarkAllRead(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	if cmd.flagString("feed") != "" && cmd.flagString("folder") != "" {
+		return fmt.Errorf("Use either --feed or --folder, not both")
+	}
+
+	feedID := uuid.NullUUID{}
+	if cmd.flagString("feed") != "" {
+		follow, err := findFollowedFeed(s, user, cmd.flagString("feed"))
+		if err != nil {
+			return err
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+	}
+	folderID, err := folderFilter(ctx, s, user, cmd.flagString("folder"))
+	if err != nil {
+		return err
+	}
+
+	n, err := s.db.MarkPostsRead(ctx, database.MarkPostsReadParams{
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID: user.ID,
+		FeedID: feedID,
+		FolderID: folderID,
+		Before: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("Error marking posts read: %v", err)
+	}
+
+	fmt.Printf("Marked %v posts as read\n", n)
+	return nil
+}
+
+//completeFolders suggests the names of the current user's folders
+func completeFolders(s *state) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	folders, err := s.db.GetFoldersForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+	}
+	return names
+}
+
+type folderRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Feeds     int64     `json:"feeds"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newFolderRecord(folder database.GetFoldersForUserRow) folderRecord {
+	return folderRecord{
+		ID:        folder.ID,
+		Name:      folder.Name,
+		Feeds:     folder.FeedCount,
+		CreatedAt: folder.CreatedAt,
+	}
+}
+
+func (r folderRecord) header() []string {
+	return []string{"id", "name", "feeds", "created_at"}
+}
+
+func (r folderRecord) fields() []string {
+	return []string{r.ID.String(), r.Name, fmt.Sprint(r.Feeds), formatTime(r.CreatedAt)}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+//folderOf is the folder the user's follow of the feed at url is in
+func (e *testEnv) folderOf(name, url string) string {
+	e.t.Helper()
+	follows, err := e.s.db.GetFeedFollowsForUser(context.Background(), e.user(name).ID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	for _, follow := range follows {
+		if follow.FeedUrl == url {
+			return follow.FolderName.String
+		}
+	}
+	e.t.Fatalf("%v doesn't follow %v", name, url)
+	return ""
+}
+
+func TestFolders(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "bob")
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("first", now))
+	e.fetch("http://example.com/news", testItem("headline", now.Add(-time.Minute)))
+
+	if _, err := e.run("folder", "create", "  "); err == nil {
+		t.Fatal("creating a folder without a name succeeded")
+	}
+	//folders are per user, bob's tech doesn't get in the way
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("folder", "create", "later")
+	if _, err := e.run("folder", "create", "tech"); !errors.Is(err, errConflict) {
+		t.Fatalf("creating tech twice: got %v, want a conflict", err)
+	}
+
+	if _, err := e.run("move", "blog", "missing"); err == nil {
+		t.Fatal("moving a feed to a missing folder succeeded")
+	}
+	e.mustRun("move", "blog", "tech")
+	if folder := e.folderOf("alice", "http://example.com/feed"); folder != "tech" {
+		t.Fatalf("blog is in %q, want tech", folder)
+	}
+	if out := e.mustRun("folder", "list"); !strings.Contains(out, "tech (1 feeds)") || !strings.Contains(out, "later (0 feeds)") {
+		t.Fatalf("folder list printed:\n%v", out)
+	}
+	if got, want := e.browseTitles("10", "--folder", "tech"), []string{"first"}; !slices.Equal(got, want) {
+		t.Fatalf("browse --folder tech lists %v, want %v", got, want)
+	}
+
+	if _, err := e.run("markall", "read", "--feed", "news", "--folder", "tech"); err == nil {
+		t.Fatal("markall read with both --feed and --folder succeeded")
+	}
+	e.mustRun("markall", "read", "--folder", "tech")
+	if got, want := e.browseTitles("10", "--unread"), []string{"headline"}; !slices.Equal(got, want) {
+		t.Fatalf("after marking tech read browse --unread lists %v, want %v", got, want)
+	}
+
+	if _, err := e.run("folder", "rename", "tech", "later"); !errors.Is(err, errConflict) {
+		t.Fatalf("renaming tech to later: got %v, want a conflict", err)
+	}
+	e.mustRun("folder", "rename", "tech", "programming")
+	if folder := e.folderOf("alice", "http://example.com/feed"); folder != "programming" {
+		t.Fatalf("after renaming tech blog is in %q, want programming", folder)
+	}
+
+	//removing a folder keeps its feeds followed
+	e.mustRun("folder", "rm", "programming")
+	if folder := e.folderOf("alice", "http://example.com/feed"); folder != "" {
+		t.Fatalf("after removing its folder blog is in %q", folder)
+	}
+	e.mustRun("move", "news", "later")
+	e.mustRun("move", "news")
+	if folder := e.folderOf("alice", "http://example.com/news"); folder != "" {
+		t.Fatalf("after moving it out news is in %q", folder)
+	}
+
+	e.mustRun("login", "bob")
+	if out := e.mustRun("folder", "list"); !strings.Contains(out, "tech (0 feeds)") || strings.Contains(out, "later") {
+		t.Fatalf("bob's folder list printed:\n%v", out)
+	}
+}
+
+func TestOPMLFolders(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("move", "blog", "tech")
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	e.mustRun("opml", "export", "--out", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<outline text="tech"`) {
+		t.Fatalf("the export has no tech outline:\n%s", data)
+	}
+
+	//bob picks up alice's feeds in the same folders
+	e.mustRun("register", "bob")
+	e.mustRun("opml", "import", path)
+	if folder := e.folderOf("bob", "http://example.com/feed"); folder != "tech" {
+		t.Errorf("the import put blog in %q, want tech", folder)
+	}
+	if folder := e.folderOf("bob", "http://example.com/news"); folder != "" {
+		t.Errorf("the import put news in %q, want no folder", folder)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//where the new posts of a followed feed are sent
+const (
+	notifyAll = "all"
+	notifyDigest = "digest"
+	notifyWebhooks = "webhooks"
+	notifyNone = "none"
+)
+
+var notifyOptions = []string{notifyAll, notifyDigest, notifyWebhooks, notifyNone}
+
+//the order browse lists a feed's posts in
+const (
+	sortNewest = "newest"
+	sortOldest = "oldest"
+)
+
+var sortOptions = []string{sortNewest, sortOldest}
+
+//followName is the title the user gave a followed feed, or its name
+func followName(follow database.GetFeedFollowsForUserRow) string {
+	if follow.Title.Valid {
+		return follow.Title.String
+	}
+	return follow.FeedName
+}
+
+//checkSort refuses a sort order browse doesn't know
+func checkSort(sortOrder string) error {
+	if !slices.Contains(sortOptions, sortOrder) {
+		return fmt.Errorf("Unknown sort %q, use one of: %v", sortOrder, strings.Join(sortOptions, ", "))
+	}
+	return nil
+}
+
+//handlerFollowSettings shows the user's settings for a followed feed, or
+//changes the ones given as flags
+func handlerFollowSettings(s *state, cmd command, user database.User) error {
+	follow, err := findFollowedFeed(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	settings := database.UpdateFollowSettingsParams{
+		UserID: user.ID,
+		FeedID: follow.FeedID,
+		Title: follow.Title,
+		Muted: follow.Muted,
+		Priority: follow.Priority,
+		Notify: follow.Notify,
+		SortOrder: follow.SortOrder,
+		UpdatedAt: time.Now(),
+	}
+	changed := false
+	if cmd.flagSet("title") {
+		//an empty title goes back to the feed's name
+		title := strings.TrimSpace(cmd.flagString("title"))
+		settings.Title = sql.NullString{String: title, Valid: title != ""}
+		changed = true
+	}
+	if cmd.flagSet("mute") {
+		settings.Muted = cmd.flagBool("mute")
+		changed = true
+	}
+	if cmd.flagSet("priority") {
+		settings.Priority = int32(cmd.flagInt("priority"))
+		changed = true
+	}
+	if cmd.flagSet("notify") {
+		settings.Notify = cmd.flagString("notify")
+		if !slices.Contains(notifyOptions, settings.Notify) {
+			return fmt.Errorf("Unknown notify %q, use one of: %v", settings.Notify, strings.Join(notifyOptions, ", "))
+		}
+		changed = true
+	}
+	if cmd.flagSet("sort") {
+		settings.SortOrder = cmd.flagString("sort")
+		if err := checkSort(settings.SortOrder); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if changed {
+		err = s.db.UpdateFollowSettings(context.Background(), settings)
+		if err != nil {
+			return fmt.Errorf("Error updating follow settings: %v", err)
+		}
+		fmt.Printf("Updated settings for %v:\n", follow.FeedName)
+	} else {
+		fmt.Printf("Settings for %v:\n", follow.FeedName)
+	}
+
+	title := "(the feed's name)"
+	if settings.Title.Valid {
+		title = settings.Title.String
+	}
+	fmt.Printf("  - Title: %v\n", title)
+	fmt.Printf("  - Muted: %v\n", settings.Muted)
+	fmt.Printf("  - Priority: %v\n", settings.Priority)
+	fmt.Printf("  - Notify: %v\n", settings.Notify)
+	fmt.Printf("  - Sort: %v\n", settings.SortOrder)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollowSettings(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.mustRun("addfeed", "podcast", "http://example.com/podcast")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("first", now), testItem("second", now.Add(-2*time.Minute)))
+	e.fetch("http://example.com/news", testItem("headline", now.Add(-time.Minute)))
+	e.fetch("http://example.com/podcast", testItem("episode", now.Add(-3*time.Minute)))
+
+	out := e.mustRun("follow-settings", "blog")
+	for _, want := range []string{"Title: (the feed's name)", "Muted: false", "Priority: 0", "Notify: all", "Sort: newest"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the default settings don't show %q:\n%v", want, out)
+		}
+	}
+	for _, flags := range [][]string{{"--notify", "email"}, {"--sort", "random"}} {
+		if _, err := e.run(append([]string{"follow-settings", "blog"}, flags...)...); err == nil {
+			t.Errorf("follow-settings %v succeeded", flags)
+		}
+	}
+
+	e.mustRun("follow-settings", "blog", "--title", "My Blog", "--sort", "oldest")
+	e.mustRun("follow-settings", "podcast", "--mute")
+	e.mustRun("follow-settings", "news", "--priority", "5", "--notify", "none")
+	out = e.mustRun("following")
+	if !strings.Contains(out, "My Blog") || !strings.Contains(out, "podcast (muted)") {
+		t.Fatalf("following doesn't show the title and the muted feed:\n%v", out)
+	}
+
+	//muted feeds are left out unless asked for, higher priorities come first
+	if got, want := e.browseTitles("10"), []string{"headline", "first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("browse lists %v, want %v", got, want)
+	}
+	if got, want := e.browseTitles("10", "--feed", "podcast"), []string{"episode"}; !slices.Equal(got, want) {
+		t.Errorf("browse --feed podcast lists %v, want %v", got, want)
+	}
+	if got, want := e.browseTitles("10", "--feed", "blog"), []string{"second", "first"}; !slices.Equal(got, want) {
+		t.Errorf("browse --feed blog lists %v, want its oldest first %v", got, want)
+	}
+	if got, want := e.browseTitles("10", "--feed", "blog", "--sort", "newest"), []string{"first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("browse --feed blog --sort newest lists %v, want %v", got, want)
+	}
+
+	//news asked not to be notified about, so its posts stay out of digests
+	user := e.user("alice")
+	d, err := buildDigest(context.Background(), e.s, user.ID, user.Name, now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, feed := range d.Feeds {
+		for _, post := range feed.Posts {
+			titles = append(titles, post.Title)
+		}
+	}
+	if slices.Contains(titles, "headline") || !slices.Contains(titles, "first") {
+		t.Errorf("the digest lists %v, want blog's posts and not news'", titles)
+	}
+
+	//an empty title goes back to the feed's name
+	e.mustRun("follow-settings", "blog", "--title", "")
+	if out := e.mustRun("following"); strings.Contains(out, "My Blog") || !strings.Contains(out, "blog") {
+		t.Errorf("following after clearing the title:\n%v", out)
+	}
+}
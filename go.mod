@@ -3,7 +3,12 @@ module github.com/npayetteraynauld/Blog-Aggregator
 go 1.24.3
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	golang.org/x/net v0.41.0 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
+	golang.org/x/crypto v0.39.0
+	golang.org/x/net v0.41.0
+	golang.org/x/term v0.32.0
 )
+
+require golang.org/x/sys v0.33.0 // indirect
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//Google Reader API streams and ids, as spoken by NetNewsWire, Reeder and
+//FreshRSS clients
+const (
+	streamReadingList = "user/-/state/com.google/reading-list"
+	streamRead = "user/-/state/com.google/read"
+	streamStarred = "user/-/state/com.google/starred"
+	streamKeptUnread = "user/-/state/com.google/kept-unread"
+	streamFeedPrefix = "feed/"
+	streamLabelPrefix = "user/-/label/"
+	greaderItemPrefix = "tag:google.com,2005:reader/item/"
+	defaultGReaderItems = 20
+)
+
+func (cfg *apiConfig) greaderRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /accounts/ClientLogin", cfg.handlerGReaderLogin)
+
+	mux.HandleFunc("GET /reader/api/0/token", cfg.middlewareGReader(handlerGReaderToken))
+	mux.HandleFunc("GET /reader/api/0/user-info", cfg.middlewareGReader(handlerGReaderUserInfo))
+	mux.HandleFunc("GET /reader/api/0/subscription/list", cfg.middlewareGReader(cfg.handlerGReaderSubscriptions))
+	mux.HandleFunc("POST /reader/api/0/subscription/edit", cfg.middlewareGReader(cfg.handlerGReaderEditSubscription))
+	mux.HandleFunc("POST /reader/api/0/subscription/quickadd", cfg.middlewareGReader(cfg.handlerGReaderQuickAdd))
+	mux.HandleFunc("GET /reader/api/0/tag/list", cfg.middlewareGReader(cfg.handlerGReaderTags))
+	mux.HandleFunc("GET /reader/api/0/unread-count", cfg.middlewareGReader(cfg.handlerGReaderUnreadCount))
+	mux.HandleFunc("GET /reader/api/0/stream/items/ids", cfg.middlewareGReader(cfg.handlerGReaderItemIDs))
+	mux.HandleFunc("/reader/api/0/stream/items/contents", cfg.middlewareGReader(cfg.handlerGReaderItemContents))
+	mux.HandleFunc("GET /reader/api/0/stream/contents/{stream...}", cfg.middlewareGReader(cfg.handlerGReaderStreamContents))
+	mux.HandleFunc("POST /reader/api/0/edit-tag", cfg.middlewareGReader(cfg.handlerGReaderEditTag))
+	mux.HandleFunc("POST /reader/api/0/mark-all-as-read", cfg.middlewareGReader(cfg.handlerGReaderMarkAllRead))
+}
+
+//handlerGReaderLogin checks a user name and app password; the returned
+//auth token is the app password's key, so changing or removing the app
+//password signs the apps out. The credentials are only read from the form
+//body, so they don't end up in urls and access logs.
+func (cfg *apiConfig) handlerGReaderLogin(w http.ResponseWriter, r *http.Request) {
+	name := r.PostFormValue("Email")
+	key := appPasswordKey(name, r.PostFormValue("Passwd"))
+
+	_, err := userByAppKey(r.Context(), cfg.s, key)
+	if errors.Is(err, errNotFound) {
+		respondWithText(w, http.StatusUnauthorized, "Error=BadAuthentication\n")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.FormValue("output") == "json" {
+		respondWithJSON(w, http.StatusOK, map[string]string{"SID": key, "LSID": key, "Auth": key})
+		return
+	}
+	respondWithText(w, http.StatusOK, fmt.Sprintf("SID=%v\nLSID=%v\nAuth=%v\n", key, key, key))
+}
+
+//middlewareGReader resolves the user from "Authorization: GoogleLogin auth=<token>"
+func (cfg *apiConfig) middlewareGReader(handler apiUserHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+		if !ok {
+			respondWithText(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		user, err := userByAppKey(r.Context(), cfg.s, strings.TrimSpace(key))
+		if errors.Is(err, errNotFound) {
+			respondWithText(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+//handlerGReaderToken hands out the edit token clients send back as T. Auth
+//is a header, which browsers can't forge, so the token isn't checked.
+func handlerGReaderToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithText(w, http.StatusOK, hashToken(user.ID.String())[:57]+"\n")
+}
+
+func handlerGReaderUserInfo(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"userId": user.ID.String(),
+		"userName": user.Name,
+		"userProfileId": user.ID.String(),
+		"userEmail": "",
+	})
+}
+
+func (cfg *apiConfig) handlerGReaderSubscriptions(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, err := cfg.s.db.GetFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feeds for user: "+err.Error())
+		return
+	}
+	folders, err := feedFolders(r.Context(), cfg.s, user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type category struct {
+		ID string `json:"id"`
+		Label string `json:"label"`
+	}
+	type subscription struct {
+		ID string `json:"id"`
+		Title string `json:"title"`
+		Categories []category `json:"categories"`
+		URL string `json:"url"`
+		HTMLURL string `json:"htmlUrl"`
+		IconURL string `json:"iconUrl"`
+	}
+
+	subscriptions := []subscription{}
+	for _, feed := range feeds {
+		//a feed's folder is its one label
+		categories := []category{}
+		if name, ok := folders[feed.ID]; ok {
+			categories = append(categories, category{ID: streamLabelPrefix + name, Label: name})
+		}
+		subscriptions = append(subscriptions, subscription{
+			ID: streamFeedPrefix + feed.Url,
+			Title: feed.Name,
+			Categories: categories,
+			URL: feed.Url,
+			HTMLURL: feed.Url,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": subscriptions})
+}
+
+//handlerGReaderEditSubscription subscribes to or unsubscribes from the feeds
+//given as s=feed/<url>, all of them or none. a=user/-/label/<folder> moves
+//them into a folder, created if needed, and r= takes them out of it; renames
+//are accepted but ignored
+func (cfg *apiConfig) handlerGReaderEditSubscription(w http.ResponseWriter, r *http.Request, user database.User) {
+	urls := []string{}
+	for _, stream := range r.Form["s"] {
+		url, ok := strings.CutPrefix(stream, streamFeedPrefix)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Not a feed stream: "+stream)
+			return
+		}
+		urls = append(urls, url)
+	}
+
+	err := inTx(r.Context(), cfg.s, func(tx *state) error {
+		for _, url := range urls {
+			var err error
+			switch r.FormValue("ac") {
+			case "subscribe":
+				_, err = subscribe(r.Context(), tx, user, url, r.FormValue("t"))
+			case "unsubscribe":
+				_, err = unfollowFeed(r.Context(), tx, user, url)
+			}
+			if err == nil && r.FormValue("ac") != "unsubscribe" {
+				err = greaderEditLabel(r.Context(), tx, user, url, r.FormValue("a"), r.FormValue("r"))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+//greaderEditLabel moves a followed feed into the folder named by the label
+//add, or out of the folder named by remove
+func greaderEditLabel(ctx context.Context, s *state, user database.User, url, add, remove string) error {
+	add, adding := strings.CutPrefix(normalizeStream(add), streamLabelPrefix)
+	remove, removing := strings.CutPrefix(normalizeStream(remove), streamLabelPrefix)
+	if !adding && !removing {
+		return nil
+	}
+	feed, err := getFeedByURL(ctx, s, url)
+	if err != nil {
+		return err
+	}
+
+	if adding {
+		name, err := folderName(add)
+		if err != nil {
+			return err
+		}
+		folder, err := ensureFolder(ctx, s, user, name)
+		if err != nil {
+			return err
+		}
+		return setFolder(ctx, s, user, feed.ID, folder)
+	}
+
+	folders, err := feedFolders(ctx, s, user)
+	if err != nil {
+		return err
+	}
+	if folders[feed.ID] != remove {
+		return nil
+	}
+	err = s.db.ClearFollowFolder(ctx, database.ClearFollowFolderParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error taking feed out of folder: %v", err)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGReaderQuickAdd(w http.ResponseWriter, r *http.Request, user database.User) {
+	url := strings.TrimPrefix(r.FormValue("quickadd"), streamFeedPrefix)
+	if url == "" {
+		respondWithError(w, http.StatusBadRequest, "quickadd is required")
+		return
+	}
+
+	feed, err := subscribe(r.Context(), cfg.s, user, url, "")
+	if err != nil {
+		respondWithActionError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"numResults": 1,
+		"query": feed.Url,
+		"streamId": streamFeedPrefix + feed.Url,
+		"streamName": feed.Name,
+	})
+}
+
+func (cfg *apiConfig) handlerGReaderTags(w http.ResponseWriter, r *http.Request, user database.User) {
+	folders, err := cfg.s.db.GetFoldersForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting folders: "+err.Error())
+		return
+	}
+
+	type tag struct {
+		ID string `json:"id"`
+		Type string `json:"type,omitempty"`
+	}
+	tags := []tag{{ID: streamStarred}}
+	for _, folder := range folders {
+		tags = append(tags, tag{ID: streamLabelPrefix + folder.Name, Type: "folder"})
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"tags": tags})
+}
+
+func (cfg *apiConfig) handlerGReaderUnreadCount(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, err := cfg.s.db.GetFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feeds for user: "+err.Error())
+		return
+	}
+	counts, err := cfg.s.db.GetUnreadCountsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting unread counts: "+err.Error())
+		return
+	}
+
+	unread := map[uuid.UUID]int64{}
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+	}
+
+	type unreadCount struct {
+		ID string `json:"id"`
+		Count int64 `json:"count"`
+		NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+	}
+
+	total := int64(0)
+	newest := time.Time{}
+	unreadCounts := []unreadCount{}
+	for _, feed := range feeds {
+		fetched := feed.LastFetchedAt.Time
+		if fetched.After(newest) {
+			newest = fetched
+		}
+		total += unread[feed.ID]
+		unreadCounts = append(unreadCounts, unreadCount{
+			ID: streamFeedPrefix + feed.Url,
+			Count: unread[feed.ID],
+			NewestItemTimestampUsec: usec(fetched),
+		})
+	}
+	unreadCounts = append(unreadCounts, unreadCount{
+		ID: streamReadingList,
+		Count: total,
+		NewestItemTimestampUsec: usec(newest),
+	})
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"max": maxItemIDs,
+		"unreadcounts": unreadCounts,
+	})
+}
+
+func (cfg *apiConfig) handlerGReaderItemIDs(w http.ResponseWriter, r *http.Request, user database.User) {
+	items, continuation, err := cfg.greaderStream(r, user, r.FormValue("s"))
+	if err != nil {
+		respondWithStreamError(w, err)
+		return
+	}
+
+	type itemRef struct {
+		ID string `json:"id"`
+		DirectStreamIDs []string `json:"directStreamIds"`
+		TimestampUsec string `json:"timestampUsec"`
+	}
+
+	refs := []itemRef{}
+	for _, item := range items {
+		refs = append(refs, itemRef{
+			ID: strconv.FormatInt(item.Seq, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec: usec(item.CreatedAt),
+		})
+	}
+
+	resp := map[string]interface{}{"itemRefs": refs}
+	if continuation != "" {
+		resp["continuation"] = continuation
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerGReaderItemContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	seqs := []int64{}
+	for _, id := range r.Form["i"] {
+		seq, err := parseGReaderItemID(id)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		seqs = append(seqs, seq)
+	}
+	if len(seqs) > maxItemIDs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %v items can be requested at once", maxItemIDs))
+		return
+	}
+
+	items, err := getItemsBySeq(r.Context(), cfg.s, user, seqs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newGReaderContents(user, streamReadingList, items, ""))
+}
+
+func (cfg *apiConfig) handlerGReaderStreamContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	stream := r.PathValue("stream")
+	items, continuation, err := cfg.greaderStream(r, user, stream)
+	if err != nil {
+		respondWithStreamError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newGReaderContents(user, stream, items, continuation))
+}
+
+//handlerGReaderEditTag adds (a=) and removes (r=) the read and starred
+//states of the items given as i=
+func (cfg *apiConfig) handlerGReaderEditTag(w http.ResponseWriter, r *http.Request, user database.User) {
+	seqs := []int64{}
+	for _, id := range r.Form["i"] {
+		seq, err := parseGReaderItemID(id)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		seqs = append(seqs, seq)
+	}
+
+	items, err := getItemsBySeq(r.Context(), cfg.s, user, seqs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type change struct {
+		tag string
+		add bool
+	}
+	changes := []change{}
+	for _, tag := range r.Form["a"] {
+		changes = append(changes, change{tag: normalizeStream(tag), add: true})
+	}
+	for _, tag := range r.Form["r"] {
+		changes = append(changes, change{tag: normalizeStream(tag), add: false})
+	}
+
+	err = inTx(r.Context(), cfg.s, func(tx *state) error {
+		for _, item := range items {
+			for _, c := range changes {
+				var err error
+				switch c.tag {
+				case streamRead:
+					err = setItemRead(r.Context(), tx, user, item.ID, c.add)
+				case streamKeptUnread:
+					err = setItemRead(r.Context(), tx, user, item.ID, !c.add)
+				case streamStarred:
+					err = setItemStarred(r.Context(), tx, user, item.ID, c.add)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+//handlerGReaderMarkAllRead marks a stream read up to ts (microseconds)
+func (cfg *apiConfig) handlerGReaderMarkAllRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	before := time.Now()
+	if ts, err := strconv.ParseInt(r.FormValue("ts"), 10, 64); err == nil && ts > 0 {
+		before = time.UnixMicro(ts)
+	}
+
+	//labels are folders
+	if name, ok := strings.CutPrefix(normalizeStream(r.FormValue("s")), streamLabelPrefix); ok {
+		folder, err := getFolder(r.Context(), cfg.s, user, name)
+		if err != nil {
+			respondWithActionError(w, err)
+			return
+		}
+		if err := markRead(r.Context(), cfg.s, user, uuid.NullUUID{}, uuid.NullUUID{UUID: folder.ID, Valid: true}, before); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithText(w, http.StatusOK, "OK")
+		return
+	}
+
+	params, err := cfg.greaderStreamFilter(r.Context(), user, r.FormValue("s"))
+	if err != nil {
+		respondWithStreamError(w, err)
+		return
+	}
+	if params.StarredOnly {
+		respondWithError(w, http.StatusBadRequest, "Only feeds, labels and the reading list can be marked as read")
+		return
+	}
+
+	if err := markRead(r.Context(), cfg.s, user, params.FeedID, uuid.NullUUID{}, before); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+//errUnsupportedStream is returned for streams gator has no equivalent of
+var errUnsupportedStream = errors.New("unsupported stream")
+
+func respondWithStreamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnsupportedStream) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithActionError(w, err)
+}
+
+//normalizeStream turns user/<id>/... into the user/-/... form
+func normalizeStream(stream string) string {
+	parts := strings.SplitN(stream, "/", 3)
+	if len(parts) == 3 && parts[0] == "user" {
+		return "user/-/" + parts[2]
+	}
+	return stream
+}
+
+//greaderStreamFilter turns a stream id into the matching item filters
+func (cfg *apiConfig) greaderStreamFilter(ctx context.Context, user database.User, stream string) (database.GetItemsForUserParams, error) {
+	params := database.GetItemsForUserParams{UserID: user.ID}
+
+	stream = normalizeStream(stream)
+	switch {
+	case stream == streamReadingList:
+	case stream == streamStarred:
+		params.StarredOnly = true
+	case strings.HasPrefix(stream, streamFeedPrefix):
+		feed, err := getFeedByURL(ctx, cfg.s, strings.TrimPrefix(stream, streamFeedPrefix))
+		if err != nil {
+			return params, err
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	default:
+		return params, fmt.Errorf("%w %v", errUnsupportedStream, stream)
+	}
+	return params, nil
+}
+
+//greaderStream lists a stream's items with the paging parameters shared by
+//stream/items/ids and stream/contents: n, r=o, c, xt, ot and nt
+func (cfg *apiConfig) greaderStream(r *http.Request, user database.User, stream string) ([]database.GetItemsForUserRow, string, error) {
+	params, err := cfg.greaderStreamFilter(r.Context(), user, stream)
+	if err != nil {
+		return nil, "", err
+	}
+
+	n := defaultGReaderItems
+	if v, err := strconv.Atoi(r.FormValue("n")); err == nil && v > 0 {
+		n = min(v, maxItemIDs)
+	}
+	params.Limit = int32(n)
+	params.OldestFirst = r.FormValue("r") == "o"
+
+	if normalizeStream(r.FormValue("xt")) == streamRead {
+		params.UnreadOnly = true
+	}
+	if c, err := strconv.ParseInt(r.FormValue("c"), 10, 64); err == nil {
+		if params.OldestFirst {
+			params.AfterSeq = sql.NullInt64{Int64: c, Valid: true}
+		} else {
+			params.BeforeSeq = sql.NullInt64{Int64: c, Valid: true}
+		}
+	}
+	if ot, err := strconv.ParseInt(r.FormValue("ot"), 10, 64); err == nil && ot > 0 {
+		params.NewerThan = sql.NullTime{Time: time.Unix(ot, 0), Valid: true}
+	}
+	if nt, err := strconv.ParseInt(r.FormValue("nt"), 10, 64); err == nil && nt > 0 {
+		params.OlderThan = sql.NullTime{Time: time.Unix(nt, 0), Valid: true}
+	}
+
+	items, err := cfg.s.db.GetItemsForUser(r.Context(), params)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error getting items: %v", err)
+	}
+
+	continuation := ""
+	if len(items) == n {
+		continuation = strconv.FormatInt(items[len(items)-1].Seq, 10)
+	}
+	return items, continuation, nil
+}
+
+//parseGReaderItemID accepts the long tag:google.com form (hex) and the
+//short decimal form of an item id
+func parseGReaderItemID(id string) (int64, error) {
+	if hex, ok := strings.CutPrefix(id, greaderItemPrefix); ok {
+		seq, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid item id %v", id)
+		}
+		return int64(seq), nil
+	}
+	seq, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid item id %v", id)
+	}
+	return seq, nil
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderItem struct {
+	ID string `json:"id"`
+	CrawlTimeMsec string `json:"crawlTimeMsec"`
+	TimestampUsec string `json:"timestampUsec"`
+	Published int64 `json:"published"`
+	Updated int64 `json:"updated"`
+	Title string `json:"title"`
+	Author string `json:"author"`
+	Canonical []greaderLink `json:"canonical"`
+	Alternate []greaderLink `json:"alternate"`
+	Categories []string `json:"categories"`
+	Origin struct {
+		StreamID string `json:"streamId"`
+		Title string `json:"title"`
+		HTMLURL string `json:"htmlUrl"`
+	} `json:"origin"`
+	Summary struct {
+		Direction string `json:"direction"`
+		Content string `json:"content"`
+	} `json:"summary"`
+}
+
+type greaderContents struct {
+	Direction string `json:"direction"`
+	ID string `json:"id"`
+	Title string `json:"title"`
+	Author string `json:"author"`
+	Updated int64 `json:"updated"`
+	Items []greaderItem `json:"items"`
+	Continuation string `json:"continuation,omitempty"`
+}
+
+func newGReaderContents(user database.User, stream string, items []database.GetItemsForUserRow, continuation string) greaderContents {
+	contents := greaderContents{
+		Direction: "ltr",
+		ID: stream,
+		Title: "gator - " + user.Name,
+		Author: user.Name,
+		Updated: time.Now().Unix(),
+		Items: []greaderItem{},
+		Continuation: continuation,
+	}
+
+	for _, item := range items {
+		categories := []string{streamReadingList, streamFeedPrefix + item.FeedUrl}
+		if item.ReadAt.Valid {
+			categories = append(categories, streamRead)
+		}
+		if item.StarredAt.Valid {
+			categories = append(categories, streamStarred)
+		}
+
+		entry := greaderItem{
+			ID: fmt.Sprintf("%v%016x", greaderItemPrefix, item.Seq),
+			CrawlTimeMsec: strconv.FormatInt(item.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: usec(item.CreatedAt),
+			Published: item.PublishedAt.Unix(),
+			Updated: item.PublishedAt.Unix(),
+			Title: item.Title,
+			Canonical: []greaderLink{{Href: item.Url}},
+			Alternate: []greaderLink{{Href: item.Url, Type: "text/html"}},
+			Categories: categories,
+		}
+		entry.Origin.StreamID = streamFeedPrefix + item.FeedUrl
+		entry.Origin.Title = item.FeedName
+		entry.Origin.HTMLURL = item.FeedUrl
+		entry.Summary.Direction = "ltr"
+		entry.Summary.Content = itemHTML(item)
+
+		contents.Items = append(contents.Items, entry)
+	}
+	return contents
+}
+
+//usec formats a time as the microsecond timestamps Google Reader uses
+func usec(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//greaderClient returns a function making Google Reader API calls with the
+//auth key against a server on e's database, failing unless they succeed
+func (e *testEnv) greaderClient(key string) func(method, path string, form url.Values, v any) {
+	server := httptest.NewServer((&apiConfig{s: e.s}).routes())
+	e.t.Cleanup(server.Close)
+	return func(method, path string, form url.Values, v any) {
+		e.t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "GoogleLogin auth="+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			e.t.Fatalf("%v %v: got %v", method, path, resp.Status)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				e.t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestGReaderLogin(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.input("correct horse", "correct horse")
+	e.mustRun("app-password")
+
+	server := httptest.NewServer((&apiConfig{s: e.s}).routes())
+	t.Cleanup(server.Close)
+	login := server.URL + "/accounts/ClientLogin"
+	form := url.Values{"Email": {"alice"}, "Passwd": {"correct horse"}}
+
+	resp, err := http.PostForm(login, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("logging in: got %v, want 200", resp.Status)
+	}
+
+	resp, err = http.PostForm(login, url.Values{"Email": {"alice"}, "Passwd": {"wrong password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("logging in with the wrong password: got %v, want 401", resp.Status)
+	}
+
+	//credentials in the url are refused, whatever the method
+	resp, err = http.Get(login + "?" + form.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("logging in with GET succeeded")
+	}
+	resp, err = http.Post(login+"?"+form.Encode(), "application/x-www-form-urlencoded", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("logging in with the password in the url: got %v, want 401", resp.Status)
+	}
+}
+
+func TestGReaderLabelsAreFolders(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("move", "blog", "tech")
+	e.input("correct horse", "correct horse")
+	e.mustRun("app-password")
+
+	send := e.greaderClient(appPasswordKey("alice", "correct horse"))
+	labels := func() map[string]string {
+		t.Helper()
+		var list struct {
+			Subscriptions []struct {
+				ID         string `json:"id"`
+				Categories []struct {
+					ID    string `json:"id"`
+					Label string `json:"label"`
+				} `json:"categories"`
+			} `json:"subscriptions"`
+		}
+		send(http.MethodGet, "/reader/api/0/subscription/list?output=json", nil, &list)
+		labels := map[string]string{}
+		for _, sub := range list.Subscriptions {
+			for _, c := range sub.Categories {
+				labels[sub.ID] = c.ID
+			}
+		}
+		return labels
+	}
+
+	want := map[string]string{"feed/http://example.com/feed": "user/-/label/tech"}
+	if got := labels(); !maps.Equal(got, want) {
+		t.Fatalf("the subscriptions have labels %v, want %v", got, want)
+	}
+	var tags struct {
+		Tags []struct {
+			ID string `json:"id"`
+		} `json:"tags"`
+	}
+	send(http.MethodGet, "/reader/api/0/tag/list?output=json", nil, &tags)
+	if len(tags.Tags) != 2 || tags.Tags[1].ID != "user/-/label/tech" {
+		t.Fatalf("the tags are %+v, want starred and the tech label", tags.Tags)
+	}
+
+	//labelling a feed in the app moves it to that folder, made if needed
+	send(http.MethodPost, "/reader/api/0/subscription/edit", url.Values{
+		"ac": {"edit"},
+		"s":  {"feed/http://example.com/news"},
+		"a":  {"user/1234/label/world"},
+	}, nil)
+	send(http.MethodPost, "/reader/api/0/subscription/edit", url.Values{
+		"ac": {"edit"},
+		"s":  {"feed/http://example.com/feed"},
+		"r":  {"user/-/label/tech"},
+	}, nil)
+	want = map[string]string{"feed/http://example.com/news": "user/-/label/world"}
+	if got := labels(); !maps.Equal(got, want) {
+		t.Fatalf("after editing the subscriptions have labels %v, want %v", got, want)
+	}
+}
+
+func TestGReaderItems(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "bob")
+	e.mustRun("addfeed", "other", "http://example.com/other")
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("first", now.Add(-2*time.Hour)), testItem("second", now.Add(-time.Hour)))
+	e.fetch("http://example.com/news", testItem("headline", now))
+	e.fetch("http://example.com/other", testItem("elsewhere", now))
+	e.input("correct horse", "correct horse")
+	e.mustRun("app-password")
+	send := e.greaderClient(appPasswordKey("alice", "correct horse"))
+
+	contents := func(path string) ([]string, string) {
+		t.Helper()
+		var c greaderContents
+		send(http.MethodGet, path, nil, &c)
+		titles := []string{}
+		for _, item := range c.Items {
+			titles = append(titles, item.Title)
+		}
+		return titles, c.Continuation
+	}
+	stream := func(id string) string {
+		return "/reader/api/0/stream/contents/" + url.PathEscape(id)
+	}
+
+	//the reading list pages from newest to oldest with continuations
+	titles, c := contents(stream(streamReadingList) + "?n=2")
+	if want := []string{"headline", "second"}; !slices.Equal(titles, want) || c == "" {
+		t.Fatalf("the first page is %v (continuation %q), want %v and more", titles, c, want)
+	}
+	if titles, c = contents(stream(streamReadingList) + "?n=2&c=" + c); !slices.Equal(titles, []string{"first"}) || c != "" {
+		t.Fatalf("the second page is %v (continuation %q), want only first", titles, c)
+	}
+	if titles, _ = contents(stream("feed/http://example.com/feed") + "?r=o"); !slices.Equal(titles, []string{"first", "second"}) {
+		t.Fatalf("blog oldest first is %v", titles)
+	}
+
+	var refs struct {
+		ItemRefs []struct {
+			ID string `json:"id"`
+		} `json:"itemRefs"`
+	}
+	send(http.MethodGet, "/reader/api/0/stream/items/ids?s="+url.QueryEscape(streamReadingList)+"&n=10", nil, &refs)
+	if len(refs.ItemRefs) != 3 {
+		t.Fatalf("the reading list has %v ids, want 3", len(refs.ItemRefs))
+	}
+	seq, err := strconv.ParseInt(refs.ItemRefs[0].ID, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	longID := fmt.Sprintf("%v%016x", greaderItemPrefix, seq)
+
+	//items are looked up by their long or short ids
+	var c2 greaderContents
+	send(http.MethodPost, "/reader/api/0/stream/items/contents", url.Values{"i": {longID, refs.ItemRefs[1].ID}}, &c2)
+	if len(c2.Items) != 2 || c2.Items[0].ID != longID {
+		t.Fatalf("the items contents are %+v", c2.Items)
+	}
+
+	send(http.MethodPost, "/reader/api/0/edit-tag", url.Values{"i": {longID}, "a": {"user/-/state/com.google/read", "user/1234/state/com.google/starred"}}, nil)
+	if titles, _ = contents(stream(streamReadingList) + "?xt=" + url.QueryEscape(streamRead)); !slices.Equal(titles, []string{"second", "first"}) {
+		t.Fatalf("the unread items are %v after reading headline", titles)
+	}
+	if titles, _ = contents(stream(streamStarred)); !slices.Equal(titles, []string{"headline"}) {
+		t.Fatalf("the starred items are %v, want headline", titles)
+	}
+
+	send(http.MethodPost, "/reader/api/0/mark-all-as-read", url.Values{"s": {"feed/http://example.com/feed"}}, nil)
+	var counts struct {
+		UnreadCounts []struct {
+			ID    string `json:"id"`
+			Count int64  `json:"count"`
+		} `json:"unreadcounts"`
+	}
+	send(http.MethodGet, "/reader/api/0/unread-count?output=json", nil, &counts)
+	for _, count := range counts.UnreadCounts {
+		if count.Count != 0 {
+			t.Errorf("%v has %v unread items after reading everything", count.ID, count.Count)
+		}
+	}
+	if len(counts.UnreadCounts) != 3 {
+		t.Errorf("the unread counts are %+v, want blog, news and the reading list", counts.UnreadCounts)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+//programName is the name the binary was invoked with, used in usage lines
+var programName = filepath.Base(os.Args[0])
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		c.printHelp(os.Stdout)
+		return nil
+	}
+
+	spec, exists := c.commands[cmd.args[0]]
+	if !exists {
+		if suggestion := c.suggest(cmd.args[0]); suggestion != "" {
+			return fmt.Errorf("Unknown command %q, did you mean %q?", cmd.args[0], suggestion)
+		}
+		return fmt.Errorf("Unknown command %q", cmd.args[0])
+	}
+
+	spec, rest := resolveSubcommand(spec, cmd.args[1:])
+	if len(rest) > 0 {
+		return fmt.Errorf("Unknown subcommand %q of %v", rest[0], spec.name)
+	}
+
+	c.printCommandHelp(os.Stdout, spec)
+	return nil
+}
+
+//printHelp lists every visible command with its summary
+func (c *commands) printHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %v <command> [flags] [arguments]\n\n", programName)
+	fmt.Fprintln(w, "Commands:")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, spec := range c.sorted() {
+		summary := spec.summary
+		if spec.loginRequired {
+			summary += " (login required)"
+		}
+		fmt.Fprintf(tw, "  %v\t%v\n", spec.name, summary)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nRun \"%v help <command>\" for details on a command.\n", programName)
+}
+
+//printCommandHelp prints the usage, summary and flags of a single command
+func (c *commands) printCommandHelp(w io.Writer, spec commandSpec) {
+	fmt.Fprintf(w, "Usage: %v\n\n", spec.usageLine())
+	fmt.Fprintln(w, spec.summary)
+	if spec.loginRequired {
+		fmt.Fprintln(w, "Requires a logged in user.")
+	}
+
+	if len(spec.subcommands) > 0 {
+		fmt.Fprintln(w, "\nSubcommands:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for i, name := range spec.subcommandNames() {
+			fmt.Fprintf(tw, "  %v\t%v\n", name, spec.subcommands[i].summary)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "\nRun \"%v help %v <subcommand>\" for details on a subcommand.\n", programName, spec.name)
+		return
+	}
+
+	fs := spec.flagSet()
+	hasFlags := false
+	fs.VisitAll(func(_ *flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+func (c *commands) sorted() []commandSpec {
+	specs := []commandSpec{}
+	for _, spec := range c.commands {
+		if spec.hidden {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].name < specs[j].name
+	})
+	return specs
+}
+
+//suggest returns the registered command closest to name, or "" if nothing
+//is close enough to be a likely typo
+func (c *commands) suggest(name string) string {
+	names := []string{}
+	for _, spec := range c.sorted() {
+		names = append(names, spec.name)
+	}
+	return suggestName(name, names)
+}
+
+func suggestName(name string, candidates []string) string {
+	best := ""
+	bestDistance := 0
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, name) && len(name) > 1 {
+			return candidate
+		}
+		d := levenshtein(name, candidate)
+		if best == "" || d < bestDistance {
+			best = candidate
+			bestDistance = d
+		}
+	}
+
+	if bestDistance > 2 && bestDistance > len(name)/2 {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -9,12 +9,47 @@ import (
 const configFileName = ".gatorconfig.json"
 
 type Config struct {
-	DBURL           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DBURL           string     `json:"db_url"`
+	CurrentUserName string     `json:"current_user_name"`
+	SessionToken    string     `json:"session_token,omitempty"`
+	SMTP            *SMTP      `json:"smtp,omitempty"`
+	Retention       *Retention `json:"retention,omitempty"`
 }
 
+// SMTP is the mail server digests are sent through.
+type SMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+	// TLS is "starttls" (the default, used when the server offers it),
+	// "tls" for servers that expect TLS from the start, or "none".
+	TLS string `json:"tls,omitempty"`
+}
+
+// Retention limits the posts kept by the prune command, and by agg --prune.
+// Posts someone starred are always kept.
+type Retention struct {
+	// MaxAge is how long after they're published posts are kept, as a
+	// duration ("720h") or a number of days ("90d").
+	MaxAge string `json:"max_age,omitempty"`
+	// MaxPostsPerFeed keeps only that many of each feed's newest posts.
+	MaxPostsPerFeed int `json:"max_posts_per_feed,omitempty"`
+}
+
+// SetUser logs in a passwordless user by name.
 func (cfg *Config) SetUser(userName string) error {
 	cfg.CurrentUserName = userName
+	cfg.SessionToken = ""
+	return write(*cfg)
+}
+
+// SetSession logs in with a session token, used for password-protected
+// users instead of the plain user name.
+func (cfg *Config) SetSession(token string) error {
+	cfg.CurrentUserName = ""
+	cfg.SessionToken = token
 	return write(*cfg)
 }
 
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrUniqueViolation is wrapped by Querier implementations other than
+// Queries when a unique constraint fails, so IsUniqueViolation works with
+// every backend.
+var ErrUniqueViolation = errors.New("unique constraint violated")
+
+// IsUniqueViolation reports whether err was caused by a unique constraint,
+// such as registering a name or url that is already taken.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return errors.Is(err, ErrUniqueViolation)
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,10 +23,10 @@ WITH inserted_feed_follow AS (
     $4,
     $5
   )
-  RETURNING id, created_at, updated_at, user_id, feed_id
+  RETURNING id, created_at, updated_at, user_id, feed_id, title, muted, priority, notify, sort_order
 )
 SELECT 
-  inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+  inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.title, inserted_feed_follow.muted, inserted_feed_follow.priority, inserted_feed_follow.notify, inserted_feed_follow.sort_order,
   feeds.name as feed_name,
   users.name as user_name
 FROM inserted_feed_follow
@@ -49,6 +50,11 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Muted     bool
+	Priority  int32
+	Notify    string
+	SortOrder string
 	FeedName  string
 	UserName  string
 }
@@ -68,6 +74,11 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Title,
+		&i.Muted,
+		&i.Priority,
+		&i.Notify,
+		&i.SortOrder,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -76,25 +87,39 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT 
-  feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
+  feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.title, feed_follows.muted, feed_follows.priority, feed_follows.notify, feed_follows.sort_order,
   feeds.name as feed_name,
-  users.name as user_name
+  feeds.url as feed_url,
+  users.name as user_name,
+  folders.name as folder_name
 From feed_follows
 INNER JOIN feeds
   ON feed_follows.feed_id = feeds.id
 INNER JOIN users
   ON feed_follows.user_id = users.id
+LEFT JOIN folder_follows
+  ON folder_follows.feed_follow_id = feed_follows.id
+LEFT JOIN folders
+  ON folder_follows.folder_id = folders.id
 WHERE $1 = feed_follows.user_id
+ORDER BY feed_follows.priority DESC, feeds.name
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	Title      sql.NullString
+	Muted      bool
+	Priority   int32
+	Notify     string
+	SortOrder  string
+	FeedName   string
+	FeedUrl    string
+	UserName   string
+	FolderName sql.NullString
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -112,8 +137,15 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Title,
+			&i.Muted,
+			&i.Priority,
+			&i.Notify,
+			&i.SortOrder,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserName,
+			&i.FolderName,
 		); err != nil {
 			return nil, err
 		}
@@ -143,3 +175,40 @@ func (q *Queries) Unfollow(ctx context.Context, arg UnfollowParams) error {
 	_, err := q.db.ExecContext(ctx, unfollow, arg.UserID, arg.FeedID)
 	return err
 }
+
+const updateFollowSettings = `-- name: UpdateFollowSettings :exec
+UPDATE feed_follows
+SET title = $3,
+    muted = $4,
+    priority = $5,
+    notify = $6,
+    sort_order = $7,
+    updated_at = $8
+WHERE user_id = $1
+AND feed_id = $2
+`
+
+type UpdateFollowSettingsParams struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Title     sql.NullString
+	Muted     bool
+	Priority  int32
+	Notify    string
+	SortOrder string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFollowSettings(ctx context.Context, arg UpdateFollowSettingsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFollowSettings,
+		arg.UserID,
+		arg.FeedID,
+		arg.Title,
+		arg.Muted,
+		arg.Priority,
+		arg.Notify,
+		arg.SortOrder,
+		arg.UpdatedAt,
+	)
+	return err
+}
@@ -23,7 +23,7 @@ VALUES (
   $5,
   $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, seq
 `
 
 type CreateFeedParams struct {
@@ -53,12 +53,38 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+// follows, posts and the rules and webhooks scoped to the feed cascade
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeeds = `-- name: DeleteFeeds :execrows
+DELETE FROM feeds
+WHERE $1::uuid IS NULL OR user_id = $1
+`
+
+// every feed, or the feeds the user added; follows and posts cascade
+func (q *Queries) DeleteFeeds(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeeds, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq FROM feeds
 WHERE $1 = feeds.url
 `
 
@@ -73,12 +99,64 @@ func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
 	)
 	return i, err
 }
 
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const getFeedFollowerNames = `-- name: GetFeedFollowerNames :many
+SELECT users.name FROM feed_follows
+INNER JOIN users ON feed_follows.user_id = users.id
+WHERE feed_follows.feed_id = $1
+ORDER BY users.name
+`
+
+func (q *Queries) GetFeedFollowerNames(ctx context.Context, feedID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowerNames, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq FROM feeds
 ORDER BY name
 `
 
@@ -99,6 +177,46 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsForUser = `-- name: GetFeedsForUser :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.seq FROM feeds
+INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name
+`
+
+func (q *Queries) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
 		); err != nil {
 			return nil, err
 		}
@@ -114,7 +232,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq FROM feeds
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1
 `
@@ -130,6 +248,7 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
 	)
 	return i, err
 }
@@ -150,3 +269,58 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.ID)
 	return err
 }
+
+const setFeedOwner = `-- name: SetFeedOwner :exec
+UPDATE feeds
+SET user_id = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedOwnerParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedOwner(ctx context.Context, arg SetFeedOwnerParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedOwner, arg.ID, arg.UserID, arg.UpdatedAt)
+	return err
+}
+
+const updateFeed = `-- name: UpdateFeed :one
+UPDATE feeds
+SET name = $2,
+    url = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, seq
+`
+
+type UpdateFeedParams struct {
+	ID        uuid.UUID
+	Name      string
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFeed(ctx context.Context, arg UpdateFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeed,
+		arg.ID,
+		arg.Name,
+		arg.Url,
+		arg.UpdatedAt,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Seq,
+	)
+	return i, err
+}
@@ -11,6 +11,34 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scope      string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type AppPassword struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	KeyHash   string
+}
+
+type DigestSubscription struct {
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Email      string
+	LastSentAt sql.NullTime
+}
+
 type Feed struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
@@ -19,6 +47,7 @@ type Feed struct {
 	Url           string
 	UserID        uuid.UUID
 	LastFetchedAt sql.NullTime
+	Seq           int64
 }
 
 type FeedFollow struct {
@@ -27,6 +56,30 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Muted     bool
+	Priority  int32
+	Notify    string
+	SortOrder string
+}
+
+type FeedToken struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	TokenHash string
+}
+
+type Folder struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type FolderFollow struct {
+	FeedFollowID uuid.UUID
+	FolderID     uuid.UUID
 }
 
 type Post struct {
@@ -38,11 +91,74 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     sql.NullString
+	Search      interface{}
+	Seq         int64
+	Author      sql.NullString
+	Categories  []string
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+	HiddenAt  sql.NullTime
+}
+
+type PostTag struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Tag    string
+}
+
+type Rule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Match     string
+	Pattern   string
+	Action    string
+	Tag       sql.NullString
+}
+
+type Session struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
 }
 
 type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+}
+
+type Webhook struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Name      string
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	FeedID    uuid.NullUUID
+	Keyword   sql.NullString
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.UUID
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastAttemptAt sql.NullTime
+	ResponseCode  sql.NullInt32
+	Error         sql.NullString
 }
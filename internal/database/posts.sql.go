@@ -11,8 +11,23 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPostsForUser = `-- name: CountPostsForUser :one
+SELECT count(*)
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1
+`
+
+func (q *Queries) CountPostsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts (
   id, 
@@ -22,7 +37,10 @@ INSERT INTO posts (
   url, 
   description, 
   published_at, 
-  feed_id
+  feed_id,
+  content,
+  author,
+  categories
 )
 VALUES (
   $1,
@@ -32,9 +50,13 @@ VALUES (
   $5,
   $6,
   $7,
-  $8
+  $8,
+  $9,
+  $10,
+  $11
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content, search, seq, author, categories
 `
 
 type CreatePostParams struct {
@@ -46,8 +68,12 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     sql.NullString
+	Author      sql.NullString
+	Categories  []string
 }
 
+// a post whose url is already saved returns no rows
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.ID,
@@ -58,6 +84,9 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
 	)
 	var i Post
 	err := row.Scan(
@@ -69,33 +98,233 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.Search,
+		&i.Seq,
+		&i.Author,
+		pq.Array(&i.Categories),
 	)
 	return i, err
 }
 
+const deletePosts = `-- name: DeletePosts :execrows
+DELETE FROM posts
+WHERE $1::uuid IS NULL OR feed_id IN (
+  SELECT id FROM feeds WHERE user_id = $1
+)
+`
+
+// every post, or the posts of the feeds the user added
+func (q *Queries) DeletePosts(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePosts, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getItemsForUser = `-- name: GetItemsForUser :many
+SELECT
+  p.id,
+  p.seq,
+  p.title,
+  p.url,
+  p.description,
+  p.content,
+  p.published_at,
+  p.created_at,
+  p.feed_id,
+  feeds.seq AS feed_seq,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+AND ps.hidden_at IS NULL
+AND ($2::uuid IS NULL OR p.feed_id = $2)
+AND (NOT $3::boolean OR ps.read_at IS NULL)
+AND (NOT $4::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT $5::boolean OR ff.notify IN ('all', 'digest'))
+AND ($6::bigint[] IS NULL OR p.seq = ANY($6::bigint[]))
+AND ($7::bigint IS NULL OR p.seq > $7)
+AND ($8::bigint IS NULL OR p.seq < $8)
+AND ($9::timestamp IS NULL OR p.created_at >= $9)
+AND ($10::timestamp IS NULL OR p.created_at < $10)
+ORDER BY CASE WHEN $11::boolean THEN p.seq ELSE -p.seq END
+LIMIT $12
+`
+
+type GetItemsForUserParams struct {
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	UnreadOnly  bool
+	StarredOnly bool
+	ForDigest   bool
+	Seqs        []int64
+	AfterSeq    sql.NullInt64
+	BeforeSeq   sql.NullInt64
+	NewerThan   sql.NullTime
+	OlderThan   sql.NullTime
+	OldestFirst bool
+	Limit       int32
+}
+
+type GetItemsForUserRow struct {
+	ID          uuid.UUID
+	Seq         int64
+	Title       string
+	Url         string
+	Description sql.NullString
+	Content     sql.NullString
+	PublishedAt time.Time
+	CreatedAt   time.Time
+	FeedID      uuid.UUID
+	FeedSeq     int64
+	FeedName    string
+	FeedUrl     string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+}
+
+func (q *Queries) GetItemsForUser(ctx context.Context, arg GetItemsForUserParams) ([]GetItemsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getItemsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.ForDigest,
+		pq.Array(arg.Seqs),
+		arg.AfterSeq,
+		arg.BeforeSeq,
+		arg.NewerThan,
+		arg.OlderThan,
+		arg.OldestFirst,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetItemsForUserRow
+	for rows.Next() {
+		var i GetItemsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Seq,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.Content,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedID,
+			&i.FeedSeq,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.ReadAt,
+			&i.StarredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id
+SELECT
+  p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.search, p.seq, p.author, p.categories,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at,
+  array(
+    SELECT pt.tag FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id
+    ORDER BY pt.tag
+  )::text[] AS tags
 FROM posts p
 INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
 WHERE ff.user_id = $1
-ORDER BY p.published_at DESC
-LIMIT $2
+AND ps.hidden_at IS NULL
+AND ($2::uuid IS NULL OR p.feed_id = $2)
+AND ($3::uuid IS NULL OR EXISTS (
+  SELECT 1 FROM folder_follows fo
+  WHERE fo.feed_follow_id = ff.id AND fo.folder_id = $3
+))
+-- muted feeds only show when asked for
+AND ($2::uuid IS NOT NULL OR NOT ff.muted)
+AND (NOT $4::boolean OR ps.read_at IS NULL)
+AND (NOT $5::boolean OR ps.starred_at IS NOT NULL)
+ORDER BY
+  ff.priority DESC,
+  CASE WHEN $6::boolean THEN p.published_at END,
+  p.published_at DESC
+LIMIT $7 OFFSET $8
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	FolderID    uuid.NullUUID
+	UnreadOnly  bool
+	StarredOnly bool
+	OldestFirst bool
+	Limit       int32
+	Offset      int32
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Content     sql.NullString
+	Search      interface{}
+	Seq         int64
+	Author      sql.NullString
+	Categories  []string
+	FeedName    string
+	FeedUrl     string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	Tags        []string
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.FolderID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.OldestFirst,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -105,6 +334,137 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.Search,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.ReadAt,
+			&i.StarredAt,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyNewPost = `-- name: NotifyNewPost :exec
+SELECT pg_notify('new_posts', $1::text)
+`
+
+func (q *Queries) NotifyNewPost(ctx context.Context, payload string) error {
+	_, err := q.db.ExecContext(ctx, notifyNewPost, payload)
+	return err
+}
+
+const pruneExcessPosts = `-- name: PruneExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+  SELECT ranked.id FROM (
+    SELECT p.id, row_number() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.seq DESC) AS position
+    FROM posts p
+  ) ranked
+  WHERE ranked.position > $1::bigint
+)
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+)
+`
+
+func (q *Queries) PruneExcessPosts(ctx context.Context, maxPosts int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneExcessPosts, maxPosts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pruneOldPosts = `-- name: PruneOldPosts :execrows
+DELETE FROM posts
+WHERE published_at < $1
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+)
+`
+
+func (q *Queries) PruneOldPosts(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneOldPosts, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+  p.id,
+  p.title,
+  p.url,
+  p.published_at,
+  feeds.name AS feed_name,
+  ts_rank(p.search, websearch_to_tsquery('english', $1)) AS rank,
+  ts_headline(
+    'english',
+    coalesce(p.content, p.description, p.title),
+    websearch_to_tsquery('english', $1),
+    'StartSel=**, StopSel=**, MaxWords=35, MinWords=15, MaxFragments=2'
+  ) AS snippet
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+WHERE ff.user_id = $2
+AND p.search @@ websearch_to_tsquery('english', $1)
+ORDER BY rank DESC, p.published_at DESC
+LIMIT $3
+`
+
+type SearchPostsForUserParams struct {
+	Query   string
+	UserID  uuid.UUID
+	MaxRows int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser, arg.Query, arg.UserID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -7,11 +7,47 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countRecords = `-- name: CountRecords :one
+SELECT
+  (SELECT count(*) FROM users
+   WHERE $1::uuid IS NULL OR users.id = $1) AS users,
+  (SELECT count(*) FROM feeds
+   WHERE $1::uuid IS NULL OR feeds.user_id = $1) AS feeds,
+  (SELECT count(*) FROM feed_follows ff
+   INNER JOIN feeds ON ff.feed_id = feeds.id
+   WHERE $1::uuid IS NULL OR feeds.user_id = $1) AS follows,
+  (SELECT count(*) FROM posts p
+   INNER JOIN feeds ON p.feed_id = feeds.id
+   WHERE $1::uuid IS NULL OR feeds.user_id = $1) AS posts
+`
+
+type CountRecordsRow struct {
+	Users   int64
+	Feeds   int64
+	Follows int64
+	Posts   int64
+}
+
+// counts everything, or when user_id is given the user and the feeds they
+// added with those feeds' follows and posts
+func (q *Queries) CountRecords(ctx context.Context, userID uuid.NullUUID) (CountRecordsRow, error) {
+	row := q.db.QueryRowContext(ctx, countRecords, userID)
+	var i CountRecordsRow
+	err := row.Scan(
+		&i.Users,
+		&i.Feeds,
+		&i.Follows,
+		&i.Posts,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, name)
 VALUES (
@@ -20,7 +56,7 @@ VALUES (
   $3,
   $4
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash
 `
 
 type CreateUserParams struct {
@@ -43,6 +79,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
 	)
 	return i, err
 }
@@ -56,8 +93,19 @@ func (q *Queries) DeleteRecords(ctx context.Context) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
+`
+
+// feeds the user added and everything else they own cascade
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name FROM users
+SELECT id, created_at, updated_at, name, password_hash FROM users
 WHERE name = $1 LIMIT 1
 `
 
@@ -69,6 +117,7 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
 	)
 	return i, err
 }
@@ -86,7 +135,7 @@ func (q *Queries) GetUserNameFromID(ctx context.Context, id uuid.UUID) (string,
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name FROM users
+SELECT id, created_at, updated_at, name, password_hash FROM users
 ORDER BY name
 `
 
@@ -104,6 +153,7 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.PasswordHash,
 		); err != nil {
 			return nil, err
 		}
@@ -117,3 +167,48 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const renameUser = `-- name: RenameUser :one
+UPDATE users
+SET name = $2,
+    updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, password_hash
+`
+
+type RenameUserParams struct {
+	ID        uuid.UUID
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) RenameUser(ctx context.Context, arg RenameUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, renameUser, arg.ID, arg.Name, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.PasswordHash,
+	)
+	return i, err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash sql.NullString
+	UpdatedAt    time.Time
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.PasswordHash, arg.UpdatedAt)
+	return err
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	key := database.ApiKey{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		KeyHash:   arg.KeyHash,
+		Scope:     arg.Scope,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	err := s.write(func(d *data) error {
+		if _, ok := d.APIKeys[arg.ID]; ok {
+			return uniqueViolation("api_keys_pkey")
+		}
+		for _, k := range d.APIKeys {
+			if k.KeyHash == arg.KeyHash {
+				return uniqueViolation("api_keys_key_hash_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("api_keys_user_id_fkey")
+		}
+		d.APIKeys[arg.ID] = key
+		return nil
+	})
+	if err != nil {
+		return database.ApiKey{}, err
+	}
+	return key, nil
+}
+
+func (s *Store) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	var keys []database.ApiKey
+	err := s.read(func(d *data) error {
+		all := values(d.APIKeys, func(a, b database.ApiKey) bool {
+			return a.CreatedAt.Before(b.CreatedAt)
+		})
+		for _, key := range all {
+			if key.UserID == userID {
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *Store) GetUserByAPIKey(ctx context.Context, keyHash string) (database.GetUserByAPIKeyRow, error) {
+	var row database.GetUserByAPIKeyRow
+	err := s.read(func(d *data) error {
+		for _, key := range d.APIKeys {
+			if key.KeyHash != keyHash {
+				continue
+			}
+			row = database.GetUserByAPIKeyRow{
+				User:      d.Users[key.UserID],
+				KeyID:     key.ID,
+				Scope:     key.Scope,
+				ExpiresAt: key.ExpiresAt,
+				RevokedAt: key.RevokedAt,
+			}
+			return nil
+		}
+		return sql.ErrNoRows
+	})
+	return row, err
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, arg database.RevokeAPIKeyParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		key, ok := d.APIKeys[arg.ID]
+		if !ok || key.UserID != arg.UserID || key.RevokedAt.Valid {
+			return nil
+		}
+		key.RevokedAt = arg.RevokedAt
+		key.UpdatedAt = arg.RevokedAt.Time
+		d.APIKeys[arg.ID] = key
+		count = 1
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, arg database.TouchAPIKeyParams) error {
+	return s.write(func(d *data) error {
+		key, ok := d.APIKeys[arg.ID]
+		if !ok {
+			return nil
+		}
+		key.LastUsedAt = arg.LastUsedAt
+		d.APIKeys[arg.ID] = key
+		return nil
+	})
+}
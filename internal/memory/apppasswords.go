@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) DeleteAppPassword(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.AppPasswords[userID]; ok {
+			delete(d.AppPasswords, userID)
+			count = 1
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) GetUserByAppPassword(ctx context.Context, keyHash string) (database.GetUserByAppPasswordRow, error) {
+	var row database.GetUserByAppPasswordRow
+	err := s.read(func(d *data) error {
+		for userID, password := range d.AppPasswords {
+			if password.KeyHash == keyHash {
+				row.User = d.Users[userID]
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return row, err
+}
+
+func (s *Store) SetAppPassword(ctx context.Context, arg database.SetAppPasswordParams) error {
+	return s.write(func(d *data) error {
+		for userID, password := range d.AppPasswords {
+			if password.KeyHash == arg.KeyHash && userID != arg.UserID {
+				return uniqueViolation("app_passwords_key_hash_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("app_passwords_user_id_fkey")
+		}
+		d.AppPasswords[arg.UserID] = database.AppPassword(arg)
+		return nil
+	})
+}
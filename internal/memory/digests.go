@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) DeleteDigestSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.DigestSubscriptions[userID]; ok {
+			delete(d.DigestSubscriptions, userID)
+			count = 1
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) GetDigestSubscription(ctx context.Context, userID uuid.UUID) (database.DigestSubscription, error) {
+	var subscription database.DigestSubscription
+	err := s.read(func(d *data) error {
+		sub, ok := d.DigestSubscriptions[userID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		subscription = sub
+		return nil
+	})
+	return subscription, err
+}
+
+func (s *Store) GetDigestSubscriptions(ctx context.Context) ([]database.GetDigestSubscriptionsRow, error) {
+	var rows []database.GetDigestSubscriptionsRow
+	err := s.read(func(d *data) error {
+		subscriptions := values(d.DigestSubscriptions, func(a, b database.DigestSubscription) bool {
+			return d.Users[a.UserID].Name < d.Users[b.UserID].Name
+		})
+		for _, sub := range subscriptions {
+			rows = append(rows, database.GetDigestSubscriptionsRow{
+				UserID:     sub.UserID,
+				CreatedAt:  sub.CreatedAt,
+				UpdatedAt:  sub.UpdatedAt,
+				Email:      sub.Email,
+				LastSentAt: sub.LastSentAt,
+				UserName:   d.Users[sub.UserID].Name,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) MarkDigestSent(ctx context.Context, arg database.MarkDigestSentParams) error {
+	return s.write(func(d *data) error {
+		sub, ok := d.DigestSubscriptions[arg.UserID]
+		if !ok {
+			return nil
+		}
+		sub.LastSentAt = arg.LastSentAt
+		d.DigestSubscriptions[arg.UserID] = sub
+		return nil
+	})
+}
+
+func (s *Store) SetDigestEmail(ctx context.Context, arg database.SetDigestEmailParams) (database.DigestSubscription, error) {
+	var subscription database.DigestSubscription
+	err := s.write(func(d *data) error {
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("digest_subscriptions_user_id_fkey")
+		}
+		sub, ok := d.DigestSubscriptions[arg.UserID]
+		if !ok {
+			sub = database.DigestSubscription{UserID: arg.UserID, CreatedAt: arg.CreatedAt}
+		}
+		sub.UpdatedAt = arg.CreatedAt
+		sub.Email = arg.Email
+		d.DigestSubscriptions[arg.UserID] = sub
+		subscription = sub
+		return nil
+	})
+	return subscription, err
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	var row database.CreateFeedFollowRow
+	err := s.write(func(d *data) error {
+		if _, ok := d.FeedFollows[arg.ID]; ok {
+			return uniqueViolation("feed_follows_pkey")
+		}
+		if d.following(arg.UserID, arg.FeedID) {
+			return uniqueViolation("feed_follows_user_id_feed_id_key")
+		}
+		user, ok := d.Users[arg.UserID]
+		if !ok {
+			return foreignKeyViolation("feed_follows_user_id_fkey")
+		}
+		feed, ok := d.Feeds[arg.FeedID]
+		if !ok {
+			return foreignKeyViolation("feed_follows_feed_id_fkey")
+		}
+
+		follow := followDefaults(database.FeedFollow{
+			ID:        arg.ID,
+			CreatedAt: arg.CreatedAt,
+			UpdatedAt: arg.UpdatedAt,
+			UserID:    arg.UserID,
+			FeedID:    arg.FeedID,
+		})
+		d.FeedFollows[arg.ID] = follow
+		row = database.CreateFeedFollowRow{
+			ID:        follow.ID,
+			CreatedAt: follow.CreatedAt,
+			UpdatedAt: follow.UpdatedAt,
+			UserID:    follow.UserID,
+			FeedID:    follow.FeedID,
+			Title:     follow.Title,
+			Muted:     follow.Muted,
+			Priority:  follow.Priority,
+			Notify:    follow.Notify,
+			SortOrder: follow.SortOrder,
+			FeedName:  feed.Name,
+			UserName:  user.Name,
+		}
+		return nil
+	})
+	return row, err
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	var rows []database.GetFeedFollowsForUserRow
+	err := s.read(func(d *data) error {
+		// ORDER BY priority DESC, feeds.name
+		follows := values(d.FeedFollows, func(a, b database.FeedFollow) bool {
+			if a.Priority != b.Priority {
+				return a.Priority > b.Priority
+			}
+			return d.Feeds[a.FeedID].Name < d.Feeds[b.FeedID].Name
+		})
+		for _, follow := range follows {
+			if follow.UserID != userID {
+				continue
+			}
+			feed := d.Feeds[follow.FeedID]
+			rows = append(rows, database.GetFeedFollowsForUserRow{
+				ID:         follow.ID,
+				CreatedAt:  follow.CreatedAt,
+				UpdatedAt:  follow.UpdatedAt,
+				UserID:     follow.UserID,
+				FeedID:     follow.FeedID,
+				Title:      follow.Title,
+				Muted:      follow.Muted,
+				Priority:   follow.Priority,
+				Notify:     follow.Notify,
+				SortOrder:  follow.SortOrder,
+				FeedName:   feed.Name,
+				FeedUrl:    feed.Url,
+				UserName:   d.Users[follow.UserID].Name,
+				FolderName: d.folderName(follow.ID),
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) Unfollow(ctx context.Context, arg database.UnfollowParams) error {
+	return s.write(func(d *data) error {
+		for id, follow := range d.FeedFollows {
+			if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+				d.deleteFeedFollow(id)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) UpdateFollowSettings(ctx context.Context, arg database.UpdateFollowSettingsParams) error {
+	return s.write(func(d *data) error {
+		for id, follow := range d.FeedFollows {
+			if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+				follow.Title = arg.Title
+				follow.Muted = arg.Muted
+				follow.Priority = arg.Priority
+				follow.Notify = arg.Notify
+				follow.SortOrder = arg.SortOrder
+				follow.UpdatedAt = arg.UpdatedAt
+				d.FeedFollows[id] = follow
+			}
+		}
+		return nil
+	})
+}
+
+// followDefaults fills in the column defaults of a follow's settings.
+func followDefaults(follow database.FeedFollow) database.FeedFollow {
+	if follow.Notify == "" {
+		follow.Notify = "all"
+	}
+	if follow.SortOrder == "" {
+		follow.SortOrder = "newest"
+	}
+	return follow
+}
+
+// displayName is coalesce(title, feeds.name): the follower's own title for
+// the feed if they set one.
+func displayName(follow database.FeedFollow, feed database.Feed) string {
+	if follow.Title.Valid {
+		return follow.Title.String
+	}
+	return feed.Name
+}
+
+// notifies reports whether the follow's new posts go out via (digest or
+// webhooks).
+func notifies(follow database.FeedFollow, via string) bool {
+	return follow.Notify == "all" || follow.Notify == via
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func feedsByName(a, b database.Feed) bool {
+	return a.Name < b.Name
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	var feed database.Feed
+	err := s.write(func(d *data) error {
+		if _, ok := d.Feeds[arg.ID]; ok {
+			return uniqueViolation("feeds_pkey")
+		}
+		for _, f := range d.Feeds {
+			if f.Url == arg.Url {
+				return uniqueViolation("feeds_url_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("feeds_user_id_fkey")
+		}
+
+		d.FeedSeq++
+		feed = database.Feed{
+			ID:        arg.ID,
+			CreatedAt: arg.CreatedAt,
+			UpdatedAt: arg.UpdatedAt,
+			Name:      arg.Name,
+			Url:       arg.Url,
+			UserID:    arg.UserID,
+			Seq:       d.FeedSeq,
+		}
+		d.Feeds[arg.ID] = feed
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return feed, nil
+}
+
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	return s.write(func(d *data) error {
+		d.deleteFeed(id)
+		return nil
+	})
+}
+
+// DeleteFeeds deletes every feed, or the feeds the user added, with their
+// follows and posts.
+func (s *Store) DeleteFeeds(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		for _, feed := range d.Feeds {
+			if addedBy(feed, userID) {
+				d.deleteFeed(feed.ID)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// addedBy reports whether the user added the feed, or true for every feed
+// when no user is given.
+func addedBy(feed database.Feed, userID uuid.NullUUID) bool {
+	return !userID.Valid || feed.UserID == userID.UUID
+}
+
+func (s *Store) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	var feed database.Feed
+	err := s.read(func(d *data) error {
+		for _, f := range d.Feeds {
+			if f.Url == url {
+				feed = f
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return feed, err
+}
+
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	var feed database.Feed
+	err := s.read(func(d *data) error {
+		f, ok := d.Feeds[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		feed = f
+		return nil
+	})
+	return feed, err
+}
+
+func (s *Store) GetFeedFollowerNames(ctx context.Context, feedID uuid.UUID) ([]string, error) {
+	var names []string
+	err := s.read(func(d *data) error {
+		for _, user := range values(d.Users, func(a, b database.User) bool {
+			return a.Name < b.Name
+		}) {
+			if d.following(user.ID, feedID) {
+				names = append(names, user.Name)
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	var feeds []database.Feed
+	err := s.read(func(d *data) error {
+		feeds = values(d.Feeds, feedsByName)
+		return nil
+	})
+	return nilIfEmpty(feeds), err
+}
+
+func (s *Store) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	var feeds []database.Feed
+	err := s.read(func(d *data) error {
+		for _, feed := range values(d.Feeds, feedsByName) {
+			if d.following(userID, feed.ID) {
+				feeds = append(feeds, feed)
+			}
+		}
+		return nil
+	})
+	return feeds, err
+}
+
+// GetNextFeedToFetch returns the feed fetched longest ago, never fetched
+// feeds first.
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	var feed database.Feed
+	err := s.read(func(d *data) error {
+		feeds := values(d.Feeds, func(a, b database.Feed) bool {
+			if a.LastFetchedAt.Valid != b.LastFetchedAt.Valid {
+				return !a.LastFetchedAt.Valid
+			}
+			if !a.LastFetchedAt.Time.Equal(b.LastFetchedAt.Time) {
+				return a.LastFetchedAt.Time.Before(b.LastFetchedAt.Time)
+			}
+			return a.Seq < b.Seq
+		})
+		if len(feeds) == 0 {
+			return sql.ErrNoRows
+		}
+		feed = feeds[0]
+		return nil
+	})
+	return feed, err
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	return s.write(func(d *data) error {
+		feed, ok := d.Feeds[arg.ID]
+		if !ok {
+			return nil
+		}
+		feed.LastFetchedAt = arg.LastFetchedAt
+		feed.UpdatedAt = arg.LastFetchedAt.Time
+		d.Feeds[arg.ID] = feed
+		return nil
+	})
+}
+
+func (s *Store) SetFeedOwner(ctx context.Context, arg database.SetFeedOwnerParams) error {
+	return s.write(func(d *data) error {
+		feed, ok := d.Feeds[arg.ID]
+		if !ok {
+			return nil
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("feeds_user_id_fkey")
+		}
+		feed.UserID = arg.UserID
+		feed.UpdatedAt = arg.UpdatedAt
+		d.Feeds[arg.ID] = feed
+		return nil
+	})
+}
+
+func (s *Store) UpdateFeed(ctx context.Context, arg database.UpdateFeedParams) (database.Feed, error) {
+	var feed database.Feed
+	err := s.write(func(d *data) error {
+		f, ok := d.Feeds[arg.ID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		for _, other := range d.Feeds {
+			if other.ID != arg.ID && other.Url == arg.Url {
+				return uniqueViolation("feeds_url_key")
+			}
+		}
+		f.Name = arg.Name
+		f.Url = arg.Url
+		f.UpdatedAt = arg.UpdatedAt
+		d.Feeds[arg.ID] = f
+		feed = f
+		return nil
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return feed, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) GetUserByFeedToken(ctx context.Context, tokenHash string) (database.GetUserByFeedTokenRow, error) {
+	var row database.GetUserByFeedTokenRow
+	err := s.read(func(d *data) error {
+		for userID, token := range d.FeedTokens {
+			if token.TokenHash == tokenHash {
+				row.User = d.Users[userID]
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return row, err
+}
+
+func (s *Store) SetFeedToken(ctx context.Context, arg database.SetFeedTokenParams) error {
+	return s.write(func(d *data) error {
+		for userID, token := range d.FeedTokens {
+			if token.TokenHash == arg.TokenHash && userID != arg.UserID {
+				return uniqueViolation("feed_tokens_token_hash_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("feed_tokens_user_id_fkey")
+		}
+		d.FeedTokens[arg.UserID] = database.FeedToken(arg)
+		return nil
+	})
+}
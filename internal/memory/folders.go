@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) ClearFollowFolder(ctx context.Context, arg database.ClearFollowFolderParams) error {
+	return s.write(func(d *data) error {
+		for id, follow := range d.FeedFollows {
+			if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+				delete(d.FolderFollows, id)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) CreateFolder(ctx context.Context, arg database.CreateFolderParams) (database.Folder, error) {
+	folder := database.Folder(arg)
+	err := s.write(func(d *data) error {
+		if _, ok := d.Folders[arg.ID]; ok {
+			return uniqueViolation("folders_pkey")
+		}
+		for _, other := range d.Folders {
+			if other.UserID == arg.UserID && other.Name == arg.Name {
+				return uniqueViolation("folders_user_id_name_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("folders_user_id_fkey")
+		}
+		d.Folders[arg.ID] = folder
+		return nil
+	})
+	if err != nil {
+		return database.Folder{}, err
+	}
+	return folder, nil
+}
+
+func (s *Store) DeleteFolder(ctx context.Context, id uuid.UUID) error {
+	return s.write(func(d *data) error {
+		d.deleteFolder(id)
+		return nil
+	})
+}
+
+func (s *Store) GetFolder(ctx context.Context, arg database.GetFolderParams) (database.Folder, error) {
+	var folder database.Folder
+	err := s.read(func(d *data) error {
+		for _, f := range d.Folders {
+			if f.UserID == arg.UserID && f.Name == arg.Name {
+				folder = f
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return folder, err
+}
+
+func (s *Store) GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFoldersForUserRow, error) {
+	var rows []database.GetFoldersForUserRow
+	err := s.read(func(d *data) error {
+		counts := map[uuid.UUID]int64{}
+		for _, folderFollow := range d.FolderFollows {
+			counts[folderFollow.FolderID]++
+		}
+
+		folders := values(d.Folders, func(a, b database.Folder) bool {
+			return a.Name < b.Name
+		})
+		for _, folder := range folders {
+			if folder.UserID != userID {
+				continue
+			}
+			rows = append(rows, database.GetFoldersForUserRow{
+				ID:        folder.ID,
+				CreatedAt: folder.CreatedAt,
+				UpdatedAt: folder.UpdatedAt,
+				UserID:    folder.UserID,
+				Name:      folder.Name,
+				FeedCount: counts[folder.ID],
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) RenameFolder(ctx context.Context, arg database.RenameFolderParams) error {
+	return s.write(func(d *data) error {
+		folder, ok := d.Folders[arg.ID]
+		if !ok {
+			return nil
+		}
+		for _, other := range d.Folders {
+			if other.ID != arg.ID && other.UserID == folder.UserID && other.Name == arg.Name {
+				return uniqueViolation("folders_user_id_name_key")
+			}
+		}
+		folder.Name = arg.Name
+		folder.UpdatedAt = arg.UpdatedAt
+		d.Folders[arg.ID] = folder
+		return nil
+	})
+}
+
+// SetFollowFolder puts the user's follow of the feed in the folder, moving
+// it out of the folder it was in; it affects no rows without a follow.
+func (s *Store) SetFollowFolder(ctx context.Context, arg database.SetFollowFolderParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.Folders[arg.FolderID]; !ok {
+			return foreignKeyViolation("folder_follows_folder_id_fkey")
+		}
+		for id, follow := range d.FeedFollows {
+			if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+				d.FolderFollows[id] = database.FolderFollow{
+					FeedFollowID: id,
+					FolderID:     arg.FolderID,
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (d *data) deleteFolder(id uuid.UUID) {
+	delete(d.Folders, id)
+	for followID, folderFollow := range d.FolderFollows {
+		if folderFollow.FolderID == id {
+			delete(d.FolderFollows, followID)
+		}
+	}
+}
+
+// folderName is the name of the folder the follow is in, if any.
+func (d *data) folderName(followID uuid.UUID) sql.NullString {
+	folderFollow, ok := d.FolderFollows[followID]
+	if !ok {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: d.Folders[folderFollow.FolderID].Name, Valid: true}
+}
+
+// inFolder reports whether the user's follow of the feed is in the folder,
+// always true without a folder to filter by.
+func (d *data) inFolder(userID, feedID uuid.UUID, folderID uuid.NullUUID) bool {
+	if !folderID.Valid {
+		return true
+	}
+	for id, follow := range d.FeedFollows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return d.FolderFollows[id].FolderID == folderID.UUID
+		}
+	}
+	return false
+}
@@ -0,0 +1,439 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+// followedPosts returns the posts of the feeds the user follows that they
+// haven't hidden, with the user's state for each.
+func (d *data) followedPosts(userID uuid.UUID, feedID uuid.NullUUID) []followedPost {
+	var posts []followedPost
+	for _, post := range d.Posts {
+		if feedID.Valid && post.FeedID != feedID.UUID {
+			continue
+		}
+		follow, ok := d.feedFollow(userID, post.FeedID)
+		if !ok {
+			continue
+		}
+		state, _ := d.postState(userID, post.ID)
+		if state.HiddenAt.Valid {
+			continue
+		}
+		posts = append(posts, followedPost{post: post, state: state, follow: follow})
+	}
+	return posts
+}
+
+type followedPost struct {
+	post   database.Post
+	state  database.PostState
+	follow database.FeedFollow
+}
+
+func (p followedPost) matches(unreadOnly, starredOnly bool) bool {
+	if unreadOnly && p.state.ReadAt.Valid {
+		return false
+	}
+	if starredOnly && !p.state.StarredAt.Valid {
+		return false
+	}
+	return true
+}
+
+func (s *Store) CountPostsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.read(func(d *data) error {
+		for _, post := range d.Posts {
+			if d.following(userID, post.FeedID) {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	var post database.Post
+	err := s.write(func(d *data) error {
+		if _, ok := d.Posts[arg.ID]; ok {
+			return uniqueViolation("posts_pkey")
+		}
+		// ON CONFLICT (url) DO NOTHING
+		for _, p := range d.Posts {
+			if p.Url == arg.Url {
+				return sql.ErrNoRows
+			}
+		}
+		if _, ok := d.Feeds[arg.FeedID]; !ok {
+			return foreignKeyViolation("posts_feed_id_fkey")
+		}
+
+		d.PostSeq++
+		post = database.Post{
+			ID:          arg.ID,
+			CreatedAt:   arg.CreatedAt,
+			UpdatedAt:   arg.UpdatedAt,
+			Title:       arg.Title,
+			Url:         arg.Url,
+			Description: arg.Description,
+			PublishedAt: arg.PublishedAt,
+			FeedID:      arg.FeedID,
+			Content:     arg.Content,
+			Seq:         d.PostSeq,
+			Author:      arg.Author,
+			Categories:  append([]string(nil), arg.Categories...),
+		}
+		d.Posts[arg.ID] = post
+		return nil
+	})
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// DeletePosts deletes every post, or the posts of the feeds the user added.
+func (s *Store) DeletePosts(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		for _, post := range d.Posts {
+			if addedBy(d.Feeds[post.FeedID], userID) {
+				d.deletePost(post.ID)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) GetItemsForUser(ctx context.Context, arg database.GetItemsForUserParams) ([]database.GetItemsForUserRow, error) {
+	var rows []database.GetItemsForUserRow
+	err := s.read(func(d *data) error {
+		var seqs map[int64]bool
+		if arg.Seqs != nil {
+			seqs = map[int64]bool{}
+			for _, seq := range arg.Seqs {
+				seqs[seq] = true
+			}
+		}
+
+		var posts []followedPost
+		for _, p := range d.followedPosts(arg.UserID, arg.FeedID) {
+			switch {
+			case !p.matches(arg.UnreadOnly, arg.StarredOnly):
+			case arg.ForDigest && !notifies(p.follow, "digest"):
+			case seqs != nil && !seqs[p.post.Seq]:
+			case arg.AfterSeq.Valid && p.post.Seq <= arg.AfterSeq.Int64:
+			case arg.BeforeSeq.Valid && p.post.Seq >= arg.BeforeSeq.Int64:
+			case arg.NewerThan.Valid && p.post.CreatedAt.Before(arg.NewerThan.Time):
+			case arg.OlderThan.Valid && !p.post.CreatedAt.Before(arg.OlderThan.Time):
+			default:
+				posts = append(posts, p)
+			}
+		}
+		sort.Slice(posts, func(i, j int) bool {
+			if arg.OldestFirst {
+				return posts[i].post.Seq < posts[j].post.Seq
+			}
+			return posts[i].post.Seq > posts[j].post.Seq
+		})
+		if len(posts) > int(arg.Limit) {
+			posts = posts[:arg.Limit]
+		}
+
+		for _, p := range posts {
+			feed := d.Feeds[p.post.FeedID]
+			rows = append(rows, database.GetItemsForUserRow{
+				ID:          p.post.ID,
+				Seq:         p.post.Seq,
+				Title:       p.post.Title,
+				Url:         p.post.Url,
+				Description: p.post.Description,
+				Content:     p.post.Content,
+				PublishedAt: p.post.PublishedAt,
+				CreatedAt:   p.post.CreatedAt,
+				FeedID:      p.post.FeedID,
+				FeedSeq:     feed.Seq,
+				FeedName:    displayName(p.follow, feed),
+				FeedUrl:     feed.Url,
+				ReadAt:      p.state.ReadAt,
+				StarredAt:   p.state.StarredAt,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	var rows []database.GetPostsForUserRow
+	err := s.read(func(d *data) error {
+		var posts []followedPost
+		for _, p := range d.followedPosts(arg.UserID, arg.FeedID) {
+			switch {
+			case !p.matches(arg.UnreadOnly, arg.StarredOnly):
+			case !d.inFolder(arg.UserID, p.post.FeedID, arg.FolderID):
+			// muted feeds only show when asked for
+			case p.follow.Muted && !arg.FeedID.Valid:
+			default:
+				posts = append(posts, p)
+			}
+		}
+		sort.Slice(posts, func(i, j int) bool {
+			if posts[i].follow.Priority != posts[j].follow.Priority {
+				return posts[i].follow.Priority > posts[j].follow.Priority
+			}
+			if !posts[i].post.PublishedAt.Equal(posts[j].post.PublishedAt) {
+				if arg.OldestFirst {
+					return posts[i].post.PublishedAt.Before(posts[j].post.PublishedAt)
+				}
+				return posts[i].post.PublishedAt.After(posts[j].post.PublishedAt)
+			}
+			return posts[i].post.Seq > posts[j].post.Seq
+		})
+		posts = page(posts, int(arg.Offset), int(arg.Limit))
+
+		for _, p := range posts {
+			feed := d.Feeds[p.post.FeedID]
+			tags := append([]string{}, d.PostTags[arg.UserID][p.post.ID]...)
+			sort.Strings(tags)
+			rows = append(rows, database.GetPostsForUserRow{
+				ID:          p.post.ID,
+				CreatedAt:   p.post.CreatedAt,
+				UpdatedAt:   p.post.UpdatedAt,
+				Title:       p.post.Title,
+				Url:         p.post.Url,
+				Description: p.post.Description,
+				PublishedAt: p.post.PublishedAt,
+				FeedID:      p.post.FeedID,
+				Content:     p.post.Content,
+				Seq:         p.post.Seq,
+				Author:      p.post.Author,
+				Categories:  p.post.Categories,
+				FeedName:    displayName(p.follow, feed),
+				FeedUrl:     feed.Url,
+				ReadAt:      p.state.ReadAt,
+				StarredAt:   p.state.StarredAt,
+				Tags:        tags,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// page returns the rows LIMIT limit OFFSET offset would.
+func page[V any](rows []V, offset, limit int) []V {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// NotifyNewPost does nothing: the memory store lives in a single process,
+// so there are no other servers to tell.
+func (s *Store) NotifyNewPost(ctx context.Context, payload string) error {
+	return nil
+}
+
+// PruneExcessPosts deletes all but the newest maxPosts posts of each feed,
+// keeping posts anyone starred.
+func (s *Store) PruneExcessPosts(ctx context.Context, maxPosts int64) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		byFeed := map[uuid.UUID][]database.Post{}
+		for _, post := range d.Posts {
+			byFeed[post.FeedID] = append(byFeed[post.FeedID], post)
+		}
+		for _, posts := range byFeed {
+			sort.Slice(posts, func(i, j int) bool {
+				if !posts[i].PublishedAt.Equal(posts[j].PublishedAt) {
+					return posts[i].PublishedAt.After(posts[j].PublishedAt)
+				}
+				return posts[i].Seq > posts[j].Seq
+			})
+			for i, post := range posts {
+				if int64(i) >= maxPosts && !d.starred(post.ID) {
+					d.deletePost(post.ID)
+					count++
+				}
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// PruneOldPosts deletes the posts published before before, keeping posts
+// anyone starred.
+func (s *Store) PruneOldPosts(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		for _, post := range d.Posts {
+			if post.PublishedAt.Before(before) && !d.starred(post.ID) {
+				d.deletePost(post.ID)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// starred reports whether any user starred the post.
+func (d *data) starred(postID uuid.UUID) bool {
+	for _, states := range d.PostStates {
+		if states[postID].StarredAt.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchPostsForUser matches posts containing every word of the query,
+// except words prefixed with -, which they must not contain. Quoted phrases
+// are matched as single words. Posts are ranked by how often the words
+// appear, so unlike the postgres search there's no stemming.
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	include, exclude := searchTerms(arg.Query)
+	if len(include) == 0 {
+		return nil, nil
+	}
+
+	var rows []database.SearchPostsForUserRow
+	err := s.read(func(d *data) error {
+		for _, post := range d.Posts {
+			if !d.following(arg.UserID, post.FeedID) {
+				continue
+			}
+			text := strings.ToLower(post.Title + " " + post.Description.String + " " + post.Content.String)
+			rank := 0
+			for _, term := range include {
+				count := strings.Count(text, term)
+				if count == 0 {
+					rank = 0
+					break
+				}
+				rank += count
+			}
+			for _, term := range exclude {
+				if strings.Contains(text, term) {
+					rank = 0
+				}
+			}
+			if rank == 0 {
+				continue
+			}
+
+			body := post.Title
+			if post.Description.Valid {
+				body = post.Description.String
+			}
+			if post.Content.Valid {
+				body = post.Content.String
+			}
+			rows = append(rows, database.SearchPostsForUserRow{
+				ID:          post.ID,
+				Title:       post.Title,
+				Url:         post.Url,
+				PublishedAt: post.PublishedAt,
+				FeedName:    d.Feeds[post.FeedID].Name,
+				Rank:        float32(rank),
+				Snippet:     snippet(body, include),
+			})
+		}
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Rank != rows[j].Rank {
+			return rows[i].Rank > rows[j].Rank
+		}
+		return rows[i].PublishedAt.After(rows[j].PublishedAt)
+	})
+	if len(rows) > int(arg.MaxRows) {
+		rows = rows[:arg.MaxRows]
+	}
+	return rows, err
+}
+
+// searchTerms splits a websearch style query into lower case words and
+// phrases to find and to exclude.
+func searchTerms(query string) (include, exclude []string) {
+	fields := strings.Split(query, `"`)
+	for i, field := range fields {
+		field = strings.ToLower(field)
+		// odd fields were between quotes
+		if i%2 == 1 {
+			if phrase := strings.Join(strings.Fields(field), " "); phrase != "" {
+				include = append(include, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(field) {
+			if word == "or" {
+				continue
+			}
+			if strings.HasPrefix(word, "-") {
+				if word = strings.TrimLeft(word, "-"); word != "" {
+					exclude = append(exclude, word)
+				}
+				continue
+			}
+			include = append(include, word)
+		}
+	}
+	return include, exclude
+}
+
+// snippetWords is roughly the fragment ts_headline returns.
+const snippetWords = 35
+
+// snippet returns up to snippetWords words of text around the first word
+// matching a term, with the matching words between **.
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	matching := func(word string) bool {
+		word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}))
+		for _, term := range terms {
+			if word != "" && strings.Contains(word, term) {
+				return true
+			}
+		}
+		return false
+	}
+
+	start := 0
+	for i, word := range words {
+		if matching(word) {
+			start = max(0, i-snippetWords/3)
+			break
+		}
+	}
+	end := min(len(words), start+snippetWords)
+
+	out := make([]string, 0, end-start)
+	for _, word := range words[start:end] {
+		if matching(word) {
+			word = "**" + word + "**"
+		}
+		out = append(out, word)
+	}
+	return strings.Join(out, " ")
+}
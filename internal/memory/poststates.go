@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) AddPostTag(ctx context.Context, arg database.AddPostTagParams) error {
+	return s.write(func(d *data) error {
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("post_tags_user_id_fkey")
+		}
+		if _, ok := d.Posts[arg.PostID]; !ok {
+			return foreignKeyViolation("post_tags_post_id_fkey")
+		}
+		if d.PostTags[arg.UserID] == nil {
+			d.PostTags[arg.UserID] = map[uuid.UUID][]string{}
+		}
+		tags := d.PostTags[arg.UserID][arg.PostID]
+		if !slices.Contains(tags, arg.Tag) {
+			d.PostTags[arg.UserID][arg.PostID] = append(tags, arg.Tag)
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetUnreadCountsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetUnreadCountsForUserRow, error) {
+	var rows []database.GetUnreadCountsForUserRow
+	err := s.read(func(d *data) error {
+		counts := map[uuid.UUID]int64{}
+		for _, post := range d.Posts {
+			if !d.following(userID, post.FeedID) {
+				continue
+			}
+			if state, _ := d.postState(userID, post.ID); !state.ReadAt.Valid {
+				counts[post.FeedID]++
+			}
+		}
+		for feedID, unread := range counts {
+			rows = append(rows, database.GetUnreadCountsForUserRow{FeedID: feedID, Unread: unread})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) HidePost(ctx context.Context, arg database.HidePostParams) error {
+	return s.writeState(arg.UserID, arg.PostID, func(state *database.PostState) {
+		state.HiddenAt = arg.HiddenAt
+		if !state.ReadAt.Valid {
+			state.ReadAt = arg.HiddenAt
+		}
+	})
+}
+
+// MarkPostsRead marks the user's posts created up to arg.Before read,
+// returning how many weren't read already.
+func (s *Store) MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		for _, post := range d.Posts {
+			if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+				continue
+			}
+			if post.CreatedAt.After(arg.Before) || !d.following(arg.UserID, post.FeedID) {
+				continue
+			}
+			if !d.inFolder(arg.UserID, post.FeedID, arg.FolderID) {
+				continue
+			}
+			state, ok := d.postState(arg.UserID, post.ID)
+			if ok && state.ReadAt.Valid {
+				continue
+			}
+			state.UserID = arg.UserID
+			state.PostID = post.ID
+			state.ReadAt = arg.ReadAt
+			d.setPostState(state)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) SetPostRead(ctx context.Context, arg database.SetPostReadParams) error {
+	return s.writeState(arg.UserID, arg.PostID, func(state *database.PostState) {
+		state.ReadAt = arg.ReadAt
+	})
+}
+
+func (s *Store) SetPostStarred(ctx context.Context, arg database.SetPostStarredParams) error {
+	return s.writeState(arg.UserID, arg.PostID, func(state *database.PostState) {
+		state.StarredAt = arg.StarredAt
+	})
+}
+
+// writeState upserts the user's state for the post.
+func (s *Store) writeState(userID, postID uuid.UUID, update func(state *database.PostState)) error {
+	return s.write(func(d *data) error {
+		if _, ok := d.Users[userID]; !ok {
+			return foreignKeyViolation("post_states_user_id_fkey")
+		}
+		if _, ok := d.Posts[postID]; !ok {
+			return foreignKeyViolation("post_states_post_id_fkey")
+		}
+		state, _ := d.postState(userID, postID)
+		state.UserID = userID
+		state.PostID = postID
+		update(&state)
+		d.setPostState(state)
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func rulesByCreation(a, b database.Rule) bool {
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+func (s *Store) CreateRule(ctx context.Context, arg database.CreateRuleParams) (database.Rule, error) {
+	rule := database.Rule(arg)
+	err := s.write(func(d *data) error {
+		if _, ok := d.Rules[arg.ID]; ok {
+			return uniqueViolation("rules_pkey")
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("rules_user_id_fkey")
+		}
+		if _, ok := d.Feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+			return foreignKeyViolation("rules_feed_id_fkey")
+		}
+		d.Rules[arg.ID] = rule
+		return nil
+	})
+	if err != nil {
+		return database.Rule{}, err
+	}
+	return rule, nil
+}
+
+func (s *Store) DeleteRule(ctx context.Context, arg database.DeleteRuleParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		rule, ok := d.Rules[arg.ID]
+		if !ok || rule.UserID != arg.UserID {
+			return nil
+		}
+		delete(d.Rules, arg.ID)
+		count = 1
+		return nil
+	})
+	return count, err
+}
+
+// GetRulesForFeed returns the rules of every user following the feed that
+// apply to it.
+func (s *Store) GetRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]database.Rule, error) {
+	var rules []database.Rule
+	err := s.read(func(d *data) error {
+		for _, rule := range values(d.Rules, rulesByCreation) {
+			if rule.FeedID.Valid && rule.FeedID.UUID != feedID {
+				continue
+			}
+			if d.following(rule.UserID, feedID) {
+				rules = append(rules, rule)
+			}
+		}
+		return nil
+	})
+	return rules, err
+}
+
+func (s *Store) GetRulesForUser(ctx context.Context, userID uuid.UUID) ([]database.GetRulesForUserRow, error) {
+	var rows []database.GetRulesForUserRow
+	err := s.read(func(d *data) error {
+		for _, rule := range values(d.Rules, rulesByCreation) {
+			if rule.UserID != userID {
+				continue
+			}
+			rows = append(rows, database.GetRulesForUserRow{
+				ID:        rule.ID,
+				CreatedAt: rule.CreatedAt,
+				UserID:    rule.UserID,
+				FeedID:    rule.FeedID,
+				Match:     rule.Match,
+				Pattern:   rule.Pattern,
+				Action:    rule.Action,
+				Tag:       rule.Tag,
+				FeedName:  d.feedName(rule.FeedID),
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func (s *Store) CreateSession(ctx context.Context, arg database.CreateSessionParams) (database.Session, error) {
+	session := database.Session(arg)
+	err := s.write(func(d *data) error {
+		if _, ok := d.Sessions[arg.ID]; ok {
+			return uniqueViolation("sessions_pkey")
+		}
+		for _, other := range d.Sessions {
+			if other.TokenHash == arg.TokenHash {
+				return uniqueViolation("sessions_token_hash_key")
+			}
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("sessions_user_id_fkey")
+		}
+		d.Sessions[arg.ID] = session
+		return nil
+	})
+	if err != nil {
+		return database.Session{}, err
+	}
+	return session, nil
+}
+
+func (s *Store) DeleteSession(ctx context.Context, tokenHash string) error {
+	return s.write(func(d *data) error {
+		for id, session := range d.Sessions {
+			if session.TokenHash == tokenHash {
+				delete(d.Sessions, id)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) DeleteSessionsForUser(ctx context.Context, userID uuid.UUID) error {
+	return s.write(func(d *data) error {
+		for id, session := range d.Sessions {
+			if session.UserID == userID {
+				delete(d.Sessions, id)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetUserBySession(ctx context.Context, tokenHash string) (database.GetUserBySessionRow, error) {
+	var row database.GetUserBySessionRow
+	err := s.read(func(d *data) error {
+		for _, session := range d.Sessions {
+			if session.TokenHash == tokenHash {
+				row = database.GetUserBySessionRow{
+					User:      d.Users[session.UserID],
+					ExpiresAt: session.ExpiresAt,
+				}
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return row, err
+}
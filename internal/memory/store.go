@@ -0,0 +1,397 @@
+// Package memory is a database.Querier kept in memory, for trying gator out
+// and demos without a database server. It follows the postgres queries'
+// semantics: the same filters, orderings, unique constraints and cascades.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+// Store holds every table in maps. With a path, the data is loaded from
+// that file and written back shortly after each change and on Close, so
+// separate commands see each other's changes; without one it lasts as long
+// as the process. Only one process should use a file at a time.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	data      *data
+	saveTimer *time.Timer
+	// inTx is set on the store InTx hands out, which runs with the lock
+	// already held.
+	inTx bool
+}
+
+var _ database.Querier = (*Store)(nil)
+
+// data is what the snapshot file holds, the tables keyed by primary key
+// (post states and tags by user then post, folder follows by feed follow).
+type data struct {
+	Users               map[uuid.UUID]database.User
+	Feeds               map[uuid.UUID]database.Feed
+	FeedFollows         map[uuid.UUID]database.FeedFollow
+	Posts               map[uuid.UUID]database.Post
+	PostStates          map[uuid.UUID]map[uuid.UUID]database.PostState
+	PostTags            map[uuid.UUID]map[uuid.UUID][]string
+	APIKeys             map[uuid.UUID]database.ApiKey
+	Sessions            map[uuid.UUID]database.Session
+	FeedTokens          map[uuid.UUID]database.FeedToken
+	AppPasswords        map[uuid.UUID]database.AppPassword
+	Webhooks            map[uuid.UUID]database.Webhook
+	WebhookDeliveries   map[uuid.UUID]database.WebhookDelivery
+	Rules               map[uuid.UUID]database.Rule
+	DigestSubscriptions map[uuid.UUID]database.DigestSubscription
+	Folders             map[uuid.UUID]database.Folder
+	FolderFollows       map[uuid.UUID]database.FolderFollow
+	FeedSeq             int64
+	PostSeq             int64
+}
+
+func newData() data {
+	return data{
+		Users:               map[uuid.UUID]database.User{},
+		Feeds:               map[uuid.UUID]database.Feed{},
+		FeedFollows:         map[uuid.UUID]database.FeedFollow{},
+		Posts:               map[uuid.UUID]database.Post{},
+		PostStates:          map[uuid.UUID]map[uuid.UUID]database.PostState{},
+		PostTags:            map[uuid.UUID]map[uuid.UUID][]string{},
+		APIKeys:             map[uuid.UUID]database.ApiKey{},
+		Sessions:            map[uuid.UUID]database.Session{},
+		FeedTokens:          map[uuid.UUID]database.FeedToken{},
+		AppPasswords:        map[uuid.UUID]database.AppPassword{},
+		Webhooks:            map[uuid.UUID]database.Webhook{},
+		WebhookDeliveries:   map[uuid.UUID]database.WebhookDelivery{},
+		Rules:               map[uuid.UUID]database.Rule{},
+		DigestSubscriptions: map[uuid.UUID]database.DigestSubscription{},
+		Folders:             map[uuid.UUID]database.Folder{},
+		FolderFollows:       map[uuid.UUID]database.FolderFollow{},
+	}
+}
+
+// Open returns an empty store, or the one saved at path if the file exists.
+func Open(path string) (*Store, error) {
+	d := newData()
+	s := &Store{path: path, data: &d}
+	if path == "" {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// tables the snapshot doesn't have yet stay empty
+	if err := json.Unmarshal(content, s.data); err != nil {
+		return nil, fmt.Errorf("%v isn't a memory database snapshot: %v", path, err)
+	}
+	// and columns it doesn't have yet take their defaults
+	for id, follow := range s.data.FeedFollows {
+		s.data.FeedFollows[id] = followDefaults(follow)
+	}
+	return s, nil
+}
+
+// read runs fn with the store locked.
+func (s *Store) read(fn func(d *data) error) error {
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.data)
+}
+
+// write runs fn with the store locked and schedules saving the snapshot.
+// fn must check everything that can fail before changing anything.
+func (s *Store) write(fn func(d *data) error) error {
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	if err := fn(s.data); err != nil {
+		return err
+	}
+	s.scheduleSave()
+	return nil
+}
+
+// InTx runs fn with a store whose changes are all undone if fn returns an
+// error. The store stays locked until fn returns, so fn must only use the
+// store it's given. Calling InTx on that store nests, undoing just the
+// inner fn's changes when it fails.
+func (s *Store) InTx(ctx context.Context, fn func(tx *Store) error) error {
+	tx := s
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		tx = &Store{data: s.data, inTx: true}
+	}
+
+	backup := s.data.clone()
+	if err := fn(tx); err != nil {
+		*s.data = backup
+		return err
+	}
+	s.scheduleSave()
+	return nil
+}
+
+// saveDelay batches the writes of a busy command, such as agg saving a
+// feed's posts, into one write of the snapshot.
+const saveDelay = time.Second
+
+func (s *Store) scheduleSave() {
+	// stores in a transaction have no path, InTx saves once it's done
+	if s.path == "" || s.saveTimer != nil {
+		return
+	}
+	s.saveTimer = time.AfterFunc(saveDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.saveTimer = nil
+		if err := s.save(); err != nil {
+			log.Printf("Error saving %v: %v", s.path, err)
+		}
+	})
+}
+
+// Close writes changes that haven't been saved yet.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveTimer == nil {
+		return nil
+	}
+	s.saveTimer.Stop()
+	s.saveTimer = nil
+	return s.save()
+}
+
+// Backup writes a snapshot of the store to path, which Open reads back.
+func (s *Store) Backup(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveTo(path)
+}
+
+func (s *Store) save() error {
+	return s.saveTo(s.path)
+}
+
+func (s *Store) saveTo(path string) error {
+	content, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	// write then rename, so a crash never leaves half a snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// clone copies the tables, so that changing the copy leaves d as it was.
+// Rows are always replaced rather than changed in place, so copying the
+// maps is enough.
+func (d *data) clone() data {
+	c := *d
+	c.Users = maps.Clone(d.Users)
+	c.Feeds = maps.Clone(d.Feeds)
+	c.FeedFollows = maps.Clone(d.FeedFollows)
+	c.Posts = maps.Clone(d.Posts)
+	c.PostStates = cloneNested(d.PostStates)
+	c.PostTags = cloneNested(d.PostTags)
+	c.APIKeys = maps.Clone(d.APIKeys)
+	c.Sessions = maps.Clone(d.Sessions)
+	c.FeedTokens = maps.Clone(d.FeedTokens)
+	c.AppPasswords = maps.Clone(d.AppPasswords)
+	c.Webhooks = maps.Clone(d.Webhooks)
+	c.WebhookDeliveries = maps.Clone(d.WebhookDeliveries)
+	c.Rules = maps.Clone(d.Rules)
+	c.DigestSubscriptions = maps.Clone(d.DigestSubscriptions)
+	c.Folders = maps.Clone(d.Folders)
+	c.FolderFollows = maps.Clone(d.FolderFollows)
+	return c
+}
+
+func cloneNested[V any](m map[uuid.UUID]map[uuid.UUID]V) map[uuid.UUID]map[uuid.UUID]V {
+	c := make(map[uuid.UUID]map[uuid.UUID]V, len(m))
+	for k, inner := range m {
+		c[k] = maps.Clone(inner)
+	}
+	return c
+}
+
+func uniqueViolation(constraint string) error {
+	return fmt.Errorf("%w: %v", database.ErrUniqueViolation, constraint)
+}
+
+func foreignKeyViolation(constraint string) error {
+	return fmt.Errorf("foreign key violated: %v", constraint)
+}
+
+// values returns the map's rows sorted with less.
+func values[V any](m map[uuid.UUID]V, less func(a, b V) bool) []V {
+	out := make([]V, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return less(out[i], out[j])
+	})
+	return out
+}
+
+// nilIfEmpty matches sqlc, which returns nil for queries without rows.
+func nilIfEmpty[V any](items []V) []V {
+	if len(items) == 0 {
+		return nil
+	}
+	return items
+}
+
+// following reports whether the user follows the feed.
+func (d *data) following(userID, feedID uuid.UUID) bool {
+	_, ok := d.feedFollow(userID, feedID)
+	return ok
+}
+
+// feedFollow is the user's follow of the feed, with their settings for it.
+func (d *data) feedFollow(userID, feedID uuid.UUID) (database.FeedFollow, bool) {
+	for _, follow := range d.FeedFollows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return follow, true
+		}
+	}
+	return database.FeedFollow{}, false
+}
+
+func (d *data) postState(userID, postID uuid.UUID) (database.PostState, bool) {
+	state, ok := d.PostStates[userID][postID]
+	return state, ok
+}
+
+func (d *data) setPostState(state database.PostState) {
+	if d.PostStates[state.UserID] == nil {
+		d.PostStates[state.UserID] = map[uuid.UUID]database.PostState{}
+	}
+	d.PostStates[state.UserID][state.PostID] = state
+}
+
+// deleteUser removes the user and everything that cascades from it.
+func (d *data) deleteUser(id uuid.UUID) {
+	delete(d.Users, id)
+	for feedID, feed := range d.Feeds {
+		if feed.UserID == id {
+			d.deleteFeed(feedID)
+		}
+	}
+	for followID, follow := range d.FeedFollows {
+		if follow.UserID == id {
+			d.deleteFeedFollow(followID)
+		}
+	}
+	delete(d.PostStates, id)
+	delete(d.PostTags, id)
+	for keyID, key := range d.APIKeys {
+		if key.UserID == id {
+			delete(d.APIKeys, keyID)
+		}
+	}
+	for sessionID, session := range d.Sessions {
+		if session.UserID == id {
+			delete(d.Sessions, sessionID)
+		}
+	}
+	delete(d.FeedTokens, id)
+	delete(d.AppPasswords, id)
+	for webhookID, webhook := range d.Webhooks {
+		if webhook.UserID == id {
+			d.deleteWebhook(webhookID)
+		}
+	}
+	for ruleID, rule := range d.Rules {
+		if rule.UserID == id {
+			delete(d.Rules, ruleID)
+		}
+	}
+	delete(d.DigestSubscriptions, id)
+	for folderID, folder := range d.Folders {
+		if folder.UserID == id {
+			d.deleteFolder(folderID)
+		}
+	}
+}
+
+func (d *data) deleteFeedFollow(id uuid.UUID) {
+	delete(d.FeedFollows, id)
+	delete(d.FolderFollows, id)
+}
+
+// deleteFeed removes the feed and everything that cascades from it.
+func (d *data) deleteFeed(id uuid.UUID) {
+	delete(d.Feeds, id)
+	for followID, follow := range d.FeedFollows {
+		if follow.FeedID == id {
+			d.deleteFeedFollow(followID)
+		}
+	}
+	for postID, post := range d.Posts {
+		if post.FeedID == id {
+			d.deletePost(postID)
+		}
+	}
+	for webhookID, webhook := range d.Webhooks {
+		if webhook.FeedID.Valid && webhook.FeedID.UUID == id {
+			d.deleteWebhook(webhookID)
+		}
+	}
+	for ruleID, rule := range d.Rules {
+		if rule.FeedID.Valid && rule.FeedID.UUID == id {
+			delete(d.Rules, ruleID)
+		}
+	}
+}
+
+func (d *data) deletePost(id uuid.UUID) {
+	delete(d.Posts, id)
+	for _, states := range d.PostStates {
+		delete(states, id)
+	}
+	for _, tags := range d.PostTags {
+		delete(tags, id)
+	}
+	for deliveryID, delivery := range d.WebhookDeliveries {
+		if delivery.PostID == id {
+			delete(d.WebhookDeliveries, deliveryID)
+		}
+	}
+}
+
+func (d *data) deleteWebhook(id uuid.UUID) {
+	delete(d.Webhooks, id)
+	for deliveryID, delivery := range d.WebhookDeliveries {
+		if delivery.WebhookID == id {
+			delete(d.WebhookDeliveries, deliveryID)
+		}
+	}
+}
+
+func validTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+// CountRecords counts everything, or the user and the feeds they added with
+// those feeds' follows and posts.
+func (s *Store) CountRecords(ctx context.Context, userID uuid.NullUUID) (database.CountRecordsRow, error) {
+	var counts database.CountRecordsRow
+	err := s.read(func(d *data) error {
+		for id := range d.Users {
+			if !userID.Valid || id == userID.UUID {
+				counts.Users++
+			}
+		}
+		for _, feed := range d.Feeds {
+			if addedBy(feed, userID) {
+				counts.Feeds++
+			}
+		}
+		for _, follow := range d.FeedFollows {
+			if feed, ok := d.Feeds[follow.FeedID]; ok && addedBy(feed, userID) {
+				counts.Follows++
+			}
+		}
+		for _, post := range d.Posts {
+			if feed, ok := d.Feeds[post.FeedID]; ok && addedBy(feed, userID) {
+				counts.Posts++
+			}
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user := database.User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+	}
+	err := s.write(func(d *data) error {
+		if _, ok := d.Users[arg.ID]; ok {
+			return uniqueViolation("users_pkey")
+		}
+		if d.userNamed(arg.Name) {
+			return uniqueViolation("users_name_key")
+		}
+		d.Users[arg.ID] = user
+		return nil
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func (s *Store) DeleteRecords(ctx context.Context) error {
+	return s.write(func(d *data) error {
+		for id := range d.Users {
+			d.deleteUser(id)
+		}
+		return nil
+	})
+}
+
+// DeleteUser deletes the user and, as the foreign keys cascade, the feeds
+// they added and everything else they own.
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.write(func(d *data) error {
+		d.deleteUser(id)
+		return nil
+	})
+}
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	var user database.User
+	err := s.read(func(d *data) error {
+		for _, u := range d.Users {
+			if u.Name == name {
+				user = u
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return user, err
+}
+
+func (s *Store) GetUserNameFromID(ctx context.Context, id uuid.UUID) (string, error) {
+	var name string
+	err := s.read(func(d *data) error {
+		user, ok := d.Users[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		name = user.Name
+		return nil
+	})
+	return name, err
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	var users []database.User
+	err := s.read(func(d *data) error {
+		users = values(d.Users, func(a, b database.User) bool {
+			return a.Name < b.Name
+		})
+		return nil
+	})
+	return nilIfEmpty(users), err
+}
+
+func (s *Store) RenameUser(ctx context.Context, arg database.RenameUserParams) (database.User, error) {
+	var user database.User
+	err := s.write(func(d *data) error {
+		var ok bool
+		user, ok = d.Users[arg.ID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if user.Name != arg.Name && d.userNamed(arg.Name) {
+			return uniqueViolation("users_name_key")
+		}
+		user.Name = arg.Name
+		user.UpdatedAt = arg.UpdatedAt
+		d.Users[arg.ID] = user
+		return nil
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	return s.write(func(d *data) error {
+		user, ok := d.Users[arg.ID]
+		if !ok {
+			return nil
+		}
+		user.PasswordHash = arg.PasswordHash
+		user.UpdatedAt = arg.UpdatedAt
+		d.Users[arg.ID] = user
+		return nil
+	})
+}
+
+// userNamed reports whether a user has the name.
+func (d *data) userNamed(name string) bool {
+	for _, user := range d.Users {
+		if user.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+// ClaimWebhookDeliveries leases up to arg.MaxDeliveries pending deliveries
+// that are due, earliest first, by pushing their next attempt to
+// arg.LeaseUntil.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	var rows []database.ClaimWebhookDeliveriesRow
+	err := s.write(func(d *data) error {
+		deliveries := values(d.WebhookDeliveries, func(a, b database.WebhookDelivery) bool {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		})
+		for _, delivery := range deliveries {
+			if len(rows) == int(arg.MaxDeliveries) {
+				break
+			}
+			if delivery.Status != "pending" || delivery.NextAttemptAt.After(arg.Now) {
+				continue
+			}
+			delivery.NextAttemptAt = arg.LeaseUntil
+			d.WebhookDeliveries[delivery.ID] = delivery
+
+			webhook := d.Webhooks[delivery.WebhookID]
+			post := d.Posts[delivery.PostID]
+			feed := d.Feeds[post.FeedID]
+			rows = append(rows, database.ClaimWebhookDeliveriesRow{
+				ID:          delivery.ID,
+				Attempts:    delivery.Attempts,
+				WebhookID:   webhook.ID,
+				WebhookUrl:  webhook.Url,
+				Secret:      webhook.Secret,
+				PostID:      post.ID,
+				Title:       post.Title,
+				Url:         post.Url,
+				Description: post.Description,
+				PublishedAt: post.PublishedAt,
+				FeedID:      feed.ID,
+				FeedName:    feed.Name,
+				FeedUrl:     feed.Url,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	webhook := database.Webhook(arg)
+	err := s.write(func(d *data) error {
+		if _, ok := d.Webhooks[arg.ID]; ok {
+			return uniqueViolation("webhooks_pkey")
+		}
+		if _, ok := d.Users[arg.UserID]; !ok {
+			return foreignKeyViolation("webhooks_user_id_fkey")
+		}
+		if _, ok := d.Feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+			return foreignKeyViolation("webhooks_feed_id_fkey")
+		}
+		d.Webhooks[arg.ID] = webhook
+		return nil
+	})
+	if err != nil {
+		return database.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		webhook, ok := d.Webhooks[arg.ID]
+		if !ok || webhook.UserID != arg.UserID {
+			return nil
+		}
+		d.deleteWebhook(arg.ID)
+		count = 1
+		return nil
+	})
+	return count, err
+}
+
+// EnqueueWebhookDeliveries queues the post for every webhook of the users
+// following its feed, and letting it notify webhooks, whose feed and keyword
+// filters it passes, unless the user hid it or it's queued for that webhook
+// already.
+func (s *Store) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	var count int64
+	err := s.write(func(d *data) error {
+		post, ok := d.Posts[arg.PostID]
+		if !ok {
+			return nil
+		}
+		text := strings.ToLower(post.Title + " " + post.Description.String)
+
+		queued := map[uuid.UUID]bool{}
+		for _, delivery := range d.WebhookDeliveries {
+			if delivery.PostID == post.ID {
+				queued[delivery.WebhookID] = true
+			}
+		}
+
+		for _, webhook := range d.Webhooks {
+			follow, following := d.feedFollow(webhook.UserID, post.FeedID)
+			switch {
+			case queued[webhook.ID]:
+			case !following:
+			case !notifies(follow, "webhooks"):
+			case webhook.FeedID.Valid && webhook.FeedID.UUID != post.FeedID:
+			case webhook.Keyword.Valid && !strings.Contains(text, strings.ToLower(webhook.Keyword.String)):
+			default:
+				if state, _ := d.postState(webhook.UserID, post.ID); state.HiddenAt.Valid {
+					continue
+				}
+				id := uuid.New()
+				d.WebhookDeliveries[id] = database.WebhookDelivery{
+					ID:            id,
+					CreatedAt:     arg.CreatedAt,
+					WebhookID:     webhook.ID,
+					PostID:        post.ID,
+					Status:        "pending",
+					NextAttemptAt: arg.CreatedAt,
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *Store) GetWebhookDeliveriesForUser(ctx context.Context, arg database.GetWebhookDeliveriesForUserParams) ([]database.GetWebhookDeliveriesForUserRow, error) {
+	var rows []database.GetWebhookDeliveriesForUserRow
+	err := s.read(func(d *data) error {
+		deliveries := values(d.WebhookDeliveries, func(a, b database.WebhookDelivery) bool {
+			return a.CreatedAt.After(b.CreatedAt)
+		})
+		for _, delivery := range deliveries {
+			if len(rows) == int(arg.Limit) {
+				break
+			}
+			webhook := d.Webhooks[delivery.WebhookID]
+			if webhook.UserID != arg.UserID {
+				continue
+			}
+			if arg.WebhookID.Valid && webhook.ID != arg.WebhookID.UUID {
+				continue
+			}
+			rows = append(rows, database.GetWebhookDeliveriesForUserRow{
+				ID:            delivery.ID,
+				CreatedAt:     delivery.CreatedAt,
+				WebhookID:     delivery.WebhookID,
+				PostID:        delivery.PostID,
+				Status:        delivery.Status,
+				Attempts:      delivery.Attempts,
+				NextAttemptAt: delivery.NextAttemptAt,
+				LastAttemptAt: delivery.LastAttemptAt,
+				ResponseCode:  delivery.ResponseCode,
+				Error:         delivery.Error,
+				WebhookUrl:    webhook.Url,
+				PostTitle:     d.Posts[delivery.PostID].Title,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]database.GetWebhooksForUserRow, error) {
+	var rows []database.GetWebhooksForUserRow
+	err := s.read(func(d *data) error {
+		webhooks := values(d.Webhooks, func(a, b database.Webhook) bool {
+			return a.CreatedAt.Before(b.CreatedAt)
+		})
+		for _, webhook := range webhooks {
+			if webhook.UserID != userID {
+				continue
+			}
+			rows = append(rows, database.GetWebhooksForUserRow{
+				ID:        webhook.ID,
+				CreatedAt: webhook.CreatedAt,
+				UpdatedAt: webhook.UpdatedAt,
+				UserID:    webhook.UserID,
+				Url:       webhook.Url,
+				Secret:    webhook.Secret,
+				FeedID:    webhook.FeedID,
+				Keyword:   webhook.Keyword,
+				FeedName:  d.feedName(webhook.FeedID),
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (s *Store) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	return s.write(func(d *data) error {
+		delivery, ok := d.WebhookDeliveries[arg.ID]
+		if !ok {
+			return nil
+		}
+		delivery.Status = arg.Status
+		delivery.Attempts = arg.Attempts
+		delivery.LastAttemptAt = arg.LastAttemptAt
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		delivery.ResponseCode = arg.ResponseCode
+		delivery.Error = arg.Error
+		d.WebhookDeliveries[arg.ID] = delivery
+		return nil
+	})
+}
+
+// feedName is the name a LEFT JOIN on an optional feed id returns.
+func (d *data) feedName(feedID uuid.NullUUID) sql.NullString {
+	feed, ok := d.Feeds[feedID.UUID]
+	if !feedID.Valid || !ok {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: feed.Name, Valid: true}
+}
@@ -0,0 +1,761 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+// Open opens the sqlite database at path, creating the file if needed, with
+// foreign keys enforced and transactions that wait for the write lock.
+func Open(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate")
+}
+
+// Store runs the database.Querier queries on sqlite, converting between the
+// sqlite and postgres row types so callers can't tell the backends apart.
+type Store struct {
+	q *Queries
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func NewStore(db DBTX) *Store {
+	return &Store{q: New(utcDB{db})}
+}
+
+// WithTx returns a Store running its queries in tx, like Queries.WithTx.
+func (s *Store) WithTx(tx *sql.Tx) *Store {
+	return NewStore(tx)
+}
+
+// timeFormat is fixed width, so comparing and sorting the stored text
+// matches comparing the times.
+const timeFormat = "2006-01-02 15:04:05.000000000"
+
+// utcDB stores every time parameter as UTC text in timeFormat.
+type utcDB struct {
+	db DBTX
+}
+
+func (u utcDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return u.db.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (u utcDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return u.db.PrepareContext(ctx, query)
+}
+
+func (u utcDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return u.db.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (u utcDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return u.db.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC().Format(timeFormat)
+		case sql.NullTime:
+			if v.Valid {
+				args[i] = v.Time.UTC().Format(timeFormat)
+			}
+		}
+	}
+	return args
+}
+
+// uniqueErr wraps unique constraint failures in database.ErrUniqueViolation.
+func uniqueErr(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("%w: %v", database.ErrUniqueViolation, err)
+	}
+	return err
+}
+
+func convert[T, U any](items []T, f func(T) U) []U {
+	if items == nil {
+		return nil
+	}
+	out := make([]U, 0, len(items))
+	for _, item := range items {
+		out = append(out, f(item))
+	}
+	return out
+}
+
+// jsonArray encodes the arrays postgres keeps in array columns.
+func jsonArray[T any](items []T) string {
+	if items == nil {
+		return "[]"
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+func fromJSONArray(text string) []string {
+	items := []string{}
+	json.Unmarshal([]byte(text), &items)
+	return items
+}
+
+func toPost(p Post) database.Post {
+	return database.Post{
+		ID:          p.ID,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Title:       p.Title,
+		Url:         p.Url,
+		Description: p.Description,
+		PublishedAt: p.PublishedAt,
+		FeedID:      p.FeedID,
+		Content:     p.Content,
+		Seq:         p.Seq,
+		Author:      p.Author,
+		Categories:  fromJSONArray(p.Categories),
+	}
+}
+
+func toUser(u User) database.User {
+	return database.User(u)
+}
+
+func toFeed(f Feed) database.Feed {
+	return database.Feed(f)
+}
+
+func toRule(r Rule) database.Rule {
+	return database.Rule(r)
+}
+
+func toAPIKey(k ApiKey) database.ApiKey {
+	return database.ApiKey(k)
+}
+
+// ftsQuery turns postgres websearch syntax ("exact phrase", or, -excluded)
+// into an FTS4 query, dropping characters FTS4 would read as operators.
+func ftsQuery(query string) string {
+	terms := []string{}
+	negate := false
+	for len(query) > 0 {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+		if query[0] == '-' {
+			negate = true
+			query = query[1:]
+			continue
+		}
+
+		var term string
+		if query[0] == '"' {
+			phrase, rest, _ := strings.Cut(query[1:], "\"")
+			query = rest
+			if words := strings.Fields(ftsWord(phrase)); len(words) > 0 {
+				term = "\"" + strings.Join(words, " ") + "\""
+			}
+		} else {
+			end := strings.IndexFunc(query, unicode.IsSpace)
+			if end < 0 {
+				end = len(query)
+			}
+			word := query[:end]
+			query = query[end:]
+			if strings.EqualFold(word, "or") && len(terms) > 0 && !negate {
+				terms = append(terms, "OR")
+				continue
+			}
+			term = strings.Join(strings.Fields(ftsWord(word)), " ")
+		}
+
+		if term == "" {
+			negate = false
+			continue
+		}
+		if negate {
+			// NOT needs something on its left
+			if len(terms) == 0 || terms[len(terms)-1] == "OR" {
+				negate = false
+				continue
+			}
+			term = "NOT " + term
+			negate = false
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) > 0 && terms[len(terms)-1] == "OR" {
+		terms = terms[:len(terms)-1]
+	}
+	return strings.Join(terms, " ")
+}
+
+func ftsWord(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, word)
+}
+
+func (s *Store) AddPostTag(ctx context.Context, arg database.AddPostTagParams) error {
+	return s.q.AddPostTag(ctx, AddPostTagParams(arg))
+}
+
+// ClaimWebhookDeliveries leases the deliveries then reads them, sqlite's
+// RETURNING can't include the joined webhook and post.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	ids, err := s.q.ClaimWebhookDeliveries(ctx, ClaimWebhookDeliveriesParams{
+		LeaseUntil:    arg.LeaseUntil,
+		Now:           arg.Now,
+		MaxDeliveries: int64(arg.MaxDeliveries),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []database.ClaimWebhookDeliveriesRow
+	for _, id := range ids {
+		row, err := s.q.GetClaimedWebhookDelivery(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, database.ClaimWebhookDeliveriesRow{
+			ID:          row.ID,
+			Attempts:    int32(row.Attempts),
+			WebhookID:   row.WebhookID,
+			WebhookUrl:  row.WebhookUrl,
+			Secret:      row.Secret,
+			PostID:      row.PostID,
+			Title:       row.Title,
+			Url:         row.Url,
+			Description: row.Description,
+			PublishedAt: row.PublishedAt,
+			FeedID:      row.FeedID,
+			FeedName:    row.FeedName,
+			FeedUrl:     row.FeedUrl,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) ClearFollowFolder(ctx context.Context, arg database.ClearFollowFolderParams) error {
+	return s.q.ClearFollowFolder(ctx, ClearFollowFolderParams(arg))
+}
+
+func (s *Store) CountPostsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountPostsForUser(ctx, userID)
+}
+
+func (s *Store) CountRecords(ctx context.Context, userID uuid.NullUUID) (database.CountRecordsRow, error) {
+	counts, err := s.q.CountRecords(ctx, userID)
+	return database.CountRecordsRow(counts), err
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	key, err := s.q.CreateAPIKey(ctx, CreateAPIKeyParams(arg))
+	return toAPIKey(key), uniqueErr(err)
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	feed, err := s.q.CreateFeed(ctx, CreateFeedParams(arg))
+	return toFeed(feed), uniqueErr(err)
+}
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	follow, err := s.q.CreateFeedFollow(ctx, CreateFeedFollowParams(arg))
+	if err != nil {
+		return database.CreateFeedFollowRow{}, uniqueErr(err)
+	}
+	row, err := s.q.GetFeedFollow(ctx, follow.ID)
+	return database.CreateFeedFollowRow{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+		UserID:    row.UserID,
+		FeedID:    row.FeedID,
+		Title:     row.Title,
+		Muted:     row.Muted,
+		Priority:  int32(row.Priority),
+		Notify:    row.Notify,
+		SortOrder: row.SortOrder,
+		FeedName:  row.FeedName,
+		UserName:  row.UserName,
+	}, err
+}
+
+func (s *Store) CreateFolder(ctx context.Context, arg database.CreateFolderParams) (database.Folder, error) {
+	folder, err := s.q.CreateFolder(ctx, CreateFolderParams(arg))
+	return database.Folder(folder), uniqueErr(err)
+}
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	post, err := s.q.CreatePost(ctx, CreatePostParams{
+		ID:          arg.ID,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: arg.PublishedAt,
+		FeedID:      arg.FeedID,
+		Content:     arg.Content,
+		Author:      arg.Author,
+		Categories:  jsonArray(arg.Categories),
+	})
+	return toPost(post), uniqueErr(err)
+}
+
+func (s *Store) CreateRule(ctx context.Context, arg database.CreateRuleParams) (database.Rule, error) {
+	rule, err := s.q.CreateRule(ctx, CreateRuleParams(arg))
+	return toRule(rule), uniqueErr(err)
+}
+
+func (s *Store) CreateSession(ctx context.Context, arg database.CreateSessionParams) (database.Session, error) {
+	session, err := s.q.CreateSession(ctx, CreateSessionParams(arg))
+	return database.Session(session), uniqueErr(err)
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, CreateUserParams(arg))
+	return toUser(user), uniqueErr(err)
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	webhook, err := s.q.CreateWebhook(ctx, CreateWebhookParams(arg))
+	return database.Webhook(webhook), uniqueErr(err)
+}
+
+func (s *Store) DeleteAppPassword(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.DeleteAppPassword(ctx, userID)
+}
+
+func (s *Store) DeleteDigestSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.DeleteDigestSubscription(ctx, userID)
+}
+
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteFeed(ctx, id)
+}
+
+func (s *Store) DeleteFeeds(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	return s.q.DeleteFeeds(ctx, userID)
+}
+
+func (s *Store) DeleteFolder(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteFolder(ctx, id)
+}
+
+func (s *Store) DeletePosts(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	return s.q.DeletePosts(ctx, userID)
+}
+
+func (s *Store) DeleteRecords(ctx context.Context) error {
+	return s.q.DeleteRecords(ctx)
+}
+
+func (s *Store) DeleteRule(ctx context.Context, arg database.DeleteRuleParams) (int64, error) {
+	return s.q.DeleteRule(ctx, DeleteRuleParams(arg))
+}
+
+func (s *Store) DeleteSession(ctx context.Context, tokenHash string) error {
+	return s.q.DeleteSession(ctx, tokenHash)
+}
+
+func (s *Store) DeleteSessionsForUser(ctx context.Context, userID uuid.UUID) error {
+	return s.q.DeleteSessionsForUser(ctx, userID)
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteUser(ctx, id)
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+	return s.q.DeleteWebhook(ctx, DeleteWebhookParams(arg))
+}
+
+func (s *Store) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	return s.q.EnqueueWebhookDeliveries(ctx, EnqueueWebhookDeliveriesParams(arg))
+}
+
+func (s *Store) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	keys, err := s.q.GetAPIKeysForUser(ctx, userID)
+	return convert(keys, toAPIKey), err
+}
+
+func (s *Store) GetDigestSubscription(ctx context.Context, userID uuid.UUID) (database.DigestSubscription, error) {
+	sub, err := s.q.GetDigestSubscription(ctx, userID)
+	return database.DigestSubscription(sub), err
+}
+
+func (s *Store) GetDigestSubscriptions(ctx context.Context) ([]database.GetDigestSubscriptionsRow, error) {
+	subs, err := s.q.GetDigestSubscriptions(ctx)
+	return convert(subs, func(row GetDigestSubscriptionsRow) database.GetDigestSubscriptionsRow {
+		return database.GetDigestSubscriptionsRow(row)
+	}), err
+}
+
+func (s *Store) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	feed, err := s.q.GetFeed(ctx, url)
+	return toFeed(feed), err
+}
+
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	feed, err := s.q.GetFeedByID(ctx, id)
+	return toFeed(feed), err
+}
+
+func (s *Store) GetFeedFollowerNames(ctx context.Context, feedID uuid.UUID) ([]string, error) {
+	return s.q.GetFeedFollowerNames(ctx, feedID)
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	follows, err := s.q.GetFeedFollowsForUser(ctx, userID)
+	return convert(follows, func(row GetFeedFollowsForUserRow) database.GetFeedFollowsForUserRow {
+		return database.GetFeedFollowsForUserRow{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			UserID:     row.UserID,
+			FeedID:     row.FeedID,
+			Title:      row.Title,
+			Muted:      row.Muted,
+			Priority:   int32(row.Priority),
+			Notify:     row.Notify,
+			SortOrder:  row.SortOrder,
+			FeedName:   row.FeedName,
+			FeedUrl:    row.FeedUrl,
+			UserName:   row.UserName,
+			FolderName: row.FolderName,
+		}
+	}), err
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	feeds, err := s.q.GetFeeds(ctx)
+	return convert(feeds, toFeed), err
+}
+
+func (s *Store) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	feeds, err := s.q.GetFeedsForUser(ctx, userID)
+	return convert(feeds, toFeed), err
+}
+
+func (s *Store) GetFolder(ctx context.Context, arg database.GetFolderParams) (database.Folder, error) {
+	folder, err := s.q.GetFolder(ctx, GetFolderParams(arg))
+	return database.Folder(folder), err
+}
+
+func (s *Store) GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFoldersForUserRow, error) {
+	folders, err := s.q.GetFoldersForUser(ctx, userID)
+	return convert(folders, func(row GetFoldersForUserRow) database.GetFoldersForUserRow {
+		return database.GetFoldersForUserRow(row)
+	}), err
+}
+
+func (s *Store) GetItemsForUser(ctx context.Context, arg database.GetItemsForUserParams) ([]database.GetItemsForUserRow, error) {
+	params := GetItemsForUserParams{
+		UserID:      arg.UserID,
+		FeedID:      arg.FeedID,
+		UnreadOnly:  arg.UnreadOnly,
+		StarredOnly: arg.StarredOnly,
+		ForDigest:   arg.ForDigest,
+		AfterSeq:    arg.AfterSeq,
+		BeforeSeq:   arg.BeforeSeq,
+		NewerThan:   arg.NewerThan,
+		OlderThan:   arg.OlderThan,
+		OldestFirst: arg.OldestFirst,
+		Limit:       int64(arg.Limit),
+	}
+	if arg.Seqs != nil {
+		params.Seqs = sql.NullString{String: jsonArray(arg.Seqs), Valid: true}
+	}
+	items, err := s.q.GetItemsForUser(ctx, params)
+	return convert(items, func(row GetItemsForUserRow) database.GetItemsForUserRow {
+		return database.GetItemsForUserRow(row)
+	}), err
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	feed, err := s.q.GetNextFeedToFetch(ctx)
+	return toFeed(feed), err
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	posts, err := s.q.GetPostsForUser(ctx, GetPostsForUserParams{
+		UserID:      arg.UserID,
+		FeedID:      arg.FeedID,
+		FolderID:    arg.FolderID,
+		UnreadOnly:  arg.UnreadOnly,
+		StarredOnly: arg.StarredOnly,
+		OldestFirst: arg.OldestFirst,
+		Limit:       int64(arg.Limit),
+		Offset:      int64(arg.Offset),
+	})
+	return convert(posts, func(row GetPostsForUserRow) database.GetPostsForUserRow {
+		return database.GetPostsForUserRow{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			Title:       row.Title,
+			Url:         row.Url,
+			Description: row.Description,
+			PublishedAt: row.PublishedAt,
+			FeedID:      row.FeedID,
+			Content:     row.Content,
+			Seq:         row.Seq,
+			Author:      row.Author,
+			Categories:  fromJSONArray(row.Categories),
+			FeedName:    row.FeedName,
+			FeedUrl:     row.FeedUrl,
+			ReadAt:      row.ReadAt,
+			StarredAt:   row.StarredAt,
+			Tags:        fromJSONArray(row.Tags),
+		}
+	}), err
+}
+
+func (s *Store) GetRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]database.Rule, error) {
+	rules, err := s.q.GetRulesForFeed(ctx, feedID)
+	return convert(rules, toRule), err
+}
+
+func (s *Store) GetRulesForUser(ctx context.Context, userID uuid.UUID) ([]database.GetRulesForUserRow, error) {
+	rules, err := s.q.GetRulesForUser(ctx, userID)
+	return convert(rules, func(row GetRulesForUserRow) database.GetRulesForUserRow {
+		return database.GetRulesForUserRow(row)
+	}), err
+}
+
+func (s *Store) GetUnreadCountsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetUnreadCountsForUserRow, error) {
+	counts, err := s.q.GetUnreadCountsForUser(ctx, userID)
+	return convert(counts, func(row GetUnreadCountsForUserRow) database.GetUnreadCountsForUserRow {
+		return database.GetUnreadCountsForUserRow(row)
+	}), err
+}
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	user, err := s.q.GetUser(ctx, name)
+	return toUser(user), err
+}
+
+func (s *Store) GetUserByAPIKey(ctx context.Context, keyHash string) (database.GetUserByAPIKeyRow, error) {
+	row, err := s.q.GetUserByAPIKey(ctx, keyHash)
+	return database.GetUserByAPIKeyRow{
+		User:      toUser(row.User),
+		KeyID:     row.KeyID,
+		Scope:     row.Scope,
+		ExpiresAt: row.ExpiresAt,
+		RevokedAt: row.RevokedAt,
+	}, err
+}
+
+func (s *Store) GetUserByAppPassword(ctx context.Context, keyHash string) (database.GetUserByAppPasswordRow, error) {
+	row, err := s.q.GetUserByAppPassword(ctx, keyHash)
+	return database.GetUserByAppPasswordRow{User: toUser(row.User)}, err
+}
+
+func (s *Store) GetUserByFeedToken(ctx context.Context, tokenHash string) (database.GetUserByFeedTokenRow, error) {
+	row, err := s.q.GetUserByFeedToken(ctx, tokenHash)
+	return database.GetUserByFeedTokenRow{User: toUser(row.User)}, err
+}
+
+func (s *Store) GetUserBySession(ctx context.Context, tokenHash string) (database.GetUserBySessionRow, error) {
+	row, err := s.q.GetUserBySession(ctx, tokenHash)
+	return database.GetUserBySessionRow{User: toUser(row.User), ExpiresAt: row.ExpiresAt}, err
+}
+
+func (s *Store) GetUserNameFromID(ctx context.Context, id uuid.UUID) (string, error) {
+	return s.q.GetUserNameFromID(ctx, id)
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	users, err := s.q.GetUsers(ctx)
+	return convert(users, toUser), err
+}
+
+func (s *Store) GetWebhookDeliveriesForUser(ctx context.Context, arg database.GetWebhookDeliveriesForUserParams) ([]database.GetWebhookDeliveriesForUserRow, error) {
+	deliveries, err := s.q.GetWebhookDeliveriesForUser(ctx, GetWebhookDeliveriesForUserParams{
+		UserID:    arg.UserID,
+		WebhookID: arg.WebhookID,
+		Limit:     int64(arg.Limit),
+	})
+	return convert(deliveries, func(row GetWebhookDeliveriesForUserRow) database.GetWebhookDeliveriesForUserRow {
+		return database.GetWebhookDeliveriesForUserRow{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			WebhookID:     row.WebhookID,
+			PostID:        row.PostID,
+			Status:        row.Status,
+			Attempts:      int32(row.Attempts),
+			NextAttemptAt: row.NextAttemptAt,
+			LastAttemptAt: row.LastAttemptAt,
+			ResponseCode:  sql.NullInt32{Int32: int32(row.ResponseCode.Int64), Valid: row.ResponseCode.Valid},
+			Error:         row.Error,
+			WebhookUrl:    row.WebhookUrl,
+			PostTitle:     row.PostTitle,
+		}
+	}), err
+}
+
+func (s *Store) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]database.GetWebhooksForUserRow, error) {
+	webhooks, err := s.q.GetWebhooksForUser(ctx, userID)
+	return convert(webhooks, func(row GetWebhooksForUserRow) database.GetWebhooksForUserRow {
+		return database.GetWebhooksForUserRow(row)
+	}), err
+}
+
+func (s *Store) HidePost(ctx context.Context, arg database.HidePostParams) error {
+	return s.q.HidePost(ctx, HidePostParams(arg))
+}
+
+func (s *Store) MarkDigestSent(ctx context.Context, arg database.MarkDigestSentParams) error {
+	return s.q.MarkDigestSent(ctx, MarkDigestSentParams(arg))
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	return s.q.MarkFeedFetched(ctx, MarkFeedFetchedParams(arg))
+}
+
+func (s *Store) MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error) {
+	return s.q.MarkPostsRead(ctx, MarkPostsReadParams(arg))
+}
+
+// NotifyNewPost does nothing, sqlite has no LISTEN/NOTIFY so only postgres
+// can push new posts to other processes.
+func (s *Store) NotifyNewPost(ctx context.Context, payload string) error {
+	return nil
+}
+
+func (s *Store) PruneExcessPosts(ctx context.Context, maxPosts int64) (int64, error) {
+	return s.q.PruneExcessPosts(ctx, maxPosts)
+}
+
+func (s *Store) PruneOldPosts(ctx context.Context, before time.Time) (int64, error) {
+	return s.q.PruneOldPosts(ctx, before)
+}
+
+func (s *Store) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	return s.q.RecordWebhookAttempt(ctx, RecordWebhookAttemptParams{
+		ID:            arg.ID,
+		Status:        arg.Status,
+		Attempts:      int64(arg.Attempts),
+		LastAttemptAt: arg.LastAttemptAt,
+		NextAttemptAt: arg.NextAttemptAt,
+		ResponseCode:  sql.NullInt64{Int64: int64(arg.ResponseCode.Int32), Valid: arg.ResponseCode.Valid},
+		Error:         arg.Error,
+	})
+}
+
+func (s *Store) RenameFolder(ctx context.Context, arg database.RenameFolderParams) error {
+	return uniqueErr(s.q.RenameFolder(ctx, RenameFolderParams(arg)))
+}
+
+func (s *Store) RenameUser(ctx context.Context, arg database.RenameUserParams) (database.User, error) {
+	user, err := s.q.RenameUser(ctx, RenameUserParams(arg))
+	return toUser(user), uniqueErr(err)
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, arg database.RevokeAPIKeyParams) (int64, error) {
+	return s.q.RevokeAPIKey(ctx, RevokeAPIKeyParams(arg))
+}
+
+// SearchPostsForUser takes the same websearch syntax as postgres. Results
+// come newest first and Rank is always 0, FTS4 has no ranking function.
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	query := ftsQuery(arg.Query)
+	if query == "" {
+		return nil, nil
+	}
+	results, err := s.q.SearchPostsForUser(ctx, SearchPostsForUserParams{
+		Query:   query,
+		UserID:  arg.UserID,
+		MaxRows: int64(arg.MaxRows),
+	})
+	return convert(results, func(row SearchPostsForUserRow) database.SearchPostsForUserRow {
+		return database.SearchPostsForUserRow{
+			ID:          row.ID,
+			Title:       row.Title,
+			Url:         row.Url,
+			PublishedAt: row.PublishedAt,
+			FeedName:    row.FeedName,
+			Snippet:     row.Snippet,
+		}
+	}), err
+}
+
+func (s *Store) SetAppPassword(ctx context.Context, arg database.SetAppPasswordParams) error {
+	return uniqueErr(s.q.SetAppPassword(ctx, SetAppPasswordParams(arg)))
+}
+
+func (s *Store) SetDigestEmail(ctx context.Context, arg database.SetDigestEmailParams) (database.DigestSubscription, error) {
+	sub, err := s.q.SetDigestEmail(ctx, SetDigestEmailParams(arg))
+	return database.DigestSubscription(sub), err
+}
+
+func (s *Store) SetFeedOwner(ctx context.Context, arg database.SetFeedOwnerParams) error {
+	return s.q.SetFeedOwner(ctx, SetFeedOwnerParams(arg))
+}
+
+func (s *Store) SetFeedToken(ctx context.Context, arg database.SetFeedTokenParams) error {
+	return uniqueErr(s.q.SetFeedToken(ctx, SetFeedTokenParams(arg)))
+}
+
+func (s *Store) SetFollowFolder(ctx context.Context, arg database.SetFollowFolderParams) (int64, error) {
+	return s.q.SetFollowFolder(ctx, SetFollowFolderParams(arg))
+}
+
+func (s *Store) SetPostRead(ctx context.Context, arg database.SetPostReadParams) error {
+	return s.q.SetPostRead(ctx, SetPostReadParams(arg))
+}
+
+func (s *Store) SetPostStarred(ctx context.Context, arg database.SetPostStarredParams) error {
+	return s.q.SetPostStarred(ctx, SetPostStarredParams(arg))
+}
+
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	return s.q.SetUserPassword(ctx, SetUserPasswordParams(arg))
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, arg database.TouchAPIKeyParams) error {
+	return s.q.TouchAPIKey(ctx, TouchAPIKeyParams(arg))
+}
+
+func (s *Store) Unfollow(ctx context.Context, arg database.UnfollowParams) error {
+	return s.q.Unfollow(ctx, UnfollowParams(arg))
+}
+
+func (s *Store) UpdateFeed(ctx context.Context, arg database.UpdateFeedParams) (database.Feed, error) {
+	feed, err := s.q.UpdateFeed(ctx, UpdateFeedParams(arg))
+	return toFeed(feed), uniqueErr(err)
+}
+
+func (s *Store) UpdateFollowSettings(ctx context.Context, arg database.UpdateFollowSettingsParams) error {
+	return s.q.UpdateFollowSettings(ctx, UpdateFollowSettingsParams{
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+		Title:     arg.Title,
+		Muted:     arg.Muted,
+		Priority:  int64(arg.Priority),
+		Notify:    arg.Notify,
+		SortOrder: arg.SortOrder,
+		UpdatedAt: arg.UpdatedAt,
+	})
+}
@@ -2,57 +2,44 @@ package main
 
 import (
 	"fmt"
+	"flag"
 	"os"
-	"database/sql"
+	"strings"
 
 	_"github.com/lib/pq"
 	"github.com/npayetteraynauld/Blog-Aggregator/internal/config"
-	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
 )
 
 func main() {
 	//Read JSON to config struct
 	cfg, err := config.Read()
 	if err != nil {
-		fmt.Println(err)	
+		fmt.Fprintln(os.Stderr, err)
 	}
 
-	//open connection to database
-	db, err := sql.Open("postgres", cfg.DBURL)
-	if err != nil {
-		fmt.Errorf("Error opening database: %v", err)
-	}
-	
-	dbQueries := database.New(db)
-
 	//initialize state struct
 	s := state{
-		db: dbQueries,
 		cfg: &cfg,
 	}
 
+	//open connection to database, postgres, sqlite or memory depending on db_url
+	if err := openDatabase(&s); err != nil {
+		fmt.Println(err)
+	}
+	defer closeDatabase(&s)
+
 	//initialize commands struct
 	cmds := commands{
-		commands: make(map[string]func(*state, command) error),
+		commands: make(map[string]commandSpec),
 	}
 
 	//register handlers in commands
-	cmds.register("login", handlerLogin)
-	cmds.register("register", handlerRegister)
-	cmds.register("reset", handlerReset)
-	cmds.register("users", handlerUsers)
-	cmds.register("agg", handlerAgg)
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", handlerFeeds)
-	cmds.register("follow", middlewareLoggedIn(handlerFollow))
-	cmds.register("following", middlewareLoggedIn(handlerFollowing))
-	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	registerCommands(&cmds)
 
 	//parsing arguments
 	arguments := os.Args
 	if len(arguments) < 2 {
-		fmt.Println("No arguments provided")
+		cmds.printHelp(os.Stderr)
 		os.Exit(1)
 	}
 	funcName := arguments[1]
@@ -68,6 +55,7 @@ func main() {
 	err = cmds.run(&s, cmd)
 	if err != nil {
 		fmt.Println(err)
+		closeDatabase(&s)
 		os.Exit(1)
 	}
 
@@ -75,3 +63,744 @@ func main() {
 	
 	cfg, err = config.Read()
 }
+
+func registerCommands(cmds *commands) {
+	cmds.register(commandSpec{
+		name: "help",
+		summary: "Show the list of commands, or details on one command",
+		usage: "[command [subcommand]]",
+		maxArgs: -1,
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return cmds.complete(s, []string{""})
+			}
+			return nil
+		},
+		noSchemaCheck: true,
+		handler: cmds.handlerHelp,
+	})
+	cmds.register(commandSpec{
+		name: "completion",
+		summary: "Print a shell completion script",
+		usage: "<bash|zsh|fish>",
+		minArgs: 1,
+		maxArgs: 1,
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return shells
+			}
+			return nil
+		},
+		noSchemaCheck: true,
+		handler: cmds.handlerCompletion,
+	})
+	cmds.register(commandSpec{
+		name: completeCommand,
+		summary: "Print completion suggestions for the given words",
+		maxArgs: -1,
+		hidden: true,
+		rawArgs: true,
+		noSchemaCheck: true,
+		handler: cmds.handlerComplete,
+	})
+	cmds.register(commandSpec{
+		name: "login",
+		summary: "Switch the current user",
+		usage: "<name>",
+		minArgs: 1,
+		maxArgs: 1,
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		handler: handlerLogin,
+	})
+	cmds.register(commandSpec{
+		name: "register",
+		summary: "Create a user and log in as them",
+		usage: "<name>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("password", false, "protect the user with a password (prompted for)")
+		},
+		handler: handlerRegister,
+	})
+	cmds.register(commandSpec{
+		name: "passwd",
+		summary: "Set, change or remove the current user's password",
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("remove", false, "remove the password, making the user passwordless")
+		},
+		userHandler: handlerPasswd,
+	})
+	cmds.register(commandSpec{
+		name: "logout",
+		summary: "Log out the current user",
+		handler: handlerLogout,
+	})
+	cmds.register(commandSpec{
+		name: "reset",
+		summary: "Delete all users, feeds, follows and posts, or just some of them",
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("posts", false, "delete only posts")
+			fs.Bool("feeds", false, "delete only feeds, with their follows and posts")
+			fs.String("user", "", "only what this user added: the feeds they added with --posts or --feeds, otherwise the user and all they own")
+			fs.String("backup", "", "file to back the database up to before deleting")
+			fs.Bool("yes", false, "don't ask for confirmation")
+			fs.Bool("force", false, "with --user, delete even feeds other users follow")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if flagName == "user" {
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		handler: handlerReset,
+	})
+	cmds.register(commandSpec{
+		name: "users",
+		summary: "List registered users",
+		flags: outputFlag,
+		handler: handlerUsers,
+	})
+	cmds.register(commandSpec{
+		name: "whoami",
+		summary: "Print the current user's name",
+		handler: handlerWhoami,
+	})
+	cmds.register(commandSpec{
+		name: "renameuser",
+		summary: "Rename a user, asking for their password if they have one",
+		usage: "<name> <new name>",
+		minArgs: 2,
+		maxArgs: 2,
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		handler: handlerRenameUser,
+	})
+	cmds.register(commandSpec{
+		name: "deluser",
+		summary: "Delete a user with the feeds they added and everything else they own",
+		usage: "<name>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("force", false, "delete the user even if others follow feeds they added")
+			fs.Bool("yes", false, "don't ask for confirmation")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		handler: handlerDeleteUser,
+	})
+	cmds.register(commandSpec{
+		name: "agg",
+		summary: "Fetch feeds continuously, one feed per interval",
+		usage: "<interval (ex: 1s, 1m, 1h)>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("prune", false, "prune posts by the config file's retention every hour")
+		},
+		handler: handlerAgg,
+	})
+	cmds.register(commandSpec{
+		name: "prune",
+		summary: "Delete old posts, keeping starred ones",
+		flags: func(fs *flag.FlagSet) {
+			fs.String("max-age", "", "delete posts published longer ago than this (ex: 90d, 720h)")
+			fs.Int("max-posts", 0, "keep only this many of each feed's newest posts")
+		},
+		handler: handlerPrune,
+	})
+	cmds.register(commandSpec{
+		name: "addfeed",
+		summary: "Add a feed and follow it",
+		usage: "<name> <url>",
+		minArgs: 2,
+		maxArgs: 2,
+		userHandler: handlerAddFeed,
+	})
+	cmds.register(commandSpec{
+		name: "feeds",
+		summary: "List all feeds",
+		flags: outputFlag,
+		handler: handlerFeeds,
+	})
+	cmds.register(commandSpec{
+		name: "follow",
+		summary: "Follow an existing feed",
+		usage: "<url>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.String("folder", "", "put the feed in this folder")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if flagName == "folder" {
+				return completeFolders(s)
+			}
+			if arg == 0 {
+				return completeFeedURLs(s)
+			}
+			return nil
+		},
+		userHandler: handlerFollow,
+	})
+	cmds.register(commandSpec{
+		name: "following",
+		summary: "List the feeds you follow",
+		flags: outputFlag,
+		userHandler: handlerFollowing,
+	})
+	cmds.register(commandSpec{
+		name: "follow-settings",
+		summary: "Show or change your settings for a followed feed",
+		usage: "<url|name>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.String("title", "", "title shown instead of the feed's name, empty for the name")
+			fs.Bool("mute", false, "leave the feed's posts out of browse unless asked for with --feed (--mute=false to unmute)")
+			fs.Int("priority", 0, "feeds with a higher priority are listed, and their posts browsed, first")
+			fs.String("notify", notifyAll, "where new posts are sent ("+strings.Join(notifyOptions, ", ")+")")
+			fs.String("sort", sortNewest, "order browse --feed lists the posts in ("+strings.Join(sortOptions, ", ")+")")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			switch flagName {
+			case "notify":
+				return notifyOptions
+			case "sort":
+				return sortOptions
+			}
+			if arg == 0 {
+				return completeFollowedFeeds(s, true)
+			}
+			return nil
+		},
+		userHandler: handlerFollowSettings,
+	})
+	cmds.register(commandSpec{
+		name: "unfollow",
+		summary: "Stop following a feed",
+		usage: "<url>",
+		minArgs: 1,
+		maxArgs: 1,
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeFollowedFeeds(s, false)
+			}
+			return nil
+		},
+		userHandler: handlerUnfollow,
+	})
+	cmds.register(commandSpec{
+		name: "rmfeed",
+		summary: "Delete a feed you added, with its follows and posts",
+		usage: "<url|name>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("force", false, "delete the feed even though other users follow it")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeOwnedFeeds(s)
+			}
+			return nil
+		},
+		userHandler: handlerRemoveFeed,
+	})
+	cmds.register(commandSpec{
+		name: "editfeed",
+		summary: "Rename a feed you added or change its url",
+		usage: "<url|name>",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.String("name", "", "new name of the feed")
+			fs.String("url", "", "new url of the feed")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if arg == 0 {
+				return completeOwnedFeeds(s)
+			}
+			return nil
+		},
+		userHandler: handlerEditFeed,
+	})
+	cmds.register(commandSpec{
+		name: "transfer-feed",
+		summary: "Hand a feed you added over to another user",
+		usage: "<url|name> <user>",
+		minArgs: 2,
+		maxArgs: 2,
+		complete: func(s *state, flagName string, arg int) []string {
+			switch arg {
+			case 0:
+				return completeOwnedFeeds(s)
+			case 1:
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		userHandler: handlerTransferFeed,
+	})
+	cmds.register(commandSpec{
+		name: "browse",
+		summary: "Show the most recent posts from the feeds you follow",
+		usage: "[limit]",
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			outputFlag(fs)
+			fs.String("feed", "", "only show posts from this followed feed (url or name)")
+			fs.String("folder", "", "only show posts from the feeds in this folder")
+			fs.String("sort", "", "newest or oldest first, by default the --feed's follow-settings or newest")
+			fs.Bool("unread", false, "only show unread posts")
+			fs.Bool("starred", false, "only show starred posts")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			switch flagName {
+			case "feed":
+				return completeFollowedFeeds(s, true)
+			case "folder":
+				return completeFolders(s)
+			case "sort":
+				return sortOptions
+			}
+			return nil
+		},
+		userHandler: handlerBrowse,
+	})
+	cmds.register(commandSpec{
+		name: "folder",
+		summary: "Organise the feeds you follow in folders",
+		subcommands: []commandSpec{
+			{
+				name: "create",
+				summary: "Create an empty folder",
+				usage: "<name>",
+				minArgs: 1,
+				maxArgs: 1,
+				userHandler: handlerFolderCreate,
+			},
+			{
+				name: "rename",
+				summary: "Rename a folder",
+				usage: "<name> <new name>",
+				minArgs: 2,
+				maxArgs: 2,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeFolders(s)
+					}
+					return nil
+				},
+				userHandler: handlerFolderRename,
+			},
+			{
+				name: "rm",
+				summary: "Remove a folder, its feeds stay followed",
+				usage: "<name>",
+				minArgs: 1,
+				maxArgs: 1,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeFolders(s)
+					}
+					return nil
+				},
+				userHandler: handlerFolderRemove,
+			},
+			{
+				name: "list",
+				summary: "List your folders",
+				flags: outputFlag,
+				userHandler: handlerFolderList,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "move",
+		summary: "Put a followed feed in a folder, or out of its folder",
+		usage: "<url|name> [folder]",
+		minArgs: 1,
+		maxArgs: 2,
+		complete: func(s *state, flagName string, arg int) []string {
+			switch arg {
+			case 0:
+				return completeFollowedFeeds(s, true)
+			case 1:
+				return completeFolders(s)
+			}
+			return nil
+		},
+		userHandler: handlerMove,
+	})
+	cmds.register(commandSpec{
+		name: "markall",
+		summary: "Mark posts from the feeds you follow in bulk",
+		subcommands: []commandSpec{
+			{
+				name: "read",
+				summary: "Mark every post as read, or those of one feed or folder",
+				flags: func(fs *flag.FlagSet) {
+					fs.String("feed", "", "only mark posts from this followed feed (url or name)")
+					fs.String("folder", "", "only mark posts from the feeds in this folder")
+				},
+				complete: func(s *state, flagName string, arg int) []string {
+					switch flagName {
+					case "feed":
+						return completeFollowedFeeds(s, true)
+					case "folder":
+						return completeFolders(s)
+					}
+					return nil
+				},
+				userHandler: handlerMarkAllRead,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "opml",
+		summary: "Import or export the feeds you follow as OPML",
+		subcommands: []commandSpec{
+			{
+				name: "import",
+				summary: "Follow the feeds in an OPML file, filed in its folders",
+				usage: "<file>",
+				minArgs: 1,
+				maxArgs: 1,
+				userHandler: handlerOPMLImport,
+			},
+			{
+				name: "export",
+				summary: "Write the feeds you follow and their folders as OPML",
+				flags: func(fs *flag.FlagSet) {
+					fs.String("out", "", "file to write to, stdout if empty")
+				},
+				userHandler: handlerOPMLExport,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "tui",
+		summary: "Read posts in an interactive terminal interface",
+		flags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 200, "maximum number of posts listed per feed")
+			fs.Bool("unread", false, "start with only unread posts listed")
+		},
+		userHandler: handlerTUI,
+	})
+	cmds.register(commandSpec{
+		name: "serve",
+		summary: "Serve the JSON API over HTTP",
+		flags: func(fs *flag.FlagSet) {
+			fs.String("addr", ":8080", "address to listen on")
+		},
+		handler: handlerServe,
+	})
+	cmds.register(commandSpec{
+		name: "apikey",
+		summary: "Manage api keys for the HTTP API",
+		subcommands: []commandSpec{
+			{
+				name: "create",
+				summary: "Create an api key for the current user",
+				flags: func(fs *flag.FlagSet) {
+					fs.String("name", "", "label to recognise the key by")
+					fs.String("expires", "", "lifetime of the key (ex: 720h), never expires if empty")
+					fs.Bool("read-only", false, "only allow GET requests with the key")
+				},
+				userHandler: handlerAPIKeyCreate,
+			},
+			{
+				name: "list",
+				summary: "List the current user's api keys",
+				flags: outputFlag,
+				userHandler: handlerAPIKeyList,
+			},
+			{
+				name: "revoke",
+				summary: "Revoke an api key by id or prefix",
+				usage: "<id|prefix>",
+				minArgs: 1,
+				maxArgs: 1,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeAPIKeys(s)
+					}
+					return nil
+				},
+				userHandler: handlerAPIKeyRevoke,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "export-feed",
+		summary: "Write your timeline as an RSS or Atom feed",
+		flags: func(fs *flag.FlagSet) {
+			fs.String("format", exportRSS, "feed format (rss, atom)")
+			fs.String("feed", "", "only export posts from this followed feed (url or name)")
+			fs.Int("limit", defaultExportLimit, "maximum number of posts")
+			fs.String("out", "", "file to write to, stdout if empty")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			switch flagName {
+			case "format":
+				return []string{exportRSS, exportAtom}
+			case "feed":
+				return completeFollowedFeeds(s, true)
+			}
+			return nil
+		},
+		userHandler: handlerExportFeed,
+	})
+	cmds.register(commandSpec{
+		name: "feed-token",
+		summary: "Create or rotate the secret token for your served timeline feeds",
+		flags: func(fs *flag.FlagSet) {
+			fs.String("base-url", "http://localhost:8080", "address the server is reachable at")
+		},
+		userHandler: handlerFeedToken,
+	})
+	cmds.register(commandSpec{
+		name: "app-password",
+		summary: "Set the password mobile apps use with the Fever and Google Reader APIs",
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("remove", false, "remove the app password, signing the apps out")
+			fs.String("base-url", "http://localhost:8080", "address the server is reachable at")
+		},
+		userHandler: handlerAppPassword,
+	})
+	cmds.register(commandSpec{
+		name: "webhook",
+		summary: "Send new posts to your own URLs",
+		subcommands: []commandSpec{
+			{
+				name: "add",
+				summary: "Register a URL to receive new posts from followed feeds",
+				usage: "<url>",
+				minArgs: 1,
+				maxArgs: 1,
+				flags: func(fs *flag.FlagSet) {
+					fs.String("feed", "", "only send posts from this followed feed (url or name)")
+					fs.String("keyword", "", "only send posts whose title or description contains this")
+				},
+				complete: func(s *state, flagName string, arg int) []string {
+					if flagName == "feed" {
+						return completeFollowedFeeds(s, true)
+					}
+					return nil
+				},
+				userHandler: handlerWebhookAdd,
+			},
+			{
+				name: "list",
+				summary: "List the current user's webhooks",
+				flags: outputFlag,
+				userHandler: handlerWebhookList,
+			},
+			{
+				name: "rm",
+				summary: "Remove a webhook and its delivery log",
+				usage: "<id>",
+				minArgs: 1,
+				maxArgs: 1,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeWebhooks(s)
+					}
+					return nil
+				},
+				userHandler: handlerWebhookRemove,
+			},
+			{
+				name: "test",
+				summary: "Send a signed ping to a webhook now",
+				usage: "<id>",
+				minArgs: 1,
+				maxArgs: 1,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeWebhooks(s)
+					}
+					return nil
+				},
+				userHandler: handlerWebhookTest,
+			},
+			{
+				name: "log",
+				summary: "Show recent webhook deliveries",
+				usage: "[id]",
+				maxArgs: 1,
+				flags: func(fs *flag.FlagSet) {
+					fs.Int("limit", 20, "maximum number of deliveries")
+					outputFlag(fs)
+				},
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeWebhooks(s)
+					}
+					return nil
+				},
+				userHandler: handlerWebhookLog,
+			},
+			{
+				name: "deliver",
+				summary: "Send the webhook deliveries that are due (agg does this continuously)",
+				handler: handlerWebhookDeliver,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "rule",
+		summary: "Hide, mark read, star or tag posts automatically",
+		subcommands: []commandSpec{
+			{
+				name: "add",
+				summary: "Add a rule, applied to new posts and when browsing",
+				usage: "<pattern>",
+				minArgs: 1,
+				maxArgs: 1,
+				flags: func(fs *flag.FlagSet) {
+					fs.String("match", matchKeyword, "what the pattern is matched against ("+strings.Join(ruleMatches, ", ")+")")
+					fs.String("action", actionHide, "what to do with matching posts ("+strings.Join(ruleActions, ", ")+")")
+					fs.String("tag", "", "tag added by the tag action")
+					fs.String("feed", "", "only apply to this followed feed (url or name)")
+				},
+				complete: func(s *state, flagName string, arg int) []string {
+					switch flagName {
+					case "match":
+						return ruleMatches
+					case "action":
+						return ruleActions
+					case "feed":
+						return completeFollowedFeeds(s, true)
+					}
+					return nil
+				},
+				userHandler: handlerRuleAdd,
+			},
+			{
+				name: "list",
+				summary: "List the current user's rules",
+				flags: outputFlag,
+				userHandler: handlerRuleList,
+			},
+			{
+				name: "rm",
+				summary: "Remove a rule",
+				usage: "<id>",
+				minArgs: 1,
+				maxArgs: 1,
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeRules(s)
+					}
+					return nil
+				},
+				userHandler: handlerRuleRemove,
+			},
+			{
+				name: "test",
+				summary: "Show which recent posts your rules, or one rule, match",
+				usage: "[id]",
+				maxArgs: 1,
+				flags: func(fs *flag.FlagSet) {
+					fs.Int("limit", 100, "number of recent posts to check")
+				},
+				complete: func(s *state, flagName string, arg int) []string {
+					if arg == 0 {
+						return completeRules(s)
+					}
+					return nil
+				},
+				userHandler: handlerRuleTest,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "digest",
+		summary: "Email subscribed users their unread posts since their last digest",
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("dry-run", false, "write the messages to files instead of sending them")
+			fs.String("out", ".", "directory --dry-run writes digest-<user>.eml files to")
+			fs.String("user", "", "only send this user's digest")
+			fs.Int("limit", 100, "maximum number of posts in a digest")
+		},
+		complete: func(s *state, flagName string, arg int) []string {
+			if flagName == "user" {
+				return completeUserNames(s)
+			}
+			return nil
+		},
+		handler: handlerDigest,
+	})
+	cmds.register(commandSpec{
+		name: "digest-email",
+		summary: "Show or set the address your digests go to",
+		usage: "[address]",
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("remove", false, "stop sending you digests")
+		},
+		userHandler: handlerDigestEmail,
+	})
+	cmds.register(commandSpec{
+		name: "migrate",
+		summary: "Apply or roll back database schema migrations",
+		noSchemaCheck: true,
+		subcommands: []commandSpec{
+			{
+				name: "up",
+				summary: "Apply pending migrations, up to version if given",
+				usage: "[version]",
+				maxArgs: 1,
+				noSchemaCheck: true,
+				handler: handlerMigrateUp,
+			},
+			{
+				name: "down",
+				summary: "Roll back the latest migration, or every migration above version",
+				usage: "[version]",
+				maxArgs: 1,
+				noSchemaCheck: true,
+				handler: handlerMigrateDown,
+			},
+			{
+				name: "status",
+				summary: "List migrations and when they were applied",
+				noSchemaCheck: true,
+				handler: handlerMigrateStatus,
+			},
+			{
+				name: "redo",
+				summary: "Roll back the latest migration and apply it again",
+				noSchemaCheck: true,
+				handler: handlerMigrateRedo,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name: "search",
+		summary: "Full-text search over posts from the feeds you follow",
+		usage: "<query>",
+		minArgs: 1,
+		maxArgs: -1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 10, "maximum number of results")
+		},
+		userHandler: handlerSearch,
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/config"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/memory"
+)
+
+//testEnv runs commands the way main does, against an empty memory database
+//and with the config file in a temporary home
+type testEnv struct {
+	t    *testing.T
+	s    *state
+	cmds commands
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	store, err := memory.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &testEnv{
+		t: t,
+		s: &state{
+			db:       memoryStore{store},
+			dbDriver: driverMemory,
+			cfg:      &config.Config{DBURL: "memory://"},
+		},
+		cmds: commands{commands: make(map[string]commandSpec)},
+	}
+	registerCommands(&e.cmds)
+
+	//prompts read stdin, which tests answer with input
+	stdin := stdinReader
+	t.Cleanup(func() { stdinReader = stdin })
+	e.input()
+	return e
+}
+
+//useSQLite moves e to a new, unmigrated SQLite database file
+func (e *testEnv) useSQLite() {
+	e.t.Helper()
+	e.s.cfg.DBURL = "sqlite:" + filepath.Join(e.t.TempDir(), "gator.db")
+	if err := openDatabase(e.s); err != nil {
+		e.t.Fatal(err)
+	}
+	e.t.Cleanup(func() { closeDatabase(e.s) })
+}
+
+//run runs a command line and returns what it printed; prompts, which go to
+//stderr, are dropped
+func (e *testEnv) run(args ...string) (string, error) {
+	e.t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, devNull
+	printed := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		printed <- string(out)
+	}()
+
+	err = e.cmds.run(e.s, command{name: args[0], args: args[1:]})
+	w.Close()
+	os.Stdout, os.Stderr = stdout, stderr
+	return <-printed, err
+}
+
+//mustRun runs a command line that has to succeed
+func (e *testEnv) mustRun(args ...string) string {
+	e.t.Helper()
+	out, err := e.run(args...)
+	if err != nil {
+		e.t.Fatalf("%v: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+//input answers the next prompts with lines, and any after them with EOF
+func (e *testEnv) input(lines ...string) {
+	text := ""
+	for _, line := range lines {
+		text += line + "\n"
+	}
+	stdinReader = bufio.NewReader(strings.NewReader(text))
+}
+
+func (e *testEnv) user(name string) database.User {
+	e.t.Helper()
+	user, err := e.s.db.GetUser(context.Background(), name)
+	if err != nil {
+		e.t.Fatalf("getting user %v: %v", name, err)
+	}
+	return user
+}
+
+func (e *testEnv) feed(url string) database.Feed {
+	e.t.Helper()
+	feed, err := e.s.db.GetFeed(context.Background(), url)
+	if err != nil {
+		e.t.Fatalf("getting feed %v: %v", url, err)
+	}
+	return feed
+}
+
+//fetch saves items as if agg had just fetched them from the feed at url
+func (e *testEnv) fetch(url string, items ...RSSItem) {
+	e.t.Helper()
+	rssfeed := &RSSFeed{}
+	rssfeed.Channel.Item = items
+	if err := savePosts(context.Background(), e.s, e.feed(url), rssfeed); err != nil {
+		e.t.Fatalf("saving posts of %v: %v", url, err)
+	}
+}
+
+//posts lists what browse would show the user, newest first
+func (e *testEnv) posts(name string) []database.GetPostsForUserRow {
+	e.t.Helper()
+	posts, err := e.s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+		UserID: e.user(name).ID,
+		Limit:  100,
+	})
+	if err != nil {
+		e.t.Fatalf("getting posts of %v: %v", name, err)
+	}
+	return posts
+}
+
+//titles lists the titles of the posts the user sees, newest first
+func (e *testEnv) titles(name string) []string {
+	e.t.Helper()
+	titles := []string{}
+	for _, post := range e.posts(name) {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func (e *testEnv) counts() database.CountRecordsRow {
+	e.t.Helper()
+	counts, err := e.s.db.CountRecords(context.Background(), uuid.NullUUID{})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return counts
+}
+
+//testItem is a feed item with a link made from its title
+func testItem(title string, published time.Time) RSSItem {
+	return RSSItem{
+		Title:   title,
+		Link:    "http://example.com/" + strings.ReplaceAll(title, " ", "-"),
+		PubDate: published.Format(time.RFC1123),
+	}
+}
+
+//printedTitles picks the titles out of what a listing command printed
+func printedTitles(out string) []string {
+	titles := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), "- Title: "); ok {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+//browseTitles runs browse and returns the titles it printed
+func (e *testEnv) browseTitles(args ...string) []string {
+	e.t.Helper()
+	return printedTitles(e.mustRun(append([]string{"browse"}, args...)...))
+}
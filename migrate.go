@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var migrationFS embed.FS
+
+//versionTable is the table goose keeps applied versions in; using it keeps
+//databases migrated with goose working
+const versionTable = "goose_db_version"
+
+//migrationDialect is what differs between the postgres and sqlite
+//migrations: their own schema directory and goose's version table
+type migrationDialect struct {
+	dir string
+	tableExists string //query with the table name as $1
+	createTable string
+}
+
+var migrationDialects = map[string]migrationDialect{
+	driverPostgres: {
+		dir: "sql/schema",
+		tableExists: "SELECT to_regclass($1) IS NOT NULL",
+		createTable: `CREATE TABLE ` + versionTable + ` (
+  id SERIAL PRIMARY KEY,
+  version_id BIGINT NOT NULL,
+  is_applied BOOLEAN NOT NULL,
+  tstamp TIMESTAMP DEFAULT now()
+);`,
+	},
+	driverSQLite: {
+		dir: "sql/sqlite/schema",
+		tableExists: "SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = $1",
+		createTable: `CREATE TABLE ` + versionTable + ` (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  version_id INTEGER NOT NULL,
+  is_applied INTEGER NOT NULL,
+  tstamp TIMESTAMP DEFAULT (datetime('now'))
+);`,
+	},
+}
+
+//migration is one goose-style sql file: NNN_name.sql with "-- +goose Up"
+//and "-- +goose Down" sections
+type migration struct {
+	version int64
+	name string
+	up string
+	down string
+}
+
+func loadMigrations(s *state) ([]migration, error) {
+	dialect, ok := migrationDialects[s.dbDriver]
+	if !ok {
+		return nil, fmt.Errorf("%v databases have no schema to migrate", s.dbDriver)
+	}
+	migrationsDir := dialect.dir
+	entries, err := fs.ReadDir(migrationFS, migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading migrations: %v", err)
+	}
+
+	migrations := []migration{}
+	for _, entry := range entries {
+		content, err := fs.ReadFile(migrationFS, path.Join(migrationsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading migration %v: %v", entry.Name(), err)
+		}
+		m, err := parseMigration(entry.Name(), string(content))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("Duplicate migration version %v", migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
+
+func parseMigration(name, content string) (migration, error) {
+	prefix, _, ok := strings.Cut(name, "_")
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if !ok || err != nil || version <= 0 {
+		return migration{}, fmt.Errorf("Migration %v doesn't start with a version number", name)
+	}
+
+	m := migration{version: version, name: name}
+	var section *string
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			section = &m.up
+			continue
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			section = &m.down
+			continue
+		case strings.HasPrefix(trimmed, "-- +goose"):
+			//StatementBegin/End only matter to goose's statement splitting,
+			//each section is sent whole
+			continue
+		}
+		if section != nil {
+			*section += line
+		}
+	}
+
+	if strings.TrimSpace(m.up) == "" {
+		return migration{}, fmt.Errorf("Migration %v has no \"-- +goose Up\" section", name)
+	}
+	return m, nil
+}
+
+//appliedMigrations returns when each applied version was applied; the
+//version table is created on first use like goose does
+func appliedMigrations(ctx context.Context, s *state) (map[int64]time.Time, error) {
+	if err := createVersionTable(ctx, s); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.sqlDB.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM "+versionTable+" ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading schema versions: %v", err)
+	}
+	defer rows.Close()
+
+	//goose used to record rollbacks as is_applied = false rows, the latest
+	//row for a version wins
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var tstamp sql.NullTime
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, fmt.Errorf("Error reading schema versions: %v", err)
+		}
+		if version == 0 {
+			continue
+		}
+		if isApplied {
+			applied[version] = tstamp.Time
+		} else {
+			delete(applied, version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading schema versions: %v", err)
+	}
+	return applied, nil
+}
+
+func createVersionTable(ctx context.Context, s *state) error {
+	dialect := migrationDialects[s.dbDriver]
+	var exists bool
+	err := s.sqlDB.QueryRowContext(ctx, dialect.tableExists, versionTable).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("Error checking schema version: %v", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = s.sqlDB.ExecContext(ctx, dialect.createTable+"\nINSERT INTO "+versionTable+" (version_id, is_applied) VALUES (0, true);")
+	if err != nil {
+		return fmt.Errorf("Error creating %v: %v", versionTable, err)
+	}
+	return nil
+}
+
+//schemaVersion is the highest applied migration, 0 for an empty database
+func schemaVersion(ctx context.Context, s *state) (int64, error) {
+	applied, err := appliedMigrations(ctx, s)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current, nil
+}
+
+//runMigration applies one direction of a migration and records it, both in
+//one transaction
+func runMigration(ctx context.Context, db *sql.DB, m migration, up bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := m.down
+	if up {
+		query = m.up
+	}
+	if strings.TrimSpace(query) != "" {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("Error running %v: %v", m.name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES ($1, true)", m.version)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+versionTable+" WHERE version_id = $1", m.version)
+	}
+	if err != nil {
+		return fmt.Errorf("Error recording %v: %v", m.name, err)
+	}
+	return tx.Commit()
+}
+
+//checkSchema refuses to run against a database that's missing migrations
+//this build relies on
+func checkSchema(ctx context.Context, s *state) error {
+	if s.dbDriver == driverMemory {
+		return nil
+	}
+	migrations, err := loadMigrations(s)
+	if err != nil {
+		return err
+	}
+	current, err := schemaVersion(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if current < latest {
+		return fmt.Errorf("The database schema is at version %v but gator needs version %v, run \"%v migrate up\"", current, latest, programName)
+	}
+	return nil
+}
+
+//duplicateUserNames lists the users sharing their name with another, oldest
+//first, as "name: id, id"
+func duplicateUserNames(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, name FROM users
+WHERE name IN (SELECT name FROM users GROUP BY name HAVING count(*) > 1)
+ORDER BY name, created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("Error finding users with the same name: %v", err)
+	}
+	defer rows.Close()
+
+	duplicates := []string{}
+	last := ""
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("Error finding users with the same name: %v", err)
+		}
+		if len(duplicates) > 0 && name == last {
+			duplicates[len(duplicates)-1] += ", " + id
+		} else {
+			duplicates = append(duplicates, name+": "+id)
+		}
+		last = name
+	}
+	return duplicates, rows.Err()
+}
+
+//handlerMigrateUp applies pending migrations, up to the given version if any
+func handlerMigrateUp(s *state, cmd command) error {
+	ctx := context.Background()
+	migrations, err := loadMigrations(s)
+	if err != nil {
+		return err
+	}
+
+	target := migrations[len(migrations)-1].version
+	if len(cmd.args) > 0 {
+		target, err = strconv.ParseInt(cmd.args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid version %q", cmd.args[0])
+		}
+	}
+
+	applied, err := appliedMigrations(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.version > target {
+			break
+		}
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		//say which users stand in the way of unique names, the index
+		//would only name one of them
+		if strings.HasSuffix(m.name, "_unique_user_names.sql") {
+			duplicates, err := duplicateUserNames(ctx, s.sqlDB)
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("Can't apply %v, users share names (ids oldest first):\n  %v\nRename or delete all but one of each in the database, for example\n  UPDATE users SET name = 'new name' WHERE id = '<id>';\n  DELETE FROM users WHERE id = '<id>';\nthen run \"%v migrate up\" again. A renamed user's app password stops working, they set a new one with app-password.", m.name, strings.Join(duplicates, "\n  "), programName)
+			}
+		}
+		if err := runMigration(ctx, s.sqlDB, m, true); err != nil {
+			return err
+		}
+		fmt.Printf("Applied %v\n", m.name)
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("The database schema is up to date")
+	}
+	return nil
+}
+
+//handlerMigrateDown rolls back the latest migration, or every migration
+//above the given version
+func handlerMigrateDown(s *state, cmd command) error {
+	ctx := context.Background()
+	migrations, err := loadMigrations(s)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, s)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		return fmt.Errorf("No migrations to roll back")
+	}
+
+	target := int64(-1)
+	if len(cmd.args) > 0 {
+		target, err = strconv.ParseInt(cmd.args[0], 10, 64)
+		if err != nil || target < 0 {
+			return fmt.Errorf("Invalid version %q", cmd.args[0])
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if target >= 0 && m.version <= target {
+			break
+		}
+		if err := runMigration(ctx, s.sqlDB, m, false); err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %v\n", m.name)
+		if target < 0 {
+			break
+		}
+	}
+	return nil
+}
+
+//handlerMigrateRedo rolls back the latest migration and applies it again
+func handlerMigrateRedo(s *state, cmd command) error {
+	ctx := context.Background()
+	migrations, err := loadMigrations(s)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if err := runMigration(ctx, s.sqlDB, m, false); err != nil {
+			return err
+		}
+		if err := runMigration(ctx, s.sqlDB, m, true); err != nil {
+			return err
+		}
+		fmt.Printf("Redid %v\n", m.name)
+		return nil
+	}
+	return fmt.Errorf("No migrations to redo")
+}
+
+func handlerMigrateStatus(s *state, cmd command) error {
+	migrations, err := loadMigrations(s)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(context.Background(), s)
+	if err != nil {
+		return err
+	}
+
+	current := int64(0)
+	for version := range applied {
+		current = max(current, version)
+	}
+	fmt.Printf("Schema version: %v (latest %v)\n\n", current, migrations[len(migrations)-1].version)
+
+	fmt.Printf("  %-24v  %v\n", "Applied at", "Migration")
+	for _, m := range migrations {
+		status := "Pending"
+		if t, ok := applied[m.version]; ok {
+			status = t.Format(time.DateTime)
+		}
+		fmt.Printf("  %-24v  %v\n", status, m.name)
+	}
+	return nil
+}
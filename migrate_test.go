@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseMigration(t *testing.T) {
+	m, err := parseMigration("007_things.sql", `-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE things (id INTEGER);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE things;
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.version != 7 || strings.TrimSpace(m.up) != "CREATE TABLE things (id INTEGER);" || strings.TrimSpace(m.down) != "DROP TABLE things;" {
+		t.Fatalf("parsed %+v", m)
+	}
+
+	for name, content := range map[string]string{
+		"things.sql":     "-- +goose Up\nSELECT 1;",
+		"000_things.sql": "-- +goose Up\nSELECT 1;",
+		"008_things.sql": "-- +goose Down\nSELECT 1;",
+	} {
+		if _, err := parseMigration(name, content); err == nil {
+			t.Errorf("parsing %v succeeded", name)
+		}
+	}
+}
+
+func TestMigrateCommands(t *testing.T) {
+	e := newTestEnv(t)
+	if _, err := e.run("migrate", "status"); err == nil {
+		t.Fatal("migrate status on the memory database succeeded")
+	}
+
+	e.useSQLite()
+	migrations, err := loadMigrations(e.s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1]
+
+	if _, err := e.run("register", "alice"); err == nil || !strings.Contains(err.Error(), "migrate up") {
+		t.Fatalf("register on an unmigrated database: got %v, want to be told to migrate", err)
+	}
+	if _, err := e.run("migrate", "down"); err == nil {
+		t.Fatal("migrate down with nothing applied succeeded")
+	}
+
+	e.mustRun("migrate", "up")
+	if out := e.mustRun("migrate", "status"); !strings.Contains(out, fmt.Sprintf("Schema version: %v (latest %v)", latest.version, latest.version)) || strings.Contains(out, "Pending") {
+		t.Fatalf("migrate status after migrating up:\n%v", out)
+	}
+	e.mustRun("register", "alice")
+
+	//rolling back the latest leaves it pending and blocks other commands
+	if out := e.mustRun("migrate", "down"); !strings.Contains(out, latest.name) {
+		t.Fatalf("migrate down rolled back %q, want %v", out, latest.name)
+	}
+	if out := e.mustRun("migrate", "status"); !strings.Contains(out, "Pending                   "+latest.name) {
+		t.Fatalf("migrate status doesn't show %v pending:\n%v", latest.name, out)
+	}
+	if _, err := e.run("users"); err == nil {
+		t.Fatal("users ran on an outdated schema")
+	}
+
+	e.mustRun("migrate", "up")
+	if out := e.mustRun("migrate", "redo"); !strings.Contains(out, "Redid "+latest.name) {
+		t.Fatalf("migrate redo printed %q", out)
+	}
+	e.mustRun("users")
+
+	if _, err := e.run("migrate", "down", "x"); err == nil {
+		t.Fatal("migrate down to an invalid version succeeded")
+	}
+	e.mustRun("migrate", "down", "0")
+	if version, err := schemaVersion(context.Background(), e.s); err != nil || version != 0 {
+		t.Fatalf("the schema is at version %v (%v) after migrate down 0", version, err)
+	}
+}
+
+func TestMigrateRefusesDuplicateUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.useSQLite()
+	e.mustRun("migrate", "up", "4")
+
+	//two racing registers left two bobs, each with an app password
+	ctx := context.Background()
+	older, newer := uuid.New(), uuid.New()
+	now := time.Now()
+	for i, id := range []uuid.UUID{older, newer} {
+		created := now.Add(time.Duration(i) * time.Minute)
+		_, err := e.s.sqlDB.ExecContext(ctx, "INSERT INTO users (id, created_at, updated_at, name) VALUES ($1, $2, $2, 'bob')", id, created)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = e.s.sqlDB.ExecContext(ctx, "INSERT INTO app_passwords (user_id, created_at, key_hash) VALUES ($1, $2, $3)", id, created, id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := e.run("migrate", "up")
+	if err == nil || !strings.Contains(err.Error(), "bob: "+older.String()+", "+newer.String()) {
+		t.Fatalf("migrate up with two bobs: got %v, want them listed", err)
+	}
+	if version, err := schemaVersion(ctx, e.s); err != nil || version != 4 {
+		t.Fatalf("the schema is at version %v (%v), want it left at 4", version, err)
+	}
+	//nobody was renamed or lost their app password
+	var bobs, passwords int
+	if err := e.s.sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM users WHERE name = 'bob'").Scan(&bobs); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.s.sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM app_passwords").Scan(&passwords); err != nil {
+		t.Fatal(err)
+	}
+	if bobs != 2 || passwords != 2 {
+		t.Fatalf("the failed migration left %v bobs and %v app passwords, want both untouched", bobs, passwords)
+	}
+
+	//once the operator renames one, it goes through
+	if _, err := e.s.sqlDB.ExecContext(ctx, "UPDATE users SET name = 'robert' WHERE id = $1", newer); err != nil {
+		t.Fatal(err)
+	}
+	e.mustRun("migrate", "up")
+	if _, err := e.run("register", "bob"); err == nil {
+		t.Fatal("registering a second bob succeeded")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//OPML is how feed readers trade subscription lists; folders are outlines
+//holding the feeds' outlines
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string `xml:"version,attr"`
+	Head opmlHead `xml:"head"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+type opmlHead struct {
+	Title string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlOutline struct {
+	Text string `xml:"text,attr"`
+	Title string `xml:"title,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	XMLURL string `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+//opmlFeed is a feed found in an OPML file, with the folder it was in
+type opmlFeed struct {
+	title string
+	url string
+	folder string
+}
+
+//name is the outline's text, which some exporters leave empty for title
+func (o opmlOutline) name() string {
+	if o.Text != "" {
+		return o.Text
+	}
+	return o.Title
+}
+
+//opmlFeeds flattens the outlines into feeds; a feed nested in several
+//outlines goes in the folder of the innermost one
+func opmlFeeds(outlines []opmlOutline, folder string) []opmlFeed {
+	feeds := []opmlFeed{}
+	for _, outline := range outlines {
+		if outline.XMLURL != "" {
+			feeds = append(feeds, opmlFeed{title: outline.name(), url: outline.XMLURL, folder: folder})
+			continue
+		}
+		feeds = append(feeds, opmlFeeds(outline.Outlines, strings.TrimSpace(outline.name()))...)
+	}
+	return feeds
+}
+
+//buildOPML lists the user's follows, those in a folder under its outline
+func buildOPML(user database.User, follows []database.GetFeedFollowsForUserRow) opmlDocument {
+	sort.Slice(follows, func(i, j int) bool {
+		return follows[i].FeedName < follows[j].FeedName
+	})
+
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title: user.Name + " subscriptions in gator",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	folders := map[string]*opmlOutline{}
+	names := []string{}
+	for _, follow := range follows {
+		feed := opmlOutline{
+			Text: follow.FeedName,
+			Title: follow.FeedName,
+			Type: "rss",
+			XMLURL: follow.FeedUrl,
+		}
+		if !follow.FolderName.Valid {
+			doc.Outlines = append(doc.Outlines, feed)
+			continue
+		}
+		folder, ok := folders[follow.FolderName.String]
+		if !ok {
+			folder = &opmlOutline{Text: follow.FolderName.String, Title: follow.FolderName.String}
+			folders[follow.FolderName.String] = folder
+			names = append(names, follow.FolderName.String)
+		}
+		folder.Outlines = append(folder.Outlines, feed)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Outlines = append(doc.Outlines, *folders[name])
+	}
+	return doc
+}
+
+func handlerOPMLExport(s *state, cmd command, user database.User) error {
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting following feeds: %v", err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if path := cmd.flagString("out"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Error creating %v: %v", path, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return writeXML(w, buildOPML(user, follows))
+}
+
+//handlerOPMLImport follows every feed in an OPML file, adding the ones gator
+//doesn't know yet and filing them in folders named after their outlines. A
+//feed that can't be added is reported and skipped.
+func handlerOPMLImport(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	content, err := os.ReadFile(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Error reading %v: %v", cmd.args[0], err)
+	}
+	var doc opmlDocument
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("%v isn't an OPML file: %v", cmd.args[0], err)
+	}
+
+	imported := 0
+	folders := map[string]bool{}
+	for _, feed := range opmlFeeds(doc.Outlines, "") {
+		//each feed is followed and filed together, or not at all
+		err := inTx(ctx, s, func(tx *state) error {
+			added, err := subscribe(ctx, tx, user, feed.url, feed.title)
+			if err != nil {
+				return err
+			}
+			if feed.folder == "" {
+				return nil
+			}
+			folder, err := ensureFolder(ctx, tx, user, feed.folder)
+			if err != nil {
+				return err
+			}
+			return setFolder(ctx, tx, user, added.ID, folder)
+		})
+		if err != nil {
+			fmt.Printf("Skipping %v: %v\n", feed.url, err)
+			continue
+		}
+		imported++
+		if feed.folder != "" {
+			folders[feed.folder] = true
+		}
+	}
+
+	fmt.Printf("Imported %v feeds into %v folders\n", imported, len(folders))
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//output formats accepted by --output; "text" is the default human output
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+	outputTable  = "table"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputNDJSON, outputCSV, outputTable}
+
+//record is a row of machine-readable output; header and fields line up
+//column for column and make up the csv and table layouts
+type record interface {
+	header() []string
+	fields() []string
+}
+
+type userRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newUserRecord(user database.User, currentUserName string) userRecord {
+	return userRecord{
+		ID:        user.ID,
+		Name:      user.Name,
+		Current:   user.Name == currentUserName,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func (r userRecord) header() []string {
+	return []string{"id", "name", "current", "created_at", "updated_at"}
+}
+
+func (r userRecord) fields() []string {
+	return []string{r.ID.String(), r.Name, fmt.Sprint(r.Current), formatTime(r.CreatedAt), formatTime(r.UpdatedAt)}
+}
+
+type feedRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	UserID        uuid.UUID  `json:"user_id"`
+	AddedBy       string     `json:"added_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+}
+
+func newFeedRecord(feed database.Feed, addedBy string) feedRecord {
+	return feedRecord{
+		ID:            feed.ID,
+		Name:          feed.Name,
+		Url:           feed.Url,
+		UserID:        feed.UserID,
+		AddedBy:       addedBy,
+		CreatedAt:     feed.CreatedAt,
+		UpdatedAt:     feed.UpdatedAt,
+		LastFetchedAt: nullTimePtr(feed.LastFetchedAt),
+	}
+}
+
+func (r feedRecord) header() []string {
+	return []string{"id", "name", "url", "user_id", "added_by", "created_at", "updated_at", "last_fetched_at"}
+}
+
+func (r feedRecord) fields() []string {
+	return []string{r.ID.String(), r.Name, r.Url, r.UserID.String(), r.AddedBy, formatTime(r.CreatedAt), formatTime(r.UpdatedAt), formatTimePtr(r.LastFetchedAt)}
+}
+
+type followRecord struct {
+	ID        uuid.UUID `json:"id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	FeedName  string    `json:"feed_name"`
+	FeedUrl   string    `json:"feed_url"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Folder    string    `json:"folder"`
+	Title     string    `json:"title"`
+	Muted     bool      `json:"muted"`
+	Priority  int32     `json:"priority"`
+	Notify    string    `json:"notify"`
+	Sort      string    `json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newFollowRecord(follow database.GetFeedFollowsForUserRow) followRecord {
+	return followRecord{
+		ID:        follow.ID,
+		FeedID:    follow.FeedID,
+		FeedName:  follow.FeedName,
+		FeedUrl:   follow.FeedUrl,
+		UserID:    follow.UserID,
+		UserName:  follow.UserName,
+		Folder:    follow.FolderName.String,
+		Title:     follow.Title.String,
+		Muted:     follow.Muted,
+		Priority:  follow.Priority,
+		Notify:    follow.Notify,
+		Sort:      follow.SortOrder,
+		CreatedAt: follow.CreatedAt,
+	}
+}
+
+func (r followRecord) header() []string {
+	return []string{"id", "feed_id", "feed_name", "feed_url", "user_id", "user_name", "folder", "title", "muted", "priority", "notify", "sort", "created_at"}
+}
+
+func (r followRecord) fields() []string {
+	return []string{r.ID.String(), r.FeedID.String(), r.FeedName, r.FeedUrl, r.UserID.String(), r.UserName, r.Folder, r.Title, fmt.Sprint(r.Muted), fmt.Sprint(r.Priority), r.Notify, r.Sort, formatTime(r.CreatedAt)}
+}
+
+type postRecord struct {
+	ID          uuid.UUID `json:"id"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	FeedName    string    `json:"feed_name"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	Read        bool      `json:"read"`
+	Starred     bool      `json:"starred"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+func newPostRecord(post database.GetPostsForUserRow) postRecord {
+	return postRecord{
+		ID:          post.ID,
+		FeedID:      post.FeedID,
+		FeedName:    post.FeedName,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: stripHTML(post.Description.String),
+		PublishedAt: post.PublishedAt,
+		CreatedAt:   post.CreatedAt,
+		Read:        post.ReadAt.Valid,
+		Starred:     post.StarredAt.Valid,
+		Tags:        post.Tags,
+	}
+}
+
+//newItemPostRecord builds a post record from the reader APIs' item rows
+func newItemPostRecord(item database.GetItemsForUserRow) postRecord {
+	return postRecord{
+		ID:          item.ID,
+		FeedID:      item.FeedID,
+		FeedName:    item.FeedName,
+		Title:       item.Title,
+		Url:         item.Url,
+		Description: stripHTML(item.Description.String),
+		PublishedAt: item.PublishedAt,
+		CreatedAt:   item.CreatedAt,
+		Read:        item.ReadAt.Valid,
+		Starred:     item.StarredAt.Valid,
+	}
+}
+
+func (r postRecord) header() []string {
+	return []string{"id", "feed_id", "feed_name", "title", "url", "description", "published_at", "created_at", "read", "starred", "tags"}
+}
+
+func (r postRecord) fields() []string {
+	return []string{r.ID.String(), r.FeedID.String(), r.FeedName, r.Title, r.Url, r.Description, formatTime(r.PublishedAt), formatTime(r.CreatedAt), fmt.Sprint(r.Read), fmt.Sprint(r.Starred), strings.Join(r.Tags, ";")}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+//formatTimePtr formats an optional time, empty when unset
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+//shortID is the first eight characters of an id, enough to tell a user's
+//webhooks or rules apart
+func shortID(id uuid.UUID) string {
+	return id.String()[:8]
+}
+
+//nullTimePtr turns a nullable column into a pointer that marshals as null
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+//outputFlag registers --output and its -o shorthand on a listing command
+func outputFlag(fs *flag.FlagSet) {
+	usage := "output format: " + strings.Join(outputFormats, "|")
+	format := fs.String("output", outputText, usage)
+	fs.StringVar(format, "o", outputText, "shorthand for --output")
+}
+
+//outputFormat returns the validated --output value of a listing command
+func (cmd command) outputFormat() (string, error) {
+	format := cmd.flagString("output")
+	if format == "" {
+		return outputText, nil
+	}
+	if err := validateOutputFormat(format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown output format %q, expected one of %v", format, strings.Join(outputFormats, "|"))
+}
+
+//writeRecords renders records in one of the machine-readable formats
+func writeRecords[T record](w io.Writer, format string, records []T) error {
+	switch format {
+	case outputJSON:
+		if records == nil {
+			records = []T{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case outputNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputCSV:
+		writer := csv.NewWriter(w)
+		var zero T
+		if err := writer.Write(zero.header()); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := writer.Write(r.fields()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case outputTable:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		var zero T
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(zero.header(), "\t")))
+		for _, r := range records {
+			fields := r.fields()
+			for i := range fields {
+				fields[i] = strings.Join(strings.Fields(fields[i]), " ")
+			}
+			fmt.Fprintln(writer, strings.Join(fields, "\t"))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("Unsupported output format %q", format)
+}
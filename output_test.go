@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOutputFormats(t *testing.T) {
+	e := newTestEnv(t)
+	if out := e.mustRun("users", "--output", "json"); strings.TrimSpace(out) != "[]" {
+		t.Fatalf("listing no users as json printed %q, want []", out)
+	}
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog, with a comma", "http://example.com/feed")
+	e.mustRun("register", "bob")
+
+	var users []userRecord
+	if err := json.Unmarshal([]byte(e.mustRun("users", "--output", "json")), &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Name != "alice" || users[0].Current || !users[1].Current {
+		t.Fatalf("users as json: %+v, want alice and bob, the current user", users)
+	}
+
+	lines := strings.Split(strings.TrimSpace(e.mustRun("users", "--output", "ndjson")), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("users as ndjson printed %v lines, want one per user", len(lines))
+	}
+	var bob userRecord
+	if err := json.Unmarshal([]byte(lines[1]), &bob); err != nil || bob.Name != "bob" {
+		t.Fatalf("the second ndjson line is %q: %v", lines[1], err)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(e.mustRun("feeds", "--output", "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !slices.Equal(rows[0], feedRecord{}.header()) || rows[1][1] != "blog, with a comma" || rows[1][4] != "alice" {
+		t.Fatalf("feeds as csv: %v", rows)
+	}
+
+	table := strings.Split(e.mustRun("feeds", "--output", "table"), "\n")
+	if !strings.HasPrefix(table[0], "ID") || !strings.Contains(table[0], "ADDED_BY") || !strings.Contains(table[1], "http://example.com/feed") {
+		t.Fatalf("feeds as a table: %q", table)
+	}
+
+	if _, err := e.run("users", "--output", "xml"); err == nil {
+		t.Fatal("an unknown output format was accepted")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+//sessionTTL is how long a password login stays valid
+const sessionTTL = 30 * 24 * time.Hour
+
+//minPasswordLength is the shortest password accepted by register and passwd
+const minPasswordLength = 8
+
+//stdinReader is shared by prompts so buffered input isn't lost between them
+var stdinReader = bufio.NewReader(os.Stdin)
+
+//promptPassword asks for a password without echoing it; when stdin isn't
+//a terminal the password is read as a line, so scripts can pipe it in
+func promptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("Error reading password: %v", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("Error reading password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+//promptNewPassword asks for a new password twice
+func promptNewPassword() (string, error) {
+	password, err := promptPassword("New password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("Password must be at least %v characters", minPasswordLength)
+	}
+
+	confirm, err := promptPassword("Repeat password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != password {
+		return "", fmt.Errorf("Passwords do not match")
+	}
+
+	return password, nil
+}
+
+//confirm asks a yes/no question, taking anything but yes as no
+func confirm(question string) bool {
+	fmt.Fprint(os.Stderr, question+" [y/N] ")
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+//unlockUser makes a password-protected user prove who they are before
+//acting as them; passwordless users are open to anyone
+func unlockUser(user database.User) error {
+	if !user.PasswordHash.Valid {
+		return nil
+	}
+	password, err := promptPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if !checkPassword(user, password) {
+		return fmt.Errorf("Wrong password")
+	}
+	return nil
+}
+
+func hashPassword(password string) (sql.NullString, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("Error hashing password: %v", err)
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+//checkPassword reports whether password matches the user's stored hash
+func checkPassword(user database.User, password string) bool {
+	if !user.PasswordHash.Valid {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password)) == nil
+}
+
+//createSession starts a session for the user and returns its secret token
+func createSession(ctx context.Context, s *state, user database.User) (string, error) {
+	token, hash, err := generateToken(sessionTokenPrefix)
+	if err != nil {
+		return "", fmt.Errorf("Error generating session token: %v", err)
+	}
+
+	_, err = s.db.CreateSession(ctx, database.CreateSessionParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UserID: user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(sessionTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error creating session: %v", err)
+	}
+
+	return token, nil
+}
+
+//getUserBySession resolves a session token, rejecting unknown and expired ones
+func getUserBySession(ctx context.Context, s *state, token string) (database.User, error) {
+	session, err := s.db.GetUserBySession(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && session.ExpiresAt.Before(time.Now())) {
+		return database.User{}, fmt.Errorf("Session expired or invalid, log in again")
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("Error getting session: %v", err)
+	}
+	return session.User, nil
+}
+
+//currentUser returns the logged in user: from the session token for
+//password-protected users, or from the user name in passwordless mode
+func currentUser(ctx context.Context, s *state) (database.User, error) {
+	if s.cfg.SessionToken != "" {
+		return getUserBySession(ctx, s, s.cfg.SessionToken)
+	}
+
+	if s.cfg.CurrentUserName == "" {
+		return database.User{}, fmt.Errorf("Not logged in, run login or register first")
+	}
+	user, err := s.db.GetUser(ctx, s.cfg.CurrentUserName)
+	if err != nil {
+		return database.User{}, fmt.Errorf("Error getting user: %v", err)
+	}
+	if user.PasswordHash.Valid {
+		return database.User{}, fmt.Errorf("User %v has a password, log in again", user.Name)
+	}
+	return user, nil
+}
+
+//logIn saves the user as current in the config, starting a session if the
+//user has a password
+func logIn(ctx context.Context, s *state, user database.User) error {
+	if !user.PasswordHash.Valid {
+		return s.cfg.SetUser(user.Name)
+	}
+
+	token, err := createSession(ctx, s, user)
+	if err != nil {
+		return err
+	}
+	return s.cfg.SetSession(token)
+}
+
+func handlerPasswd(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+
+	//check the current password first
+	if user.PasswordHash.Valid {
+		password, err := promptPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		if !checkPassword(user, password) {
+			return fmt.Errorf("Wrong password")
+		}
+	}
+
+	hash := sql.NullString{}
+	if !cmd.flagBool("remove") {
+		password, err := promptNewPassword()
+		if err != nil {
+			return err
+		}
+		hash, err = hashPassword(password)
+		if err != nil {
+			return err
+		}
+	} else if !user.PasswordHash.Valid {
+		return fmt.Errorf("User %v has no password", user.Name)
+	}
+
+	err := s.db.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID: user.ID,
+		PasswordHash: hash,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting password: %v", err)
+	}
+
+	//sign out every other session and log this one back in
+	if err := s.db.DeleteSessionsForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("Error deleting sessions: %v", err)
+	}
+	user.PasswordHash = hash
+	if err := logIn(ctx, s, user); err != nil {
+		return err
+	}
+
+	if hash.Valid {
+		fmt.Println("Password has been set")
+	} else {
+		fmt.Println("Password has been removed")
+	}
+	return nil
+}
+
+func handlerLogout(s *state, cmd command) error {
+	if s.cfg.SessionToken != "" {
+		if err := s.db.DeleteSession(context.Background(), hashToken(s.cfg.SessionToken)); err != nil {
+			return fmt.Errorf("Error deleting session: %v", err)
+		}
+	}
+
+	if err := s.cfg.SetUser(""); err != nil {
+		return err
+	}
+
+	fmt.Println("Logged out")
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/config"
+)
+
+//pruneInterval is how often agg --prune prunes
+const pruneInterval = time.Hour
+
+//retention is which posts are kept: those published within maxAge, and the
+//newest maxPosts of each feed; zero means no limit. Starred posts are kept
+//whatever their age or position.
+type retention struct {
+	maxAge time.Duration
+	maxPosts int
+}
+
+func (r retention) isSet() bool {
+	return r.maxAge > 0 || r.maxPosts > 0
+}
+
+//configRetention reads the retention set in the config file, if any
+func configRetention(cfg *config.Config) (retention, error) {
+	if cfg.Retention == nil {
+		return retention{}, nil
+	}
+
+	r := retention{maxPosts: cfg.Retention.MaxPostsPerFeed}
+	if cfg.Retention.MaxAge != "" {
+		age, err := parseAge(cfg.Retention.MaxAge)
+		if err != nil {
+			return retention{}, fmt.Errorf("Error in the config's retention max_age: %v", err)
+		}
+		r.maxAge = age
+	}
+	return r, nil
+}
+
+//parseAge parses a duration, also taking a number of days such as "90d"
+func parseAge(value string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q (ex: 90d, 720h)", value)
+		}
+		age = d
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("invalid age %q, it must be positive", value)
+	}
+	return age, nil
+}
+
+//keeps reports whether a post just fetched would survive pruning; position
+//is its place among the feed's fetched posts, newest first
+func (r retention) keeps(published time.Time, position int) bool {
+	if r.maxAge > 0 && published.Before(time.Now().Add(-r.maxAge)) {
+		return false
+	}
+	return r.maxPosts <= 0 || position < r.maxPosts
+}
+
+//prunePosts deletes the posts r doesn't keep, in one transaction
+func prunePosts(ctx context.Context, s *state, r retention) (old int64, excess int64, err error) {
+	err = inTx(ctx, s, func(tx *state) error {
+		if r.maxAge > 0 {
+			old, err = tx.db.PruneOldPosts(ctx, time.Now().Add(-r.maxAge))
+			if err != nil {
+				return fmt.Errorf("Error pruning old posts: %v", err)
+			}
+		}
+		if r.maxPosts > 0 {
+			excess, err = tx.db.PruneExcessPosts(ctx, int64(r.maxPosts))
+			if err != nil {
+				return fmt.Errorf("Error pruning posts: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return old, excess, nil
+}
+
+func handlerPrune(s *state, cmd command) error {
+	r, err := configRetention(s.cfg)
+	if err != nil {
+		return err
+	}
+	if value := cmd.flagString("max-age"); value != "" {
+		r.maxAge, err = parseAge(value)
+		if err != nil {
+			return err
+		}
+	}
+	if n := cmd.flagInt("max-posts"); n > 0 {
+		r.maxPosts = n
+	}
+	if !r.isSet() {
+		return fmt.Errorf("Nothing to prune: give --max-age or --max-posts, or set a retention in the config file")
+	}
+
+	old, excess, err := prunePosts(context.Background(), s, r)
+	if err != nil {
+		return err
+	}
+
+	if r.maxAge > 0 {
+		fmt.Printf("Deleted %v posts published more than %v ago\n", old, formatAge(r.maxAge))
+	}
+	if r.maxPosts > 0 {
+		fmt.Printf("Deleted %v posts beyond the newest %v of their feed\n", excess, r.maxPosts)
+	}
+	return nil
+}
+
+//formatAge shows whole days as such, the way they're usually given
+func formatAge(age time.Duration) string {
+	if age%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%vd", int(age/(24*time.Hour)))
+	}
+	return age.String()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/config"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func TestPrune(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	day := 24 * time.Hour
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("today", now),
+		testItem("last week", now.Add(-7*day)),
+		testItem("last month", now.Add(-30*day)),
+		testItem("last year", now.Add(-365*day)),
+	)
+
+	//starred posts are kept whatever their age
+	for _, post := range e.posts("alice") {
+		if post.Title == "last year" {
+			err := e.s.db.SetPostStarred(context.Background(), database.SetPostStarredParams{
+				UserID:    e.user("alice").ID,
+				PostID:    post.ID,
+				StarredAt: sql.NullTime{Time: now, Valid: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if _, err := e.run("prune"); err == nil {
+		t.Fatal("prune without a retention succeeded")
+	}
+	if _, err := e.run("prune", "--max-age", "soon"); err == nil {
+		t.Fatal("prune with an invalid age succeeded")
+	}
+
+	e.mustRun("prune", "--max-age", "10d")
+	if got, want := e.titles("alice"), []string{"today", "last week", "last year"}; !slices.Equal(got, want) {
+		t.Fatalf("after pruning by age alice sees %v, want %v", got, want)
+	}
+
+	e.mustRun("prune", "--max-posts", "1")
+	if got, want := e.titles("alice"), []string{"today", "last year"}; !slices.Equal(got, want) {
+		t.Fatalf("after pruning by count alice sees %v, want %v", got, want)
+	}
+}
+
+func TestRetentionSkipsPrunedPosts(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.cfg.Retention = &config.Retention{MaxAge: "10d", MaxPostsPerFeed: 2}
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	day := 24 * time.Hour
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("today", now),
+		testItem("yesterday", now.Add(-day)),
+		testItem("two days ago", now.Add(-2*day)),
+		testItem("last month", now.Add(-30*day)),
+	)
+	if got, want := e.titles("alice"), []string{"today", "yesterday"}; !slices.Equal(got, want) {
+		t.Fatalf("with a retention alice sees %v, want %v", got, want)
+	}
+
+	//what was saved survives pruning by the same retention
+	e.mustRun("prune")
+	if got, want := e.titles("alice"), []string{"today", "yesterday"}; !slices.Equal(got, want) {
+		t.Fatalf("after pruning alice sees %v, want %v", got, want)
+	}
+}
+
+func TestRetentionKeepsUndatedPosts(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.cfg.Retention = &config.Retention{MaxAge: "10d", MaxPostsPerFeed: 2}
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	undated := testItem("undated", time.Now())
+	undated.PubDate = ""
+	numeric := testItem("numeric zone", time.Now())
+	numeric.PubDate = time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+	old := testItem("old", time.Now())
+	old.PubDate = time.Now().Add(-30 * 24 * time.Hour).Format(time.RFC1123Z)
+
+	fetch := func() []database.GetPostsForUserRow {
+		e.fetch("http://example.com/feed", undated, numeric, old)
+		e.mustRun("prune")
+		return e.posts("alice")
+	}
+	first := fetch()
+	if got, want := e.titles("alice"), []string{"undated", "numeric zone"}; !slices.Equal(got, want) {
+		t.Fatalf("alice sees %v, want %v", got, want)
+	}
+	if age := time.Since(first[0].PublishedAt); age < 0 || age > time.Minute {
+		t.Errorf("the undated post was saved as published %v ago, want when it was fetched", age)
+	}
+
+	//pruning keeps the undated post, so fetching again doesn't bring it
+	//back as a new post
+	second := fetch()
+	if len(second) != 2 || second[0].ID != first[0].ID || second[1].ID != first[1].ID {
+		t.Fatalf("fetching again replaced the posts: %v then %v", first, second)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//maxItemIDs caps the item ids returned in one Fever or Google Reader
+//response, enough for clients to sync every unread item
+const maxItemIDs = 10000
+
+//appPasswordKey is the key for a user's app password. Fever clients send
+//md5("name:password") as their api key, so that is what identifies the user
+//to both reader APIs; only a hash of it is stored.
+func appPasswordKey(name, password string) string {
+	sum := md5.Sum([]byte(name + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+//userByAppKey resolves the user whose app password produced the key
+func userByAppKey(ctx context.Context, s *state, key string) (database.User, error) {
+	if key == "" {
+		return database.User{}, fmt.Errorf("App password %w", errNotFound)
+	}
+	row, err := s.db.GetUserByAppPassword(ctx, hashToken(strings.ToLower(key)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("App password %w", errNotFound)
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("Error getting app password: %v", err)
+	}
+	return row.User, nil
+}
+
+func handlerAppPassword(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+
+	if cmd.flagBool("remove") {
+		n, err := s.db.DeleteAppPassword(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("Error removing app password: %v", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("User %v has no app password", user.Name)
+		}
+		fmt.Println("App password has been removed, apps using it are signed out")
+		return nil
+	}
+
+	password, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+
+	err = s.db.SetAppPassword(ctx, database.SetAppPasswordParams{
+		UserID: user.ID,
+		CreatedAt: time.Now(),
+		KeyHash: hashToken(appPasswordKey(user.Name, password)),
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting app password: %v", err)
+	}
+
+	base := strings.TrimSuffix(cmd.flagString("base-url"), "/")
+	fmt.Println("App password has been set, any previous one no longer works.")
+	fmt.Printf("Sign in to mobile apps as %v with it:\n", user.Name)
+	fmt.Printf("  Fever:         %v/fever/\n", base)
+	fmt.Printf("  Google Reader: %v\n", base)
+	return nil
+}
+
+//subscribe follows the feed at url, adding it first if nobody has yet. New
+//feeds are named after title, or after the feed's own title when empty.
+func subscribe(ctx context.Context, s *state, user database.User, url, title string) (database.Feed, error) {
+	feed, _, err := followFeed(ctx, s, user, url)
+	if err == nil {
+		return feed, nil
+	}
+	if errors.Is(err, errConflict) {
+		return getFeedByURL(ctx, s, url)
+	}
+	if !errors.Is(err, errNotFound) {
+		return database.Feed{}, err
+	}
+
+	if title == "" {
+		rssFeed, err := fetchFeed(ctx, url)
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("Error fetching feed: %v", err)
+		}
+		title = rssFeed.Channel.Title
+	}
+	if title == "" {
+		title = url
+	}
+	return addFeed(ctx, s, user, title, url)
+}
+
+//getItemsBySeq returns the user's items with the given integer ids; ids of
+//posts from feeds the user doesn't follow are left out
+func getItemsBySeq(ctx context.Context, s *state, user database.User, seqs []int64) ([]database.GetItemsForUserRow, error) {
+	if len(seqs) == 0 {
+		return nil, nil
+	}
+	items, err := s.db.GetItemsForUser(ctx, database.GetItemsForUserParams{
+		UserID: user.ID,
+		Seqs: seqs,
+		Limit: int32(len(seqs)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting items: %v", err)
+	}
+	return items, nil
+}
+
+func setItemRead(ctx context.Context, s *state, user database.User, postID uuid.UUID, read bool) error {
+	readAt := sql.NullTime{}
+	if read {
+		readAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	err := s.db.SetPostRead(ctx, database.SetPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		ReadAt: readAt,
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting read state: %v", err)
+	}
+	return nil
+}
+
+func setItemStarred(ctx context.Context, s *state, user database.User, postID uuid.UUID, starred bool) error {
+	starredAt := sql.NullTime{}
+	if starred {
+		starredAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	err := s.db.SetPostStarred(ctx, database.SetPostStarredParams{
+		UserID: user.ID,
+		PostID: postID,
+		StarredAt: starredAt,
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting star: %v", err)
+	}
+	return nil
+}
+
+//markRead marks the user's items that arrived before a time as read, in one
+//followed feed, one folder or all of them
+func markRead(ctx context.Context, s *state, user database.User, feedID, folderID uuid.NullUUID, before time.Time) error {
+	_, err := s.db.MarkPostsRead(ctx, database.MarkPostsReadParams{
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID: user.ID,
+		FeedID: feedID,
+		FolderID: folderID,
+		Before: before,
+	})
+	if err != nil {
+		return fmt.Errorf("Error marking posts read: %v", err)
+	}
+	return nil
+}
+
+//feedFolders maps the feeds the user follows in a folder to the folder's
+//name, as the reader apps show folders
+func feedFolders(ctx context.Context, s *state, user database.User) (map[uuid.UUID]string, error) {
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting following feeds: %v", err)
+	}
+	folders := map[uuid.UUID]string{}
+	for _, follow := range follows {
+		if follow.FolderName.Valid {
+			folders[follow.FeedID] = follow.FolderName.String
+		}
+	}
+	return folders, nil
+}
+
+//itemHTML is the richest HTML we have for an item
+func itemHTML(item database.GetItemsForUserRow) string {
+	if item.Content.Valid && item.Content.String != "" {
+		return item.Content.String
+	}
+	return item.Description.String
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//resetScope is what reset deletes: everything, the posts or the feeds (of
+//every user, or just the feeds user added), or user with all they own
+type resetScope struct {
+	posts bool
+	feeds bool
+	user *database.User
+}
+
+func (r resetScope) userID() uuid.NullUUID {
+	if r.user == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: r.user.ID, Valid: true}
+}
+
+//empty reports whether r has nothing to delete
+func (r resetScope) empty(counts database.CountRecordsRow) bool {
+	switch {
+	case r.posts:
+		return counts.Posts == 0
+	case r.feeds:
+		return counts.Feeds == 0
+	default:
+		return counts.Users == 0
+	}
+}
+
+//describe says what r deletes, given what CountRecords counted for it
+func (r resetScope) describe(counts database.CountRecordsRow) string {
+	switch {
+	case r.posts && r.user != nil:
+		return fmt.Sprintf("%v posts of the feeds %v added", counts.Posts, r.user.Name)
+	case r.posts:
+		return fmt.Sprintf("%v posts", counts.Posts)
+	case r.feeds && r.user != nil:
+		return fmt.Sprintf("%v feeds %v added, with their %v follows and %v posts", counts.Feeds, r.user.Name, counts.Follows, counts.Posts)
+	case r.feeds:
+		return fmt.Sprintf("%v feeds with their %v follows and %v posts", counts.Feeds, counts.Follows, counts.Posts)
+	case r.user != nil:
+		return fmt.Sprintf("user %v, the %v feeds they added with their %v follows and %v posts, and everything else they own", r.user.Name, counts.Feeds, counts.Follows, counts.Posts)
+	default:
+		return fmt.Sprintf("%v users, %v feeds, %v follows and %v posts", counts.Users, counts.Feeds, counts.Follows, counts.Posts)
+	}
+}
+
+//remove deletes what r covers, the rest cascading from it
+func (r resetScope) remove(ctx context.Context, db Store) error {
+	var err error
+	switch {
+	case r.posts:
+		_, err = db.DeletePosts(ctx, r.userID())
+	case r.feeds:
+		_, err = db.DeleteFeeds(ctx, r.userID())
+	case r.user != nil:
+		err = db.DeleteUser(ctx, r.user.ID)
+	default:
+		err = db.DeleteRecords(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("Error resetting data: %v", err)
+	}
+	return nil
+}
+
+//backupDatabase copies the whole database to path: a sqlite database file,
+//a memory snapshot, or a pg_dump script for postgres
+func backupDatabase(ctx context.Context, s *state, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("Backup file %v already exists", path)
+	}
+
+	switch s.dbDriver {
+	case driverSQLite:
+		if _, err := s.sqlDB.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+			return fmt.Errorf("Error backing up database: %v", err)
+		}
+	case driverMemory:
+		if err := s.db.(memoryStore).Backup(path); err != nil {
+			return fmt.Errorf("Error backing up database: %v", err)
+		}
+	default:
+		conn, password, err := splitPassword(s.cfg.DBURL)
+		if err != nil {
+			return fmt.Errorf("Error backing up database: %v", err)
+		}
+		//the password goes in the environment, where other users can't
+		//see it as they can pg_dump's arguments
+		dump := exec.CommandContext(ctx, "pg_dump", "--dbname", conn, "--file", path)
+		dump.Env = os.Environ()
+		if password != "" {
+			dump.Env = append(dump.Env, "PGPASSWORD="+password)
+		}
+		dump.Stderr = os.Stderr
+		if err := dump.Run(); errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("Error backing up database: pg_dump isn't installed, install the postgres client or reset without --backup")
+		} else if err != nil {
+			os.Remove(path)
+			return fmt.Errorf("Error backing up database: %v", err)
+		}
+	}
+	return nil
+}
+
+//splitPassword takes the password out of a postgres url or key=value
+//connection string, returning the connection string without it
+func splitPassword(dbURL string) (string, string, error) {
+	if u, err := url.Parse(dbURL); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		password := ""
+		if u.User != nil {
+			if p, ok := u.User.Password(); ok {
+				password = p
+				u.User = url.User(u.User.Username())
+			}
+		}
+		query := u.Query()
+		if query.Has("password") {
+			password = query.Get("password")
+			query.Del("password")
+			u.RawQuery = query.Encode()
+		}
+		return u.String(), password, nil
+	}
+
+	pairs, err := parseConnString(dbURL)
+	if err != nil {
+		return "", "", err
+	}
+	password := ""
+	kept := []string{}
+	for _, pair := range pairs {
+		if pair[0] == "password" {
+			password = pair[1]
+			continue
+		}
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(pair[1])
+		kept = append(kept, pair[0]+"='"+value+"'")
+	}
+	return strings.Join(kept, " "), password, nil
+}
+
+//parseConnString splits a key=value connection string into its pairs;
+//values may be quoted with ' and escape characters with \
+func parseConnString(conn string) ([][2]string, error) {
+	pairs := [][2]string{}
+	rest := strings.TrimSpace(conn)
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok || strings.TrimSpace(key) == "" || strings.ContainsAny(strings.TrimSpace(key), " \t\n") {
+			return nil, fmt.Errorf("Invalid connection string, expected key=value pairs")
+		}
+		value = strings.TrimLeft(value, " \t\n")
+
+		quoted := strings.HasPrefix(value, "'")
+		if quoted {
+			value = value[1:]
+		}
+		var b strings.Builder
+		i := 0
+		for ; i < len(value); i++ {
+			c := value[i]
+			if c == '\\' && i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+				continue
+			}
+			if (quoted && c == '\'') || (!quoted && strings.IndexByte(" \t\n", c) >= 0) {
+				break
+			}
+			b.WriteByte(c)
+		}
+		if quoted {
+			if i == len(value) {
+				return nil, fmt.Errorf("Invalid connection string, unterminated quote in %v", strings.TrimSpace(key))
+			}
+			i++
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(key), b.String()})
+		rest = strings.TrimSpace(value[i:])
+	}
+	return pairs, nil
+}
+
+func handlerReset(s *state, cmd command) error {
+	ctx := context.Background()
+	scope := resetScope{posts: cmd.flagBool("posts"), feeds: cmd.flagBool("feeds")}
+	if scope.posts && scope.feeds {
+		return fmt.Errorf("Give --posts or --feeds, not both: deleting feeds deletes their posts too")
+	}
+	if name := cmd.flagString("user"); name != "" {
+		user, err := getUser(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		//reset can delete what deluser would, so it asks for the same
+		//password
+		if err := unlockUser(user); err != nil {
+			return err
+		}
+		scope.user = &user
+	}
+
+	counts, err := s.db.CountRecords(ctx, scope.userID())
+	if err != nil {
+		return fmt.Errorf("Error counting records: %v", err)
+	}
+	if scope.empty(counts) {
+		fmt.Println("Nothing to reset")
+		return nil
+	}
+	if !cmd.flagBool("yes") && !confirm(fmt.Sprintf("Delete %v?", scope.describe(counts))) {
+		return fmt.Errorf("Cancelled, nothing was deleted")
+	}
+
+	if path := cmd.flagString("backup"); path != "" {
+		path = expandHome(path)
+		if err := backupDatabase(ctx, s, path); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up the database to %v\n", path)
+	}
+
+	//the session goes with the user, so check who's logged in first
+	loggedIn := false
+	if !scope.posts && !scope.feeds {
+		current, err := currentUser(ctx, s)
+		loggedIn = err == nil && (scope.user == nil || current.ID == scope.user.ID)
+	}
+
+	//count again with the deletes, in case something changed since asking
+	err = inTx(ctx, s, func(tx *state) error {
+		counts, err = tx.db.CountRecords(ctx, scope.userID())
+		if err != nil {
+			return fmt.Errorf("Error counting records: %v", err)
+		}
+		//deleting a user's feeds takes other users' follows with them
+		if scope.user != nil && !scope.posts {
+			if _, err := checkSharedFeeds(ctx, tx, *scope.user, cmd.flagBool("force")); err != nil {
+				return err
+			}
+		}
+		return scope.remove(ctx, tx.db)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %v\n", scope.describe(counts))
+	if loggedIn {
+		if err := s.cfg.SetUser(""); err != nil {
+			return err
+		}
+		fmt.Println("Logged out")
+	}
+	return nil
+}
@@ -3,13 +3,16 @@ package main
 import (
 	"net/http"
 	"time"
-	"log"
 	"io"
 	"encoding/xml"
 	"context"
 	"html"
 	"fmt"
 	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
@@ -29,6 +32,10 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
 }
 
 func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
@@ -40,7 +47,7 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	//Create request
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
-		log.Fatalf("Error creating request: %v", err)
+		return nil, fmt.Errorf("Error creating request: %v", err)
 	}
 
 	//Set header
@@ -49,20 +56,20 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	//Make the request
 	res, err := client.Do(req)
 	if err != nil {
-		log.Fatalf("Error making the request: %v", err)
+		return nil, fmt.Errorf("Error making the request: %v", err)
 	}
 	defer res.Body.Close()
 
 	//Read the response
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Fatalf("Error reading the response: %v", err)
+		return nil, fmt.Errorf("Error reading the response: %v", err)
 	}
 
 	//Unmarshal into structs
 	var feed RSSFeed
 	if err := xml.Unmarshal(body, &feed); err != nil {
-		log.Fatalf("Error unmarshalling: %v", err)
+		return nil, fmt.Errorf("Error unmarshalling: %v", err)
 	}
 
 	//unescaping strings
@@ -71,6 +78,7 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	for i := range feed.Channel.Item {
 		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
+		feed.Channel.Item[i].Content = html.UnescapeString(feed.Channel.Item[i].Content)
 	}
 
 	return &feed, nil
@@ -83,8 +91,75 @@ func scrapeFeeds(s *state) error {
 		return fmt.Errorf("Error getting next feed to fetch: %v", err)
 	}
 
-	//Mark feed fetched
-	err = s.db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
+	return scrapeFeed(s, feed)
+}
+
+//scrapeFeed fetches a single feed and saves its new posts, in one
+//transaction with marking it fetched so a failure part way saves nothing
+func scrapeFeed(s *state, feed database.Feed) error {
+	ctx := context.Background()
+
+	//Fetch feed, outside the transaction so it isn't held open meanwhile
+	rssfeed, err := fetchFeed(ctx, feed.Url)
+	if err != nil {
+		err = fmt.Errorf("Error fetching feed: %v", err)
+	} else {
+		err = inTx(ctx, s, func(tx *state) error {
+			if err := markFeedFetched(ctx, tx, feed); err != nil {
+				return err
+			}
+			return savePosts(ctx, tx, feed, rssfeed)
+		})
+	}
+
+	if err != nil {
+		//a failed fetch still counts, so a broken feed goes to the back of
+		//the queue rather than being retried ahead of the others
+		if markErr := markFeedFetched(ctx, s, feed); markErr != nil {
+			log.Println(markErr)
+		}
+		return err
+	}
+	return nil
+}
+
+//pubDateLayouts are the date formats read in pubDate, RFC 822 dates with a
+//zone name or a numeric offset
+var pubDateLayouts = []string{time.RFC1123, time.RFC1123Z}
+
+//itemPublished is when the item was published, or fetched when it has no
+//date we can read, so that it ages from when it was first seen
+func itemPublished(item RSSItem, fetched time.Time) time.Time {
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(item.PubDate)); err == nil {
+			return t
+		}
+	}
+	return fetched
+}
+
+//publishedPositions numbers the items newest first, by the dates
+//itemPublished gives them
+func publishedPositions(items []RSSItem, fetched time.Time) []int {
+	order := make([]int, len(items))
+	published := make([]time.Time, len(items))
+	for i, item := range items {
+		order[i] = i
+		published[i] = itemPublished(item, fetched)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return published[order[a]].After(published[order[b]])
+	})
+
+	positions := make([]int, len(items))
+	for position, i := range order {
+		positions[i] = position
+	}
+	return positions
+}
+
+func markFeedFetched(ctx context.Context, s *state, feed database.Feed) error {
+	err := s.db.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
 		LastFetchedAt: sql.NullTime{
 			Time: time.Now(),
 			Valid: true,
@@ -94,19 +169,37 @@ func scrapeFeeds(s *state) error {
 	if err != nil {
 		return fmt.Errorf("Error marking feed fetched: %v", err)
 	}
+	return nil
+}
+
+//savePosts saves the fetched posts that are new, running the followers'
+//rules on them and passing them on to the streams and webhooks
+func savePosts(ctx context.Context, s *state, feed database.Feed, rssfeed *RSSFeed) error {
+	//rules of the feed's followers run on each new post
+	rules, err := loadFeedRules(ctx, s, feed.ID)
+	if err != nil {
+		return err
+	}
 
-	//Fetch feed
-	rssfeed, err := fetchFeed(context.Background(), feed.Url)
+	//posts the retention would prune straight away aren't saved, or each
+	//fetch would bring them back
+	r, err := configRetention(s.cfg)
 	if err != nil {
-		return fmt.Errorf("Error fetching feed: %v", err)
+		return err
 	}
+	fetched := time.Now()
+	positions := publishedPositions(rssfeed.Channel.Item, fetched)
 
 	//saving posts from feeds
-	for _, item := range rssfeed.Channel.Item {
-		//parsing PubDate into time.Time
-		t, err := time.Parse(time.RFC1123, item.PubDate)
-		
-		_, err = s.db.CreatePost(context.Background(), database.CreatePostParams{
+	for i, item := range rssfeed.Channel.Item {
+		//undated items are saved as published now, so prune judges them by
+		//the same date keeps does
+		t := itemPublished(item, fetched)
+		if !r.keeps(t, positions[i]) {
+			continue
+		}
+
+		post, err := s.db.CreatePost(ctx, database.CreatePostParams{
 			ID: uuid.New(),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -118,10 +211,63 @@ func scrapeFeeds(s *state) error {
 			},
 			PublishedAt: t,
 			FeedID: feed.ID,
+			Content: sql.NullString{
+				String: item.Content,
+				Valid: item.Content != "",
+			},
+			Author: itemAuthor(item),
+			Categories: itemCategories(item),
 		})
-		if err != nil && err.Error() != `pq: duplicate key value violates unique constraint "posts_url_key"`{
+		//no row back means the post was saved already
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
 			return fmt.Errorf("Error creating post: %v", err)
 		}
+
+		//the follow-ups are nested transactions, so one failing is only
+		//logged and doesn't undo the posts
+		err = inTx(ctx, s, func(tx *state) error {
+			return applyRules(ctx, tx, rules, post)
+		})
+		if err != nil {
+			log.Println(err)
+		}
+
+		//tell the running servers, a missed notification only delays the
+		//post until the client next asks for it
+		err = inTx(ctx, s, func(tx *state) error {
+			return notifyNewPost(tx, post)
+		})
+		if err != nil {
+			log.Printf("Error notifying new post: %v", err)
+		}
+		err = inTx(ctx, s, func(tx *state) error {
+			return enqueueWebhooks(tx, post)
+		})
+		if err != nil {
+			log.Printf("Error queueing webhooks: %v", err)
+		}
 	}
 	return nil
 }
+
+//itemAuthor prefers dc:creator, which is a name, over RSS's author, which
+//is meant to be an email address
+func itemAuthor(item RSSItem) sql.NullString {
+	author := strings.TrimSpace(item.Creator)
+	if author == "" {
+		author = strings.TrimSpace(item.Author)
+	}
+	return sql.NullString{String: author, Valid: author != ""}
+}
+
+//itemCategories is never nil: postgres saves a nil array as NULL, which the
+//categories column refuses
+func itemCategories(item RSSItem) []string {
+	if item.Categories == nil {
+		return []string{}
+	}
+	return item.Categories
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//recordingStore keeps the posts it's asked to create
+type recordingStore struct {
+	Store
+	created []database.CreatePostParams
+}
+
+func (r *recordingStore) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	r.created = append(r.created, arg)
+	return r.Store.CreatePost(ctx, arg)
+}
+
+func TestSavePostsWithoutCategories(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	rssfeed := &RSSFeed{}
+	err := xml.Unmarshal([]byte(`<rss><channel>
+		<item><title>plain</title><link>http://example.com/plain</link></item>
+		<item><title>tagged</title><link>http://example.com/tagged</link><category>go</category></item>
+	</channel></rss>`), rssfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &recordingStore{Store: e.s.db}
+	e.s.db = store
+	if err := savePosts(context.Background(), e.s, e.feed("http://example.com/feed"), rssfeed); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.created) != 2 {
+		t.Fatalf("saved %v posts, want 2", len(store.created))
+	}
+	//a nil slice would be a NULL array on postgres
+	if categories := store.created[0].Categories; categories == nil || len(categories) != 0 {
+		t.Errorf("the item without categories was saved with %#v, want an empty array", categories)
+	}
+	if categories := store.created[1].Categories; len(categories) != 1 || categories[0] != "go" {
+		t.Errorf("the tagged item was saved with %#v", categories)
+	}
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//what a rule looks at
+const (
+	matchKeyword = "keyword"
+	matchRegex = "regex"
+	matchAuthor = "author"
+	matchCategory = "category"
+)
+
+var ruleMatches = []string{matchKeyword, matchRegex, matchAuthor, matchCategory}
+
+//what a rule does to the posts it matches
+const (
+	actionHide = "hide"
+	actionRead = "read"
+	actionStar = "star"
+	actionTag = "tag"
+)
+
+var ruleActions = []string{actionHide, actionRead, actionStar, actionTag}
+
+//postRule is a rule ready to match posts, with its regex compiled once
+type postRule struct {
+	database.Rule
+	re *regexp.Regexp
+}
+
+func newPostRule(rule database.Rule) (postRule, error) {
+	r := postRule{Rule: rule}
+	if rule.Match == matchRegex {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return postRule{}, fmt.Errorf("Invalid regex %q: %v", rule.Pattern, err)
+		}
+		r.re = re
+	}
+	return r, nil
+}
+
+//rulePost is the part of a post rules look at
+type rulePost struct {
+	FeedID uuid.UUID
+	Title string
+	Description string
+	Content string
+	Author string
+	Categories []string
+}
+
+func (p rulePost) text() string {
+	return p.Title + "\n" + stripHTML(p.Description) + "\n" + stripHTML(p.Content)
+}
+
+//matches reports whether the rule applies to the post. Keywords, authors
+//and categories ignore case; regexes can ask for it with (?i).
+func (r postRule) matches(p rulePost) bool {
+	if r.FeedID.Valid && r.FeedID.UUID != p.FeedID {
+		return false
+	}
+
+	switch r.Match {
+	case matchKeyword:
+		return strings.Contains(strings.ToLower(p.text()), strings.ToLower(r.Pattern))
+	case matchRegex:
+		return r.re.MatchString(p.text())
+	case matchAuthor:
+		return p.Author != "" && strings.Contains(strings.ToLower(p.Author), strings.ToLower(r.Pattern))
+	case matchCategory:
+		for _, category := range p.Categories {
+			if strings.EqualFold(strings.TrimSpace(category), r.Pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//describe sums up a rule on one line
+func (r postRule) describe() string {
+	action := r.Action
+	if r.Action == actionTag {
+		action += " " + r.Tag.String
+	}
+	return fmt.Sprintf("%v %v %q", action, r.Match, r.Pattern)
+}
+
+//loadFeedRules gets the rules of everyone following a feed that apply to it
+func loadFeedRules(ctx context.Context, s *state, feedID uuid.UUID) ([]postRule, error) {
+	rules, err := s.db.GetRulesForFeed(ctx, feedID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting rules: %v", err)
+	}
+	return compileRules(rules), nil
+}
+
+//compileRules skips a rule that doesn't compile rather than failing the
+//fetch or listing it's used in
+func compileRules(rules []database.Rule) []postRule {
+	compiled := []postRule{}
+	for _, rule := range rules {
+		r, err := newPostRule(rule)
+		if err != nil {
+			log.Printf("Skipping rule %v: %v", shortID(rule.ID), err)
+			continue
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled
+}
+
+//applyRules runs rules on a post that was just saved; each rule's action
+//is applied for the user who owns it
+func applyRules(ctx context.Context, s *state, rules []postRule, post database.Post) error {
+	p := rulePost{
+		FeedID: post.FeedID,
+		Title: post.Title,
+		Description: post.Description.String,
+		Content: post.Content.String,
+		Author: post.Author.String,
+		Categories: post.Categories,
+	}
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for _, rule := range rules {
+		if !rule.matches(p) {
+			continue
+		}
+
+		var err error
+		switch rule.Action {
+		case actionHide:
+			err = s.db.HidePost(ctx, database.HidePostParams{UserID: rule.UserID, PostID: post.ID, HiddenAt: now})
+		case actionRead:
+			err = s.db.SetPostRead(ctx, database.SetPostReadParams{UserID: rule.UserID, PostID: post.ID, ReadAt: now})
+		case actionStar:
+			err = s.db.SetPostStarred(ctx, database.SetPostStarredParams{UserID: rule.UserID, PostID: post.ID, StarredAt: now})
+		case actionTag:
+			err = s.db.AddPostTag(ctx, database.AddPostTagParams{UserID: rule.UserID, PostID: post.ID, Tag: rule.Tag.String})
+		}
+		if err != nil {
+			return fmt.Errorf("Error applying rule %v: %v", shortID(rule.ID), err)
+		}
+	}
+	return nil
+}
+
+//applyRulesToListing applies the user's current rules to posts being
+//listed, so rules added after a post was fetched still take effect. Hidden
+//posts are dropped.
+func applyRulesToListing(rules []postRule, posts []database.GetPostsForUserRow) []database.GetPostsForUserRow {
+	listed := []database.GetPostsForUserRow{}
+	for _, post := range posts {
+		p := newRulePost(post)
+		hidden := false
+		for _, rule := range rules {
+			if !rule.matches(p) {
+				continue
+			}
+			switch rule.Action {
+			case actionHide:
+				hidden = true
+			case actionRead:
+				if !post.ReadAt.Valid {
+					post.ReadAt = sql.NullTime{Time: time.Now(), Valid: true}
+				}
+			case actionStar:
+				if !post.StarredAt.Valid {
+					post.StarredAt = sql.NullTime{Time: time.Now(), Valid: true}
+				}
+			case actionTag:
+				if !slices.Contains(post.Tags, rule.Tag.String) {
+					post.Tags = append(post.Tags, rule.Tag.String)
+				}
+			}
+		}
+		if !hidden {
+			listed = append(listed, post)
+		}
+	}
+	return listed
+}
+
+//listPosts gets the user's posts with their current rules applied. Rules
+//run after the query, so it reads on until arg.Limit posts survive them;
+//and as star rules can star posts the database has unstarred, starred only
+//listings filter after the rules too.
+func listPosts(ctx context.Context, s *state, user database.User, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	rules, err := userRules(ctx, s, user)
+	if err != nil {
+		return nil, err
+	}
+	starredOnly := arg.StarredOnly
+	if slices.ContainsFunc(rules, func(r postRule) bool { return r.Action == actionStar }) {
+		arg.StarredOnly = false
+	}
+
+	limit := int(arg.Limit)
+	//read a little more than asked for, some posts may be hidden
+	arg.Limit = max(arg.Limit, 50)
+	listed := []database.GetPostsForUserRow{}
+	for len(listed) < limit {
+		posts, err := s.db.GetPostsForUser(ctx, arg)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting posts for user: %v", err)
+		}
+		for _, post := range applyRulesToListing(rules, posts) {
+			switch {
+			case arg.UnreadOnly && post.ReadAt.Valid:
+			case starredOnly && !post.StarredAt.Valid:
+			default:
+				listed = append(listed, post)
+			}
+		}
+		if len(posts) < int(arg.Limit) {
+			break
+		}
+		arg.Offset += arg.Limit
+	}
+	if len(listed) > limit {
+		listed = listed[:max(limit, 0)]
+	}
+	return listed, nil
+}
+
+func newRulePost(post database.GetPostsForUserRow) rulePost {
+	return rulePost{
+		FeedID: post.FeedID,
+		Title: post.Title,
+		Description: post.Description.String,
+		Content: post.Content.String,
+		Author: post.Author.String,
+		Categories: post.Categories,
+	}
+}
+
+//userRules gets the user's rules ready to match posts
+func userRules(ctx context.Context, s *state, user database.User) ([]postRule, error) {
+	rows, err := s.db.GetRulesForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting rules: %v", err)
+	}
+
+	rules := []database.Rule{}
+	for _, row := range rows {
+		rules = append(rules, ruleFromRow(row))
+	}
+	return compileRules(rules), nil
+}
+
+func ruleFromRow(row database.GetRulesForUserRow) database.Rule {
+	return database.Rule{
+		ID: row.ID,
+		CreatedAt: row.CreatedAt,
+		UserID: row.UserID,
+		FeedID: row.FeedID,
+		Match: row.Match,
+		Pattern: row.Pattern,
+		Action: row.Action,
+		Tag: row.Tag,
+	}
+}
+
+func handlerRuleAdd(s *state, cmd command, user database.User) error {
+	match := cmd.flagString("match")
+	if !slices.Contains(ruleMatches, match) {
+		return fmt.Errorf("Unknown match %q, use one of: %v", match, strings.Join(ruleMatches, ", "))
+	}
+	action := cmd.flagString("action")
+	if !slices.Contains(ruleActions, action) {
+		return fmt.Errorf("Unknown action %q, use one of: %v", action, strings.Join(ruleActions, ", "))
+	}
+
+	tag := strings.TrimSpace(cmd.flagString("tag"))
+	if action == actionTag && tag == "" {
+		return fmt.Errorf("The tag action needs --tag")
+	}
+	if action != actionTag && tag != "" {
+		return fmt.Errorf("--tag is only used with --action %v", actionTag)
+	}
+
+	pattern := strings.TrimSpace(cmd.args[0])
+	if pattern == "" {
+		return fmt.Errorf("The pattern can't be empty")
+	}
+	//refuse regexes that don't compile before they're saved
+	if _, err := newPostRule(database.Rule{Match: match, Pattern: pattern}); err != nil {
+		return err
+	}
+
+	feedID := uuid.NullUUID{}
+	if v := cmd.flagString("feed"); v != "" {
+		follow, err := findFollowedFeed(s, user, v)
+		if err != nil {
+			return err
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+	}
+
+	rule, err := s.db.CreateRule(context.Background(), database.CreateRuleParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UserID: user.ID,
+		FeedID: feedID,
+		Match: match,
+		Pattern: pattern,
+		Action: action,
+		Tag: sql.NullString{String: tag, Valid: tag != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating rule: %v", err)
+	}
+
+	r, _ := newPostRule(rule)
+	fmt.Printf("New rule %v: %v", shortID(rule.ID), r.describe())
+	if feedID.Valid {
+		fmt.Printf(" in %v", cmd.flagString("feed"))
+	}
+	fmt.Println()
+	return nil
+}
+
+func handlerRuleList(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	rules, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting rules: %v", err)
+	}
+
+	records := []ruleRecord{}
+	for _, rule := range rules {
+		records = append(records, newRuleRecord(rule))
+	}
+
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No rules yet, add one with: rule add <pattern>")
+		return nil
+	}
+	fmt.Println("Rules, applied in order:")
+	for _, rule := range records {
+		action := rule.Action
+		if rule.Tag != "" {
+			action += " " + rule.Tag
+		}
+		scope := "all feeds"
+		if rule.Feed != "" {
+			scope = rule.Feed
+		}
+		fmt.Printf("  - %v  %v %v %q (%v)\n", shortID(rule.ID), action, rule.Match, rule.Pattern, scope)
+	}
+	return nil
+}
+
+func handlerRuleRemove(s *state, cmd command, user database.User) error {
+	rule, err := findRule(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.DeleteRule(context.Background(), database.DeleteRuleParams{
+		ID: rule.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting rule: %v", err)
+	}
+
+	fmt.Printf("Removed rule %v\n", shortID(rule.ID))
+	return nil
+}
+
+//handlerRuleTest shows what the user's rules, or one of them, would do to
+//their recent posts without changing anything
+func handlerRuleTest(s *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+
+	rules, err := userRules(ctx, s, user)
+	if err != nil {
+		return err
+	}
+	if len(cmd.args) > 0 {
+		rule, err := findRule(s, user, cmd.args[0])
+		if err != nil {
+			return err
+		}
+		r, err := newPostRule(ruleFromRow(rule))
+		if err != nil {
+			return err
+		}
+		rules = []postRule{r}
+	}
+	if len(rules) == 0 {
+		fmt.Println("No rules to test")
+		return nil
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID: user.ID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("Error getting posts for user: %v", err)
+	}
+
+	matched := 0
+	for _, post := range posts {
+		p := newRulePost(post)
+		actions := []string{}
+		for _, rule := range rules {
+			if rule.matches(p) {
+				actions = append(actions, rule.describe())
+			}
+		}
+		if len(actions) == 0 {
+			continue
+		}
+		matched++
+		fmt.Printf("  - %v (%v)\n", post.Title, post.FeedName)
+		for _, action := range actions {
+			fmt.Printf("      %v\n", action)
+		}
+	}
+
+	fmt.Printf("%v of the last %v posts match\n", matched, len(posts))
+	return nil
+}
+
+//findRule finds one of the user's rules by id or the id's first eight
+//characters
+func findRule(s *state, user database.User, id string) (database.GetRulesForUserRow, error) {
+	rules, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return database.GetRulesForUserRow{}, fmt.Errorf("Error getting rules: %v", err)
+	}
+
+	for _, rule := range rules {
+		if rule.ID.String() == id || shortID(rule.ID) == id {
+			return rule, nil
+		}
+	}
+	return database.GetRulesForUserRow{}, fmt.Errorf("No rule with id %v", id)
+}
+
+//completeRules suggests the short ids of the current user's rules
+func completeRules(s *state) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	rules, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	ids := []string{}
+	for _, rule := range rules {
+		ids = append(ids, shortID(rule.ID))
+	}
+	return ids
+}
+
+type ruleRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Match     string    `json:"match"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Tag       string    `json:"tag"`
+	Feed      string    `json:"feed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newRuleRecord(rule database.GetRulesForUserRow) ruleRecord {
+	return ruleRecord{
+		ID:        rule.ID,
+		Match:     rule.Match,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+		Tag:       rule.Tag.String,
+		Feed:      rule.FeedName.String,
+		CreatedAt: rule.CreatedAt,
+	}
+}
+
+func (r ruleRecord) header() []string {
+	return []string{"id", "match", "pattern", "action", "tag", "feed", "created_at"}
+}
+
+func (r ruleRecord) fields() []string {
+	return []string{r.ID.String(), r.Match, r.Pattern, r.Action, r.Tag, r.Feed, formatTime(r.CreatedAt)}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func TestRules(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+
+	if _, err := e.run("rule", "add", "x", "--action", "explode"); err == nil {
+		t.Fatal("adding a rule with an unknown action succeeded")
+	}
+	if _, err := e.run("rule", "add", "(", "--match", "regex"); err == nil {
+		t.Fatal("adding a rule with an invalid regex succeeded")
+	}
+	if _, err := e.run("rule", "add", "go", "--action", "tag"); err == nil {
+		t.Fatal("adding a tag rule without --tag succeeded")
+	}
+
+	e.mustRun("rule", "add", "sponsored")
+	if _, err := e.run("rule", "test", "--limit", "0"); err == nil {
+		t.Fatal("rule test --limit 0 succeeded")
+	}
+	e.mustRun("rule", "add", "^Go ", "--match", "regex", "--action", "tag", "--tag", "golang")
+	e.mustRun("rule", "add", "release", "--action", "star", "--feed", "news")
+
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("sponsored deal", now),
+		testItem("Go generics", now.Add(-time.Minute)),
+		testItem("release notes", now.Add(-2*time.Minute)),
+	)
+	e.fetch("http://example.com/news", testItem("release day", now.Add(-3*time.Minute)))
+
+	posts := e.posts("alice")
+	titles := []string{}
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+		switch post.Title {
+		case "Go generics":
+			if !slices.Equal(post.Tags, []string{"golang"}) {
+				t.Errorf("Go generics has tags %v, want golang", post.Tags)
+			}
+		case "release notes":
+			if post.StarredAt.Valid {
+				t.Error("the news-only rule starred a post of another feed")
+			}
+		case "release day":
+			if !post.StarredAt.Valid {
+				t.Error("the news rule didn't star release day")
+			}
+		}
+	}
+	if want := []string{"Go generics", "release notes", "release day"}; !slices.Equal(titles, want) {
+		t.Fatalf("alice sees %v, want %v", titles, want)
+	}
+
+	//removing a rule leaves what it did to earlier posts
+	rules, err := e.s.db.GetRulesForUser(context.Background(), e.user("alice").ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.mustRun("rule", "rm", shortID(rules[0].ID))
+	if _, err := e.run("rule", "rm", shortID(rules[0].ID)); err == nil {
+		t.Fatal("removing a rule twice succeeded")
+	}
+	e.fetch("http://example.com/feed", testItem("sponsored again", now))
+	if posts := e.posts("alice"); len(posts) != 4 {
+		t.Fatalf("alice sees %v posts after removing the hide rule, want 4", len(posts))
+	}
+}
+
+func TestRulesApplyBeforeLimit(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	now := time.Now()
+	e.fetch("http://example.com/feed",
+		testItem("one", now),
+		testItem("two", now.Add(-time.Minute)),
+		testItem("three", now.Add(-2*time.Minute)),
+		testItem("four", now.Add(-3*time.Minute)),
+	)
+	//rules added after the fetch only take effect in listings
+	e.mustRun("rule", "add", "one")
+	e.mustRun("rule", "add", "two", "--action", "star")
+	e.mustRun("rule", "add", "three", "--action", "read")
+
+	//the hidden post doesn't take one of the two places
+	if got, want := e.browseTitles("2"), []string{"two", "three"}; !slices.Equal(got, want) {
+		t.Fatalf("browse 2 lists %v, want %v", got, want)
+	}
+	if got, want := e.browseTitles("10", "--starred"), []string{"two"}; !slices.Equal(got, want) {
+		t.Fatalf("browse --starred lists %v, want %v", got, want)
+	}
+	if got, want := e.browseTitles("10", "--unread"), []string{"two", "four"}; !slices.Equal(got, want) {
+		t.Fatalf("browse --unread lists %v, want %v", got, want)
+	}
+
+	//the reader and the timeline feeds list what browse does
+	tu := &tui{s: e.s, user: e.user("alice"), limit: 2}
+	if err := tu.reloadFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.reloadPosts(); err != nil {
+		t.Fatal(err)
+	}
+	tl, err := loadTimeline(context.Background(), e.s, e.user("alice"), timelineFilter{limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, posts := range map[string][]database.GetPostsForUserRow{"tui": tu.posts, "the timeline": tl.posts} {
+		titles := []string{}
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		if want := []string{"two", "three"}; !slices.Equal(titles, want) {
+			t.Errorf("%v lists %v, want %v", name, titles, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"html/template"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//allowedTags are the elements kept when showing a post's HTML; other
+//elements are replaced by their content
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true, "caption": true,
+	"code": true, "dd": true, "del": true, "div": true, "dl": true, "dt": true, "em": true,
+	"figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "hr": true, "i": true, "img": true, "ins": true, "kbd": true,
+	"li": true, "mark": true, "ol": true, "p": true, "pre": true, "q": true, "s": true,
+	"small": true, "span": true, "strong": true, "sub": true, "sup": true, "table": true,
+	"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true, "tr": true,
+	"u": true, "ul": true,
+}
+
+//droppedTags are removed along with everything inside them
+var droppedTags = map[string]bool{
+	"base": true, "button": true, "embed": true, "form": true, "frame": true,
+	"frameset": true, "head": true, "iframe": true, "input": true, "link": true,
+	"meta": true, "noscript": true, "object": true, "script": true, "select": true,
+	"style": true, "template": true, "textarea": true, "title": true,
+}
+
+//allowedAttrs lists the attributes kept per element; href and src must
+//also be http(s) links
+var allowedAttrs = map[string]map[string]bool{
+	"a": {"href": true, "title": true},
+	"abbr": {"title": true},
+	"img": {"src": true, "alt": true, "title": true, "width": true, "height": true},
+	"td": {"colspan": true, "rowspan": true},
+	"th": {"colspan": true, "rowspan": true},
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+//sanitizeHTML keeps the harmless subset of a post's HTML so it can be shown
+//in the web UI. Relative links are resolved against the post's url.
+func sanitizeHTML(input, baseURL string) template.HTML {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		base = &url.URL{}
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(input), &html.Node{
+		Type: html.ElementNode,
+		Data: "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return ""
+	}
+
+	b := &strings.Builder{}
+	for _, node := range nodes {
+		sanitizeNode(b, node, base)
+	}
+	return template.HTML(b.String())
+}
+
+func sanitizeNode(b *strings.Builder, node *html.Node, base *url.URL) {
+	switch node.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(node.Data))
+		return
+	case html.ElementNode:
+	default:
+		//comments and doctypes
+		return
+	}
+
+	//svg and mathml can carry scripts of their own
+	if droppedTags[node.Data] || node.Namespace != "" {
+		return
+	}
+	if !allowedTags[node.Data] {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			sanitizeNode(b, child, base)
+		}
+		return
+	}
+
+	b.WriteString("<" + node.Data)
+	for _, attr := range node.Attr {
+		if attr.Namespace != "" || !allowedAttrs[node.Data][attr.Key] {
+			continue
+		}
+		value := attr.Val
+		if attr.Key == "href" || attr.Key == "src" {
+			var ok bool
+			if value, ok = safeURL(base, value); !ok {
+				continue
+			}
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+	}
+	if node.Data == "a" {
+		b.WriteString(` rel="noopener noreferrer nofollow" target="_blank"`)
+	}
+	if node.Data == "img" {
+		b.WriteString(` loading="lazy" referrerpolicy="no-referrer"`)
+	}
+	b.WriteString(">")
+
+	if voidTags[node.Data] {
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		sanitizeNode(b, child, base)
+	}
+	b.WriteString("</" + node.Data + ">")
+}
+
+//safeURL resolves a link against base, allowing only http(s) and mailto
+func safeURL(base *url.URL, raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	u = base.ResolveReference(u)
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
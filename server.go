@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+//apiConfig carries the state shared by every HTTP handler
+type apiConfig struct {
+	s *state
+	posts *postBroker
+}
+
+func handlerServe(s *state, cmd command) error {
+	addr := cmd.flagString("addr")
+
+	//only postgres can tell us about posts other processes save
+	listenURL := ""
+	if s.dbDriver == driverPostgres {
+		listenURL = s.cfg.DBURL
+	}
+	posts := newPostBroker(listenURL)
+	apiCfg := &apiConfig{s: s, posts: posts}
+
+	srv := &http.Server{
+		Addr: addr,
+		Handler: middlewareLog(apiCfg.routes()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	//end the open post streams, they would hold up the shutdown
+	srv.RegisterOnShutdown(posts.close)
+
+	//stop on ctrl-c or SIGTERM, letting in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Serving on %v", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("Error serving: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("Error shutting down: %v", err)
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/openapi.json", handlerOpenAPI)
+	mux.HandleFunc("GET /v1/healthz", handlerHealthz)
+
+	mux.HandleFunc("GET /v1/users", cfg.authenticated(cfg.handlerUsersGet))
+	mux.HandleFunc("GET /v1/feeds", cfg.authenticated(cfg.handlerFeedsGet))
+
+	mux.HandleFunc("POST /v1/users/{name}/feeds", cfg.middlewareAuth(cfg.handlerFeedsCreate))
+	mux.HandleFunc("GET /v1/users/{name}/follows", cfg.middlewareAuth(cfg.handlerFollowsGet))
+	mux.HandleFunc("POST /v1/users/{name}/follows", cfg.middlewareAuth(cfg.handlerFollowsCreate))
+	mux.HandleFunc("DELETE /v1/users/{name}/follows/{feedID}", cfg.middlewareAuth(cfg.handlerFollowsDelete))
+	mux.HandleFunc("GET /v1/users/{name}/posts", cfg.middlewareAuth(cfg.handlerPostsGet))
+	mux.HandleFunc("GET /v1/users/{name}/posts/stream", cfg.middlewareAuth(cfg.handlerPostsStream))
+
+	//timeline feeds for feed readers, which can't send an api key
+	mux.HandleFunc("GET /feeds/{token}/{file}", cfg.handlerTimelineFeed)
+
+	//mobile reader apps, authenticated with app passwords
+	mux.HandleFunc("/fever/", cfg.handlerFever)
+	cfg.greaderRoutes(mux)
+
+	//web reading interface, authenticated with a session cookie
+	cfg.webRoutes(mux)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, http.StatusNotFound, "No route for "+r.Method+" "+r.URL.Path)
+	})
+
+	return mux
+}
+
+//statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+//Flush lets streaming handlers work through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func middlewareLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%v %v %v %v", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func respondWithText(w http.ResponseWriter, code int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write([]byte(text))
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	if code >= 500 {
+		log.Printf("Responding with %v error: %v", code, msg)
+	}
+
+	type errorResponse struct {
+		Error string `json:"error"`
+	}
+	respondWithJSON(w, code, errorResponse{
+		Error: msg,
+	})
+}
+
+//respondWithActionError responds with an error of the shared actions
+func respondWithActionError(w http.ResponseWriter, err error) {
+	respondWithError(w, actionErrorStatus(err), err.Error())
+}
+
+//actionErrorStatus maps the errors of the shared actions to a status
+func actionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+//decodeJSON reads a JSON request body, rejecting unknown fields
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("Invalid request body: %v", err)
+	}
+	return nil
+}
+
+func handlerOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIDocument)
+}
+
+func handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
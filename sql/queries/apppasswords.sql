@@ -0,0 +1,16 @@
+-- name: SetAppPassword :exec
+INSERT INTO app_passwords (user_id, created_at, key_hash)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id)
+DO UPDATE SET created_at = EXCLUDED.created_at,
+              key_hash = EXCLUDED.key_hash;
+
+-- name: DeleteAppPassword :execrows
+DELETE FROM app_passwords
+WHERE user_id = $1;
+
+-- name: GetUserByAppPassword :one
+SELECT sqlc.embed(users)
+FROM app_passwords
+INNER JOIN users ON app_passwords.user_id = users.id
+WHERE app_passwords.key_hash = $1;
@@ -24,13 +24,31 @@ INNER JOIN users
 SELECT 
   feed_follows.*,
   feeds.name as feed_name,
-  users.name as user_name
+  feeds.url as feed_url,
+  users.name as user_name,
+  folders.name as folder_name
 From feed_follows
 INNER JOIN feeds
   ON feed_follows.feed_id = feeds.id
 INNER JOIN users
   ON feed_follows.user_id = users.id
-WHERE $1 = feed_follows.user_id;
+LEFT JOIN folder_follows
+  ON folder_follows.feed_follow_id = feed_follows.id
+LEFT JOIN folders
+  ON folder_follows.folder_id = folders.id
+WHERE $1 = feed_follows.user_id
+ORDER BY feed_follows.priority DESC, feeds.name;
+
+-- name: UpdateFollowSettings :exec
+UPDATE feed_follows
+SET title = $3,
+    muted = $4,
+    priority = $5,
+    notify = $6,
+    sort_order = $7,
+    updated_at = $8
+WHERE user_id = $1
+AND feed_id = $2;
 
 -- name: Unfollow :exec
 DELETE from feed_follows
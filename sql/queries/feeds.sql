@@ -18,6 +18,10 @@ ORDER BY name;
 SELECT * FROM feeds
 WHERE $1 = feeds.url;
 
+-- name: GetFeedByID :one
+SELECT * FROM feeds
+WHERE id = $1;
+
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $1, 
@@ -28,3 +32,39 @@ WHERE feeds.id = $2;
 SELECT * FROM feeds
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1;
+
+-- name: GetFeedsForUser :many
+SELECT feeds.* FROM feeds
+INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name;
+
+-- name: GetFeedFollowerNames :many
+SELECT users.name FROM feed_follows
+INNER JOIN users ON feed_follows.user_id = users.id
+WHERE feed_follows.feed_id = $1
+ORDER BY users.name;
+
+-- name: UpdateFeed :one
+UPDATE feeds
+SET name = $2,
+    url = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: SetFeedOwner :exec
+UPDATE feeds
+SET user_id = $2,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+-- follows, posts and the rules and webhooks scoped to the feed cascade
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: DeleteFeeds :execrows
+-- every feed, or the feeds the user added; follows and posts cascade
+DELETE FROM feeds
+WHERE sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id');
@@ -0,0 +1,50 @@
+-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, updated_at, user_id, name)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+)
+RETURNING *;
+
+-- name: GetFolder :one
+SELECT * FROM folders
+WHERE user_id = $1
+AND name = $2;
+
+-- name: GetFoldersForUser :many
+SELECT folders.*, count(folder_follows.feed_follow_id) AS feed_count
+FROM folders
+LEFT JOIN folder_follows ON folder_follows.folder_id = folders.id
+WHERE folders.user_id = $1
+GROUP BY folders.id
+ORDER BY folders.name;
+
+-- name: RenameFolder :exec
+UPDATE folders
+SET name = $2,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: DeleteFolder :exec
+DELETE FROM folders
+WHERE id = $1;
+
+-- name: SetFollowFolder :execrows
+INSERT INTO folder_follows (feed_follow_id, folder_id)
+SELECT feed_follows.id, @folder_id::uuid
+FROM feed_follows
+WHERE feed_follows.user_id = @user_id
+AND feed_follows.feed_id = @feed_id
+ON CONFLICT (feed_follow_id)
+DO UPDATE SET folder_id = EXCLUDED.folder_id;
+
+-- name: ClearFollowFolder :exec
+DELETE FROM folder_follows
+WHERE feed_follow_id IN (
+  SELECT id FROM feed_follows
+  WHERE user_id = $1
+  AND feed_id = $2
+);
@@ -1,4 +1,5 @@
 -- name: CreatePost :one
+-- a post whose url is already saved returns no rows
 INSERT INTO posts (
   id, 
   created_at, 
@@ -7,7 +8,10 @@ INSERT INTO posts (
   url, 
   description, 
   published_at, 
-  feed_id
+  feed_id,
+  content,
+  author,
+  categories
 )
 VALUES (
   $1,
@@ -17,14 +21,139 @@ VALUES (
   $5,
   $6,
   $7,
-  $8
+  $8,
+  $9,
+  $10,
+  $11
 )
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
 -- name: GetPostsForUser :many
-SELECT p.*
+SELECT
+  p.*,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at,
+  array(
+    SELECT pt.tag FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id
+    ORDER BY pt.tag
+  )::text[] AS tags
 FROM posts p
 INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
-WHERE ff.user_id = $1
-ORDER BY p.published_at DESC
-LIMIT $2;
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = @user_id
+AND ps.hidden_at IS NULL
+AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (sqlc.narg('folder_id')::uuid IS NULL OR EXISTS (
+  SELECT 1 FROM folder_follows fo
+  WHERE fo.feed_follow_id = ff.id AND fo.folder_id = sqlc.narg('folder_id')
+))
+-- muted feeds only show when asked for
+AND (sqlc.narg('feed_id')::uuid IS NOT NULL OR NOT ff.muted)
+AND (NOT @unread_only::boolean OR ps.read_at IS NULL)
+AND (NOT @starred_only::boolean OR ps.starred_at IS NOT NULL)
+ORDER BY
+  ff.priority DESC,
+  CASE WHEN @oldest_first::boolean THEN p.published_at END,
+  p.published_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SearchPostsForUser :many
+SELECT
+  p.id,
+  p.title,
+  p.url,
+  p.published_at,
+  feeds.name AS feed_name,
+  ts_rank(p.search, websearch_to_tsquery('english', @query)) AS rank,
+  ts_headline(
+    'english',
+    coalesce(p.content, p.description, p.title),
+    websearch_to_tsquery('english', @query),
+    'StartSel=**, StopSel=**, MaxWords=35, MinWords=15, MaxFragments=2'
+  ) AS snippet
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+WHERE ff.user_id = @user_id
+AND p.search @@ websearch_to_tsquery('english', @query)
+ORDER BY rank DESC, p.published_at DESC
+LIMIT @max_rows;
+
+-- name: GetItemsForUser :many
+SELECT
+  p.id,
+  p.seq,
+  p.title,
+  p.url,
+  p.description,
+  p.content,
+  p.published_at,
+  p.created_at,
+  p.feed_id,
+  feeds.seq AS feed_seq,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = @user_id
+AND ps.hidden_at IS NULL
+AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (NOT @unread_only::boolean OR ps.read_at IS NULL)
+AND (NOT @starred_only::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT @for_digest::boolean OR ff.notify IN ('all', 'digest'))
+AND (sqlc.narg('seqs')::bigint[] IS NULL OR p.seq = ANY(sqlc.narg('seqs')::bigint[]))
+AND (sqlc.narg('after_seq')::bigint IS NULL OR p.seq > sqlc.narg('after_seq'))
+AND (sqlc.narg('before_seq')::bigint IS NULL OR p.seq < sqlc.narg('before_seq'))
+AND (sqlc.narg('newer_than')::timestamp IS NULL OR p.created_at >= sqlc.narg('newer_than'))
+AND (sqlc.narg('older_than')::timestamp IS NULL OR p.created_at < sqlc.narg('older_than'))
+ORDER BY CASE WHEN @oldest_first::boolean THEN p.seq ELSE -p.seq END
+LIMIT sqlc.arg('limit');
+
+-- name: CountPostsForUser :one
+SELECT count(*)
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1;
+
+-- name: NotifyNewPost :exec
+SELECT pg_notify('new_posts', sqlc.arg('payload')::text);
+
+-- name: PruneOldPosts :execrows
+DELETE FROM posts
+WHERE published_at < @before
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+);
+
+-- name: PruneExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+  SELECT ranked.id FROM (
+    SELECT p.id, row_number() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.seq DESC) AS position
+    FROM posts p
+  ) ranked
+  WHERE ranked.position > @max_posts::bigint
+)
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+);
+
+-- name: DeletePosts :execrows
+-- every post, or the posts of the feeds the user added
+DELETE FROM posts
+WHERE sqlc.narg('user_id')::uuid IS NULL OR feed_id IN (
+  SELECT id FROM feeds WHERE user_id = sqlc.narg('user_id')
+);
@@ -0,0 +1,33 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, user_id, feed_id, match, pattern, action, tag)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8
+)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT rules.*, feeds.name AS feed_name
+FROM rules
+LEFT JOIN feeds ON rules.feed_id = feeds.id
+WHERE rules.user_id = $1
+ORDER BY rules.created_at;
+
+-- name: GetRulesForFeed :many
+SELECT rules.*
+FROM rules
+INNER JOIN feed_follows ON feed_follows.user_id = rules.user_id
+WHERE feed_follows.feed_id = @feed_id
+AND (rules.feed_id IS NULL OR rules.feed_id = @feed_id)
+ORDER BY rules.created_at;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE id = $1
+AND user_id = $2;
@@ -0,0 +1,96 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, keyword)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT webhooks.*, feeds.name AS feed_name
+FROM webhooks
+LEFT JOIN feeds ON webhooks.feed_id = feeds.id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1
+AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, status, next_attempt_at)
+SELECT gen_random_uuid(), @created_at, webhooks.id, posts.id, 'pending', @created_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN webhooks ON webhooks.user_id = feed_follows.user_id
+WHERE posts.id = @post_id
+AND feed_follows.notify IN ('all', 'webhooks')
+AND (webhooks.feed_id IS NULL OR webhooks.feed_id = posts.feed_id)
+AND (webhooks.keyword IS NULL OR strpos(lower(posts.title || ' ' || coalesce(posts.description, '')), lower(webhooks.keyword)) > 0)
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.user_id = webhooks.user_id
+  AND post_states.post_id = posts.id
+  AND post_states.hidden_at IS NOT NULL
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = @lease_until
+FROM webhooks, posts, feeds
+WHERE webhook_deliveries.id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending'
+  AND next_attempt_at <= @now
+  ORDER BY next_attempt_at
+  LIMIT @max_deliveries
+  FOR UPDATE SKIP LOCKED
+)
+AND webhooks.id = webhook_deliveries.webhook_id
+AND posts.id = webhook_deliveries.post_id
+AND feeds.id = posts.feed_id
+RETURNING
+  webhook_deliveries.id,
+  webhook_deliveries.attempts,
+  webhooks.id AS webhook_id,
+  webhooks.url AS webhook_url,
+  webhooks.secret,
+  posts.id AS post_id,
+  posts.title,
+  posts.url,
+  posts.description,
+  posts.published_at,
+  feeds.id AS feed_id,
+  feeds.name AS feed_name,
+  feeds.url AS feed_url;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = $3,
+    last_attempt_at = $4,
+    next_attempt_at = $5,
+    response_code = $6,
+    error = $7
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesForUser :many
+SELECT
+  webhook_deliveries.*,
+  webhooks.url AS webhook_url,
+  posts.title AS post_title
+FROM webhook_deliveries
+INNER JOIN webhooks ON webhook_deliveries.webhook_id = webhooks.id
+INNER JOIN posts ON webhook_deliveries.post_id = posts.id
+WHERE webhooks.user_id = @user_id
+AND (sqlc.narg('webhook_id')::uuid IS NULL OR webhooks.id = sqlc.narg('webhook_id'))
+ORDER BY webhook_deliveries.created_at DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN password_hash TEXT;
+
+CREATE TABLE sessions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE sessions;
+
+ALTER TABLE users
+DROP COLUMN password_hash;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE feed_tokens (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE
+);
+
+-- +goose Down
+DROP TABLE feed_tokens;
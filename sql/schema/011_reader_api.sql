@@ -0,0 +1,24 @@
+-- +goose Up
+-- Fever and Google Reader clients identify feeds and items by integers
+ALTER TABLE feeds
+ADD COLUMN seq BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE;
+
+ALTER TABLE posts
+ADD COLUMN seq BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE;
+
+CREATE TABLE app_passwords (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE
+);
+
+-- +goose Down
+DROP TABLE app_passwords;
+
+ALTER TABLE posts
+DROP COLUMN seq;
+
+ALTER TABLE feeds
+DROP COLUMN seq;
@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE webhooks (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  -- kept in the clear, it's needed to sign every delivery
+  secret TEXT NOT NULL,
+  feed_id UUID
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  keyword TEXT
+);
+
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  webhook_id UUID NOT NULL
+    REFERENCES webhooks(id)
+    ON DELETE CASCADE,
+  post_id UUID NOT NULL
+    REFERENCES posts(id)
+    ON DELETE CASCADE,
+  status TEXT NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_attempt_at TIMESTAMP,
+  response_code INT,
+  error TEXT,
+  UNIQUE (webhook_id, post_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx
+ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- kept so rules can match on them
+ALTER TABLE posts
+ADD COLUMN author TEXT,
+ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE post_states
+ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE post_tags (
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  post_id UUID NOT NULL
+    REFERENCES posts(id)
+    ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  PRIMARY KEY (user_id, post_id, tag)
+);
+
+CREATE TABLE rules (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  feed_id UUID
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  match TEXT NOT NULL,
+  pattern TEXT NOT NULL,
+  action TEXT NOT NULL,
+  tag TEXT
+);
+
+-- +goose Down
+DROP TABLE rules;
+DROP TABLE post_tags;
+
+ALTER TABLE post_states
+DROP COLUMN hidden_at;
+
+ALTER TABLE posts
+DROP COLUMN categories,
+DROP COLUMN author;
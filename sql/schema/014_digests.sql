@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE digest_subscriptions (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  email TEXT NOT NULL,
+  last_sent_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE digest_subscriptions;
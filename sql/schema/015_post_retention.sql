@@ -0,0 +1,20 @@
+-- +goose Up
+-- deleting a feed deletes its posts, and prune drops posts by feed
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_fkey,
+ADD CONSTRAINT posts_feed_id_fkey
+  FOREIGN KEY (feed_id)
+  REFERENCES feeds(id)
+  ON DELETE CASCADE;
+
+CREATE INDEX posts_feed_id_published_at_idx
+ON posts (feed_id, published_at DESC);
+
+-- +goose Down
+DROP INDEX posts_feed_id_published_at_idx;
+
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_fkey,
+ADD CONSTRAINT posts_feed_id_fkey
+  FOREIGN KEY (feed_id)
+  REFERENCES feeds(id);
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE folders (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  UNIQUE (user_id, name)
+);
+
+-- a follow is in at most one folder; deleting the folder leaves its follows
+-- without one
+CREATE TABLE folder_follows (
+  feed_follow_id UUID PRIMARY KEY
+    REFERENCES feed_follows(id)
+    ON DELETE CASCADE,
+  folder_id UUID NOT NULL
+    REFERENCES folders(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX folder_follows_folder_id_idx
+ON folder_follows (folder_id);
+
+-- +goose Down
+DROP TABLE folder_follows;
+DROP TABLE folders;
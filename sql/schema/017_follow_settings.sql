@@ -0,0 +1,18 @@
+-- +goose Up
+-- each follower's own settings for a feed: a title shown instead of the
+-- feed's name, muting it in browse, its priority there, where its new posts
+-- are sent (all, digest, webhooks or none) and the order it's browsed in
+ALTER TABLE feed_follows
+ADD COLUMN title TEXT,
+ADD COLUMN muted BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN priority INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN notify TEXT NOT NULL DEFAULT 'all',
+ADD COLUMN sort_order TEXT NOT NULL DEFAULT 'newest';
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN sort_order,
+DROP COLUMN notify,
+DROP COLUMN priority,
+DROP COLUMN muted,
+DROP COLUMN title;
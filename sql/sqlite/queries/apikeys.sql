@@ -0,0 +1,43 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, scope, expires_at)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetAPIKeysForUser :many
+SELECT * FROM api_keys
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: GetUserByAPIKey :one
+SELECT
+  sqlc.embed(users),
+  api_keys.id AS key_id,
+  api_keys.scope,
+  api_keys.expires_at,
+  api_keys.revoked_at
+FROM api_keys
+INNER JOIN users ON api_keys.user_id = users.id
+WHERE api_keys.key_hash = ?;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = ?2
+WHERE id = ?1;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = ?3,
+    updated_at = ?3
+WHERE id = ?1
+AND user_id = ?2
+AND revoked_at IS NULL;
@@ -0,0 +1,26 @@
+-- name: SetDigestEmail :one
+INSERT INTO digest_subscriptions (user_id, created_at, updated_at, email)
+VALUES (@user_id, @created_at, @created_at, @email)
+ON CONFLICT (user_id)
+DO UPDATE SET updated_at = excluded.updated_at,
+              email = excluded.email
+RETURNING *;
+
+-- name: GetDigestSubscription :one
+SELECT * FROM digest_subscriptions
+WHERE user_id = ?;
+
+-- name: GetDigestSubscriptions :many
+SELECT digest_subscriptions.*, users.name AS user_name
+FROM digest_subscriptions
+INNER JOIN users ON digest_subscriptions.user_id = users.id
+ORDER BY users.name;
+
+-- name: MarkDigestSent :exec
+UPDATE digest_subscriptions
+SET last_sent_at = ?2
+WHERE user_id = ?1;
+
+-- name: DeleteDigestSubscription :execrows
+DELETE FROM digest_subscriptions
+WHERE user_id = ?;
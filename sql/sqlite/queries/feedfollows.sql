@@ -0,0 +1,57 @@
+-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetFeedFollow :one
+SELECT
+  feed_follows.*,
+  feeds.name as feed_name,
+  users.name as user_name
+FROM feed_follows
+INNER JOIN feeds
+  ON feed_follows.feed_id = feeds.id
+INNER JOIN users
+  ON feed_follows.user_id = users.id
+WHERE feed_follows.id = ?;
+
+-- name: GetFeedFollowsForUser :many
+SELECT
+  feed_follows.*,
+  feeds.name as feed_name,
+  feeds.url as feed_url,
+  users.name as user_name,
+  folders.name as folder_name
+FROM feed_follows
+INNER JOIN feeds
+  ON feed_follows.feed_id = feeds.id
+INNER JOIN users
+  ON feed_follows.user_id = users.id
+LEFT JOIN folder_follows
+  ON folder_follows.feed_follow_id = feed_follows.id
+LEFT JOIN folders
+  ON folder_follows.folder_id = folders.id
+WHERE feed_follows.user_id = ?
+ORDER BY feed_follows.priority DESC, feeds.name;
+
+-- name: UpdateFollowSettings :exec
+UPDATE feed_follows
+SET title = ?3,
+    muted = ?4,
+    priority = ?5,
+    notify = ?6,
+    sort_order = ?7,
+    updated_at = ?8
+WHERE user_id = ?1
+AND feed_id = ?2;
+
+-- name: Unfollow :exec
+DELETE FROM feed_follows
+WHERE user_id = ?
+AND feed_id = ?;
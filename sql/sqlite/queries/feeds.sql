@@ -0,0 +1,70 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetFeeds :many
+SELECT * FROM feeds
+ORDER BY name;
+
+-- name: GetFeed :one
+SELECT * FROM feeds
+WHERE url = ?;
+
+-- name: GetFeedByID :one
+SELECT * FROM feeds
+WHERE id = ?;
+
+-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET last_fetched_at = @last_fetched_at,
+    updated_at = @last_fetched_at
+WHERE feeds.id = @id;
+
+-- name: GetNextFeedToFetch :one
+SELECT * FROM feeds
+ORDER BY last_fetched_at ASC NULLS FIRST
+LIMIT 1;
+
+-- name: GetFeedsForUser :many
+SELECT feeds.* FROM feeds
+INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = ?
+ORDER BY feeds.name;
+
+-- name: GetFeedFollowerNames :many
+SELECT users.name FROM feed_follows
+INNER JOIN users ON feed_follows.user_id = users.id
+WHERE feed_follows.feed_id = ?
+ORDER BY users.name;
+
+-- name: UpdateFeed :one
+UPDATE feeds
+SET name = ?2,
+    url = ?3,
+    updated_at = ?4
+WHERE id = ?1
+RETURNING *;
+
+-- name: SetFeedOwner :exec
+UPDATE feeds
+SET user_id = ?2,
+    updated_at = ?3
+WHERE id = ?1;
+
+-- name: DeleteFeed :exec
+-- follows, posts and the rules and webhooks scoped to the feed cascade
+DELETE FROM feeds
+WHERE id = ?;
+
+-- name: DeleteFeeds :execrows
+-- every feed, or the feeds the user added; follows and posts cascade
+DELETE FROM feeds
+WHERE sqlc.narg('user_id') IS NULL OR user_id = sqlc.narg('user_id');
@@ -0,0 +1,12 @@
+-- name: SetFeedToken :exec
+INSERT INTO feed_tokens (user_id, created_at, token_hash)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id)
+DO UPDATE SET created_at = excluded.created_at,
+              token_hash = excluded.token_hash;
+
+-- name: GetUserByFeedToken :one
+SELECT sqlc.embed(users)
+FROM feed_tokens
+INNER JOIN users ON feed_tokens.user_id = users.id
+WHERE feed_tokens.token_hash = ?;
@@ -0,0 +1,156 @@
+-- name: CreatePost :one
+-- a post whose url is already saved returns no rows
+INSERT INTO posts (
+  id,
+  created_at,
+  updated_at,
+  title,
+  url,
+  description,
+  published_at,
+  feed_id,
+  content,
+  author,
+  categories
+)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+ON CONFLICT (url) DO NOTHING
+RETURNING *;
+
+-- name: GetPostsForUser :many
+SELECT
+  p.*,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at,
+  CAST((
+    SELECT json_group_array(tag) FROM (
+      SELECT pt.tag FROM post_tags pt
+      WHERE pt.user_id = ff.user_id AND pt.post_id = p.id
+      ORDER BY pt.tag
+    )
+  ) AS TEXT) AS tags
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = @user_id
+AND ps.hidden_at IS NULL
+AND (sqlc.narg('feed_id') IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (sqlc.narg('folder_id') IS NULL OR EXISTS (
+  SELECT 1 FROM folder_follows fo
+  WHERE fo.feed_follow_id = ff.id AND fo.folder_id = sqlc.narg('folder_id')
+))
+-- muted feeds only show when asked for
+AND (sqlc.narg('feed_id') IS NOT NULL OR NOT ff.muted)
+AND (NOT CAST(@unread_only AS BOOLEAN) OR ps.read_at IS NULL)
+AND (NOT CAST(@starred_only AS BOOLEAN) OR ps.starred_at IS NOT NULL)
+ORDER BY
+  ff.priority DESC,
+  CASE WHEN CAST(@oldest_first AS BOOLEAN) THEN p.published_at END,
+  p.published_at DESC
+LIMIT @limit OFFSET @offset;
+
+-- name: SearchPostsForUser :many
+-- query uses the FTS4 syntax, rank has no equivalent so matches come
+-- newest first
+SELECT
+  p.id,
+  p.title,
+  p.url,
+  p.published_at,
+  feeds.name AS feed_name,
+  snippet(posts_search, '**', '**', '...', -1, 35) AS snippet
+FROM posts_search
+INNER JOIN posts p ON p.seq = posts_search.docid
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+WHERE posts_search MATCH @query
+AND ff.user_id = @user_id
+ORDER BY p.published_at DESC
+LIMIT @max_rows;
+
+-- name: GetItemsForUser :many
+SELECT
+  p.id,
+  p.seq,
+  p.title,
+  p.url,
+  p.description,
+  p.content,
+  p.published_at,
+  p.created_at,
+  p.feed_id,
+  feeds.seq AS feed_seq,
+  coalesce(ff.title, feeds.name) AS feed_name,
+  feeds.url AS feed_url,
+  ps.read_at,
+  ps.starred_at
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+INNER JOIN feeds ON p.feed_id = feeds.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = @user_id
+AND ps.hidden_at IS NULL
+AND (sqlc.narg('feed_id') IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (NOT CAST(@unread_only AS BOOLEAN) OR ps.read_at IS NULL)
+AND (NOT CAST(@starred_only AS BOOLEAN) OR ps.starred_at IS NOT NULL)
+AND (NOT CAST(@for_digest AS BOOLEAN) OR ff.notify IN ('all', 'digest'))
+-- seqs is a JSON array
+AND (CAST(sqlc.narg('seqs') AS TEXT) IS NULL OR p.seq IN (SELECT value FROM json_each(sqlc.narg('seqs'))))
+AND (CAST(sqlc.narg('after_seq') AS INTEGER) IS NULL OR p.seq > sqlc.narg('after_seq'))
+AND (CAST(sqlc.narg('before_seq') AS INTEGER) IS NULL OR p.seq < sqlc.narg('before_seq'))
+AND (sqlc.narg('newer_than') IS NULL OR p.created_at >= sqlc.narg('newer_than'))
+AND (sqlc.narg('older_than') IS NULL OR p.created_at < sqlc.narg('older_than'))
+ORDER BY CASE WHEN CAST(@oldest_first AS BOOLEAN) THEN p.seq ELSE -p.seq END
+LIMIT @limit;
+
+-- name: CountPostsForUser :one
+SELECT count(*)
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = ?;
+
+-- name: PruneOldPosts :execrows
+DELETE FROM posts
+WHERE published_at < @before
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+);
+
+-- name: PruneExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+  SELECT ranked.id FROM (
+    SELECT p.id, row_number() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.seq DESC) AS position
+    FROM posts p
+  ) ranked
+  WHERE ranked.position > @max_posts
+)
+AND NOT EXISTS (
+  SELECT 1 FROM post_states
+  WHERE post_states.post_id = posts.id
+  AND post_states.starred_at IS NOT NULL
+);
+
+-- name: DeletePosts :execrows
+-- every post, or the posts of the feeds the user added
+DELETE FROM posts
+WHERE sqlc.narg('user_id') IS NULL OR feed_id IN (
+  SELECT id FROM feeds WHERE user_id = sqlc.narg('user_id')
+);
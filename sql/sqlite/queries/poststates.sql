@@ -0,0 +1,48 @@
+-- name: SetPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET read_at = excluded.read_at;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET starred_at = excluded.starred_at;
+
+-- name: GetUnreadCountsForUser :many
+SELECT p.feed_id, count(*) AS unread
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = ?
+AND ps.read_at IS NULL
+GROUP BY p.feed_id;
+
+-- name: MarkPostsRead :execrows
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT ff.user_id, p.id, @read_at
+FROM posts p
+INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = @user_id
+AND (sqlc.narg('feed_id') IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (sqlc.narg('folder_id') IS NULL OR EXISTS (
+  SELECT 1 FROM folder_follows fo
+  WHERE fo.feed_follow_id = ff.id AND fo.folder_id = sqlc.narg('folder_id')
+))
+AND p.created_at <= @before
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET read_at = excluded.read_at
+WHERE post_states.read_at IS NULL;
+
+-- name: HidePost :exec
+INSERT INTO post_states (user_id, post_id, read_at, hidden_at)
+VALUES (@user_id, @post_id, @hidden_at, @hidden_at)
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET hidden_at = excluded.hidden_at,
+              read_at = coalesce(post_states.read_at, excluded.read_at);
+
+-- name: AddPostTag :exec
+INSERT INTO post_tags (user_id, post_id, tag)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,33 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, user_id, feed_id, match, pattern, action, tag)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT rules.*, feeds.name AS feed_name
+FROM rules
+LEFT JOIN feeds ON rules.feed_id = feeds.id
+WHERE rules.user_id = ?
+ORDER BY rules.created_at;
+
+-- name: GetRulesForFeed :many
+SELECT rules.*
+FROM rules
+INNER JOIN feed_follows ON feed_follows.user_id = rules.user_id
+WHERE feed_follows.feed_id = @feed_id
+AND (rules.feed_id IS NULL OR rules.feed_id = @feed_id)
+ORDER BY rules.created_at;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE id = ?
+AND user_id = ?;
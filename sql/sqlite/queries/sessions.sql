@@ -0,0 +1,26 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, token_hash, expires_at)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetUserBySession :one
+SELECT
+  sqlc.embed(users),
+  sessions.expires_at
+FROM sessions
+INNER JOIN users ON sessions.user_id = users.id
+WHERE sessions.token_hash = ?;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = ?;
+
+-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = ?;
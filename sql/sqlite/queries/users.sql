@@ -0,0 +1,57 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name)
+VALUES (
+  ?,
+  ?,
+  ?,
+  ?
+)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE name = ? LIMIT 1;
+
+-- name: DeleteRecords :exec
+DELETE FROM users;
+
+-- name: CountRecords :one
+-- counts everything, or when user_id is given the user and the feeds they
+-- added with those feeds' follows and posts
+SELECT
+  (SELECT count(*) FROM users
+   WHERE sqlc.narg('user_id') IS NULL OR users.id = sqlc.narg('user_id')) AS users,
+  (SELECT count(*) FROM feeds
+   WHERE sqlc.narg('user_id') IS NULL OR feeds.user_id = sqlc.narg('user_id')) AS feeds,
+  (SELECT count(*) FROM feed_follows ff
+   INNER JOIN feeds ON ff.feed_id = feeds.id
+   WHERE sqlc.narg('user_id') IS NULL OR feeds.user_id = sqlc.narg('user_id')) AS follows,
+  (SELECT count(*) FROM posts p
+   INNER JOIN feeds ON p.feed_id = feeds.id
+   WHERE sqlc.narg('user_id') IS NULL OR feeds.user_id = sqlc.narg('user_id')) AS posts;
+
+-- name: GetUsers :many
+SELECT * FROM users
+ORDER BY name;
+
+-- name: GetUserNameFromID :one
+SELECT name FROM users
+WHERE id = ? LIMIT 1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = ?2,
+    updated_at = ?3
+WHERE id = ?1;
+
+-- name: RenameUser :one
+UPDATE users
+SET name = ?2,
+    updated_at = ?3
+WHERE id = ?1
+RETURNING *;
+
+-- name: DeleteUser :exec
+-- feeds the user added and everything else they own cascade
+DELETE FROM users
+WHERE id = ?;
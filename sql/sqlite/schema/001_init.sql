@@ -0,0 +1,216 @@
+-- +goose Up
+-- the postgres schema up to 014_digests.sql; ids are stored as text, times
+-- as RFC 3339 text in UTC and arrays as JSON
+CREATE TABLE users (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  name TEXT NOT NULL,
+  password_hash TEXT
+);
+
+CREATE TABLE feeds (
+  id UUID NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  name TEXT NOT NULL,
+  url TEXT NOT NULL UNIQUE,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  last_fetched_at TIMESTAMP,
+  seq INTEGER PRIMARY KEY AUTOINCREMENT
+);
+
+CREATE TABLE feed_follows (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  feed_id UUID NOT NULL
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  UNIQUE (user_id, feed_id)
+);
+
+CREATE TABLE posts (
+  id UUID NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  title TEXT NOT NULL,
+  url TEXT NOT NULL UNIQUE,
+  description TEXT,
+  published_at TIMESTAMP NOT NULL,
+  feed_id UUID NOT NULL
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  content TEXT,
+  seq INTEGER PRIMARY KEY AUTOINCREMENT,
+  author TEXT,
+  categories TEXT NOT NULL DEFAULT '[]'
+);
+
+-- full-text search, docid is posts.seq
+CREATE VIRTUAL TABLE posts_search USING fts4(title, body, tokenize=porter);
+
+CREATE TRIGGER posts_search_insert AFTER INSERT ON posts BEGIN
+  INSERT INTO posts_search (docid, title, body)
+  VALUES (new.seq, new.title, coalesce(new.description, '') || ' ' || coalesce(new.content, ''));
+END;
+
+CREATE TRIGGER posts_search_update AFTER UPDATE ON posts BEGIN
+  DELETE FROM posts_search WHERE docid = old.seq;
+  INSERT INTO posts_search (docid, title, body)
+  VALUES (new.seq, new.title, coalesce(new.description, '') || ' ' || coalesce(new.content, ''));
+END;
+
+CREATE TRIGGER posts_search_delete AFTER DELETE ON posts BEGIN
+  DELETE FROM posts_search WHERE docid = old.seq;
+END;
+
+CREATE TABLE post_states (
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  post_id UUID NOT NULL
+    REFERENCES posts(id)
+    ON DELETE CASCADE,
+  read_at TIMESTAMP,
+  starred_at TIMESTAMP,
+  hidden_at TIMESTAMP,
+  PRIMARY KEY (user_id, post_id)
+);
+
+CREATE TABLE post_tags (
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  post_id UUID NOT NULL
+    REFERENCES posts(id)
+    ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  PRIMARY KEY (user_id, post_id, tag)
+);
+
+CREATE TABLE api_keys (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  scope TEXT NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+CREATE TABLE sessions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE feed_tokens (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE app_passwords (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE webhooks (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  feed_id UUID
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  keyword TEXT
+);
+
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  webhook_id UUID NOT NULL
+    REFERENCES webhooks(id)
+    ON DELETE CASCADE,
+  post_id UUID NOT NULL
+    REFERENCES posts(id)
+    ON DELETE CASCADE,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_attempt_at TIMESTAMP,
+  response_code INTEGER,
+  error TEXT,
+  UNIQUE (webhook_id, post_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx
+ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+CREATE TABLE rules (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  feed_id UUID
+    REFERENCES feeds(id)
+    ON DELETE CASCADE,
+  match TEXT NOT NULL,
+  pattern TEXT NOT NULL,
+  action TEXT NOT NULL,
+  tag TEXT
+);
+
+CREATE TABLE digest_subscriptions (
+  user_id UUID PRIMARY KEY
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  email TEXT NOT NULL,
+  last_sent_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE digest_subscriptions;
+DROP TABLE rules;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
+DROP TABLE app_passwords;
+DROP TABLE feed_tokens;
+DROP TABLE sessions;
+DROP TABLE api_keys;
+DROP TABLE post_tags;
+DROP TABLE post_states;
+DROP TABLE posts_search;
+DROP TABLE posts;
+DROP TABLE feed_follows;
+DROP TABLE feeds;
+DROP TABLE users;
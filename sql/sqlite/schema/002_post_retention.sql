@@ -0,0 +1,7 @@
+-- +goose Up
+-- prune drops posts by feed; posts.feed_id cascades already
+CREATE INDEX posts_feed_id_published_at_idx
+ON posts (feed_id, published_at DESC);
+
+-- +goose Down
+DROP INDEX posts_feed_id_published_at_idx;
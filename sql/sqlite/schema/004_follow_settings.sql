@@ -0,0 +1,16 @@
+-- +goose Up
+-- each follower's own settings for a feed: a title shown instead of the
+-- feed's name, muting it in browse, its priority there, where its new posts
+-- are sent (all, digest, webhooks or none) and the order it's browsed in
+ALTER TABLE feed_follows ADD COLUMN title TEXT;
+ALTER TABLE feed_follows ADD COLUMN muted BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE feed_follows ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feed_follows ADD COLUMN notify TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE feed_follows ADD COLUMN sort_order TEXT NOT NULL DEFAULT 'newest';
+
+-- +goose Down
+ALTER TABLE feed_follows DROP COLUMN sort_order;
+ALTER TABLE feed_follows DROP COLUMN notify;
+ALTER TABLE feed_follows DROP COLUMN priority;
+ALTER TABLE feed_follows DROP COLUMN muted;
+ALTER TABLE feed_follows DROP COLUMN title;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- register checks the name first, but two racing calls could both pass.
+-- Which of the users sharing a name keeps it is for the operator to decide,
+-- so this fails while there are any; migrate up lists them.
+CREATE UNIQUE INDEX users_name_key
+ON users (name);
+
+-- +goose Down
+DROP INDEX users_name_key;
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/memory"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/sqlite"
+)
+
+const (
+	driverPostgres = "postgres"
+	driverSQLite = "sqlite3"
+	driverMemory = "memory"
+)
+
+//Store is everything the commands need from a database, whichever backend
+//runs the queries
+type Store interface {
+	database.Querier
+	//InTx runs fn with a Store whose queries all commit together if fn
+	//returns nil, and are all rolled back otherwise. On the Store fn gets,
+	//InTx nests: only the inner fn's queries are rolled back when it fails.
+	InTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+//inTx runs fn with a copy of s whose queries run in one transaction, see
+//Store.InTx. fn must use the copy's db, not s's, until it returns.
+func inTx(ctx context.Context, s *state, fn func(tx *state) error) error {
+	return s.db.InTx(ctx, func(db Store) error {
+		tx := *s
+		tx.db = db
+		return fn(&tx)
+	})
+}
+
+//sqlStore is a Store on a database/sql connection, withTx giving the
+//backend's queries on a transaction
+type sqlStore struct {
+	database.Querier
+	db *sql.DB
+	tx *sql.Tx //set inside InTx
+	withTx func(tx *sql.Tx) database.Querier
+}
+
+func (s *sqlStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return s.savepoint(ctx, fn)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error starting transaction: %v", err)
+	}
+	err = fn(&sqlStore{Querier: s.withTx(tx), tx: tx, withTx: s.withTx})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing transaction: %v", err)
+	}
+	return nil
+}
+
+//savepoint nests fn in the running transaction. Postgres refuses every query
+//after one fails until the transaction is rolled back, so this is also how a
+//query that may fail on purpose, like an insert hitting a unique
+//constraint, runs without spoiling the whole transaction.
+func (s *sqlStore) savepoint(ctx context.Context, fn func(tx Store) error) error {
+	//a name reused by nested savepoints refers to the innermost one
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT gator_tx"); err != nil {
+		return fmt.Errorf("Error starting transaction: %v", err)
+	}
+	if err := fn(s); err != nil {
+		s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT gator_tx")
+		return err
+	}
+	if _, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT gator_tx"); err != nil {
+		return fmt.Errorf("Error committing transaction: %v", err)
+	}
+	return nil
+}
+
+//memoryStore is a Store on the memory backend
+type memoryStore struct {
+	*memory.Store
+}
+
+func (m memoryStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	return m.Store.InTx(ctx, func(tx *memory.Store) error {
+		return fn(memoryStore{tx})
+	})
+}
+
+//openDatabase connects s to the database in db_url: sqlite://<path> (or
+//sqlite:<path>) for a local file, memory:// for a database that lasts as
+//long as the command (memory://<path> to keep it in a file between
+//commands), anything else is a postgres url
+func openDatabase(s *state) error {
+	if path, ok := strings.CutPrefix(s.cfg.DBURL, "memory://"); ok {
+		store, err := memory.Open(expandHome(path))
+		if err != nil {
+			return fmt.Errorf("Error opening database: %v", err)
+		}
+		s.db = memoryStore{store}
+		s.dbDriver = driverMemory
+		return nil
+	}
+
+	path, ok := sqlitePath(s.cfg.DBURL)
+	if !ok {
+		db, err := sql.Open(driverPostgres, s.cfg.DBURL)
+		if err != nil {
+			return fmt.Errorf("Error opening database: %v", err)
+		}
+		queries := database.New(db)
+		s.db = &sqlStore{
+			Querier: queries,
+			db: db,
+			withTx: func(tx *sql.Tx) database.Querier {
+				return queries.WithTx(tx)
+			},
+		}
+		s.sqlDB = db
+		s.dbDriver = driverPostgres
+		return nil
+	}
+
+	if path == "" {
+		return fmt.Errorf("db_url %q is missing the database file", s.cfg.DBURL)
+	}
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return fmt.Errorf("Error opening database: %v", err)
+	}
+	store := sqlite.NewStore(db)
+	s.db = &sqlStore{
+		Querier: store,
+		db: db,
+		withTx: func(tx *sql.Tx) database.Querier {
+			return store.WithTx(tx)
+		},
+	}
+	s.sqlDB = db
+	s.dbDriver = driverSQLite
+	return nil
+}
+
+//sqlitePath returns the file in a sqlite db_url, with ~ expanded; relative
+//paths are relative to the working directory
+func sqlitePath(dbURL string) (string, bool) {
+	rest, ok := strings.CutPrefix(dbURL, "sqlite:")
+	if !ok {
+		return "", false
+	}
+	return expandHome(strings.TrimPrefix(rest, "//")), true
+}
+
+func expandHome(path string) string {
+	if home, err := os.UserHomeDir(); err == nil && (path == "~" || strings.HasPrefix(path, "~/")) {
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
+
+//closeDatabase flushes and closes the database s uses, if it opened one
+func closeDatabase(s *state) {
+	if closer, ok := s.db.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+		}
+	}
+	if s.sqlDB != nil {
+		s.sqlDB.Close()
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+func TestSQLitePath(t *testing.T) {
+	t.Setenv("HOME", "/home/alice")
+	for url, want := range map[string]string{
+		"sqlite:///var/gator.db": "/var/gator.db",
+		"sqlite://~/gator.db":    "/home/alice/gator.db",
+		"sqlite:gator.db":        "gator.db",
+	} {
+		if got, ok := sqlitePath(url); !ok || got != want {
+			t.Errorf("the file of %v is %q (%v), want %q", url, got, ok, want)
+		}
+	}
+	if _, ok := sqlitePath("postgres://localhost/gator"); ok {
+		t.Error("a postgres url was taken for a sqlite file")
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	e := newTestEnv(t)
+	path := filepath.Join(t.TempDir(), "gator.json")
+	e.s.cfg.DBURL = "memory://" + path
+	if err := openDatabase(e.s); err != nil {
+		t.Fatal(err)
+	}
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.fetch("http://example.com/feed", testItem("first post", time.Now()))
+	closeDatabase(e.s)
+
+	//the next command picks up where this one left off
+	if err := openDatabase(e.s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.titles("alice"), []string{"first post"}; !slices.Equal(got, want) {
+		t.Fatalf("after reopening alice sees %v, want %v", got, want)
+	}
+	closeDatabase(e.s)
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := openDatabase(e.s); err == nil || !strings.Contains(err.Error(), "snapshot") {
+		t.Fatalf("opening a file that isn't a snapshot: got %v", err)
+	}
+}
+
+//TestSQLiteCommands runs the everyday commands on a SQLite database, whose
+//queries are kept apart from the postgres ones
+func TestSQLiteCommands(t *testing.T) {
+	e := newTestEnv(t)
+	e.useSQLite()
+	e.mustRun("migrate", "up")
+
+	e.mustRun("register", "bob")
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	e.mustRun("rule", "add", "^Go ", "--match", "regex", "--action", "tag", "--tag", "golang")
+
+	now := time.Now()
+	item := testItem("Go generics", now)
+	item.Categories = []string{"go", "types"}
+	e.fetch("http://example.com/feed", item, testItem("Rust traits", now.Add(-time.Minute)))
+	e.fetch("http://example.com/news", testItem("headline", now.Add(-2*time.Minute)))
+
+	posts := e.posts("alice")
+	if len(posts) != 3 {
+		t.Fatalf("alice has %v posts, want 3", len(posts))
+	}
+	if !slices.Equal(posts[0].Categories, []string{"go", "types"}) || !slices.Equal(posts[0].Tags, []string{"golang"}) {
+		t.Errorf("Go generics has categories %v and tags %v", posts[0].Categories, posts[0].Tags)
+	}
+
+	if out := e.mustRun("search", "generics"); !strings.Contains(out, "Go generics") {
+		t.Errorf("searching for generics printed %q", out)
+	}
+
+	e.mustRun("folder", "create", "tech")
+	e.mustRun("move", "blog", "tech")
+	if got, want := e.browseTitles("--folder", "tech"), []string{"Go generics", "Rust traits"}; !slices.Equal(got, want) {
+		t.Errorf("browse --folder tech lists %v, want %v", got, want)
+	}
+	e.mustRun("markall", "read", "--folder", "tech")
+	if got, want := e.browseTitles("--unread"), []string{"headline"}; !slices.Equal(got, want) {
+		t.Errorf("after marking tech read browse --unread lists %v, want %v", got, want)
+	}
+
+	e.mustRun("follow-settings", "news", "--priority", "5", "--title", "News")
+	if got, want := e.browseTitles("10"), []string{"headline", "Go generics", "Rust traits"}; !slices.Equal(got, want) {
+		t.Errorf("with news prioritised browse lists %v, want %v", got, want)
+	}
+
+	e.mustRun("prune", "--max-posts", "1")
+	if got, want := e.titles("alice"), []string{"headline", "Go generics"}; !slices.Equal(got, want) {
+		t.Errorf("after pruning alice sees %v, want %v", got, want)
+	}
+
+	e.mustRun("deluser", "bob", "--yes")
+	if _, err := e.s.db.GetUser(context.Background(), "bob"); err == nil {
+		t.Error("bob is still there")
+	}
+	//deleting alice takes her feeds, follows and posts with her
+	e.mustRun("deluser", "alice", "--yes")
+	if counts := e.counts(); counts.Users != 0 || counts.Feeds != 0 || counts.Follows != 0 || counts.Posts != 0 {
+		t.Errorf("after deleting everyone the database holds %+v", counts)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	for _, backend := range []string{driverMemory, driverSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			e := newTestEnv(t)
+			if backend == driverSQLite {
+				e.useSQLite()
+				e.mustRun("migrate", "up")
+			}
+			ctx := context.Background()
+			createUser := func(tx *state, name string) error {
+				_, err := tx.db.CreateUser(ctx, database.CreateUserParams{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+					Name:      name,
+				})
+				return err
+			}
+			users := func() []string {
+				users, err := e.s.db.GetUsers(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				names := []string{}
+				for _, user := range users {
+					names = append(names, user.Name)
+				}
+				slices.Sort(names)
+				return names
+			}
+
+			failed := errors.New("failed")
+			err := inTx(ctx, e.s, func(tx *state) error {
+				if err := createUser(tx, "alice"); err != nil {
+					return err
+				}
+				return failed
+			})
+			if !errors.Is(err, failed) || len(users()) != 0 {
+				t.Fatalf("a failed transaction returned %v and left %v", err, users())
+			}
+
+			//a failed nested transaction only rolls back its own queries,
+			//even when it failed on a constraint
+			err = inTx(ctx, e.s, func(tx *state) error {
+				if err := createUser(tx, "bob"); err != nil {
+					return err
+				}
+				inner := inTx(ctx, tx, func(tx *state) error {
+					if err := createUser(tx, "carol"); err != nil {
+						return err
+					}
+					return createUser(tx, "bob")
+				})
+				if inner == nil {
+					t.Error("creating a second bob succeeded")
+				}
+				return createUser(tx, "dave")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := users(), []string{"bob", "dave"}; !slices.Equal(got, want) {
+				t.Fatalf("after the nested failure the users are %v, want %v", got, want)
+			}
+		})
+	}
+}
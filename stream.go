@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//newPostsChannel is the Postgres NOTIFY channel new posts are announced on
+const newPostsChannel = "new_posts"
+
+//streamPingInterval keeps idle streams from being closed by proxies
+const streamPingInterval = 30 * time.Second
+
+//postEvent is the payload of a new post notification
+type postEvent struct {
+	PostID uuid.UUID `json:"post_id"`
+	FeedID uuid.UUID `json:"feed_id"`
+	Seq int64 `json:"seq"`
+}
+
+//notifyNewPost announces a post to every server listening, whichever
+//process saved it
+func notifyNewPost(s *state, post database.Post) error {
+	payload, err := json.Marshal(postEvent{
+		PostID: post.ID,
+		FeedID: post.FeedID,
+		Seq: post.Seq,
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.NotifyNewPost(context.Background(), string(payload))
+}
+
+//postBroker listens for new post notifications and fans them out to the
+//open streams
+type postBroker struct {
+	listener *pq.Listener
+	mu sync.Mutex
+	subscribers map[chan postEvent]bool
+	done chan struct{}
+	closeOnce sync.Once
+}
+
+//newPostBroker listens on the postgres database at dbURL; without one
+//streams only get the posts they missed when they reconnect
+func newPostBroker(dbURL string) *postBroker {
+	b := &postBroker{
+		subscribers: map[chan postEvent]bool{},
+		done: make(chan struct{}),
+	}
+	if dbURL == "" {
+		return b
+	}
+
+	b.listener = pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Error listening for new posts: %v", err)
+		}
+	})
+	go b.run()
+	return b
+}
+
+func (b *postBroker) run() {
+	//Listen waits for the database, the server shouldn't
+	if err := b.listener.Listen(newPostsChannel); err != nil {
+		select {
+		case <-b.done:
+		default:
+			log.Printf("Error listening for new posts: %v", err)
+		}
+		return
+	}
+
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			//a nil notification means the connection was re-established and
+			//notifications may have been lost; dropping the streams makes
+			//clients reconnect and catch up with Last-Event-ID
+			if n == nil {
+				b.dropAll()
+				continue
+			}
+			event := postEvent{}
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				log.Printf("Error decoding new post notification: %v", err)
+				continue
+			}
+			b.broadcast(event)
+		case <-time.After(90 * time.Second):
+			go b.listener.Ping()
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *postBroker) subscribe() chan postEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan postEvent, 64)
+	b.subscribers[ch] = true
+	return ch
+}
+
+func (b *postBroker) unsubscribe(ch chan postEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+//broadcast hands an event to every stream; streams that fell behind are
+//closed rather than slowing everyone down
+func (b *postBroker) broadcast(event postEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *postBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+//close stops the broker and ends every open stream
+func (b *postBroker) close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		if b.listener != nil {
+			b.listener.Close()
+		}
+	})
+}
+
+//handlerPostsStream streams the user's new posts as Server-Sent Events.
+//Each event's id is the post's seq, so a client reconnecting with
+//Last-Event-ID gets the posts it missed first.
+func (cfg *apiConfig) handlerPostsStream(w http.ResponseWriter, r *http.Request, user database.User) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	//subscribe before catching up so nothing falls in between
+	events := cfg.posts.subscribe()
+	defer cfg.posts.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	lastSeq := int64(0)
+	if v, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		items, err := cfg.s.db.GetItemsForUser(r.Context(), database.GetItemsForUserParams{
+			UserID: user.ID,
+			AfterSeq: sql.NullInt64{Int64: v, Valid: true},
+			OldestFirst: true,
+			Limit: maxPostsLimit,
+		})
+		if err != nil {
+			log.Printf("Error getting missed posts: %v", err)
+		}
+		for _, item := range items {
+			if err := writePostEvent(w, item); err != nil {
+				return
+			}
+			lastSeq = item.Seq
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.posts.done:
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Seq <= lastSeq {
+				continue
+			}
+
+			//only posts from feeds the user follows come back
+			items, err := getItemsBySeq(r.Context(), cfg.s, user, []int64{event.Seq})
+			if err != nil {
+				log.Printf("Error getting new post: %v", err)
+				continue
+			}
+			for _, item := range items {
+				if err := writePostEvent(w, item); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writePostEvent(w http.ResponseWriter, item database.GetItemsForUserRow) error {
+	data, err := json.Marshal(newItemPostRecord(item))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %v\nevent: post\ndata: %s\n\n", item.Seq, data)
+	return err
+}
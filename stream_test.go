@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//sseEvent is one event read from a stream
+type sseEvent struct {
+	id   string
+	name string
+	post postRecord
+}
+
+//readEvent reads the next event, skipping comments and the retry hint
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	event := sseEvent{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.name != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.post); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestPostsStream(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("old", now.Add(-time.Hour)), testItem("missed", now.Add(-time.Minute)))
+	e.mustRun("register", "bob")
+	e.mustRun("follow", "http://example.com/feed")
+	c := e.apiClient()
+
+	broker := newPostBroker("")
+	server := httptest.NewServer((&apiConfig{s: e.s, posts: broker}).routes())
+	defer server.Close()
+	defer broker.close()
+
+	seqs := map[string]int64{}
+	for _, post := range e.posts("alice") {
+		seqs[post.Title] = post.Seq
+	}
+
+	//reconnecting after "old" replays "missed" first
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/users/bob/posts/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("Last-Event-ID", fmt.Sprint(seqs["old"]))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("opening the stream: got %v %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	r := bufio.NewReader(resp.Body)
+	if event := readEvent(t, r); event.name != "post" || event.post.Title != "missed" || event.id != fmt.Sprint(seqs["missed"]) {
+		t.Fatalf("the first event is %+v, want the missed post", event)
+	}
+
+	//new posts of feeds bob doesn't follow, and ones already sent, are skipped
+	e.fetch("http://example.com/news", testItem("headline", now))
+	e.fetch("http://example.com/feed", testItem("fresh", now))
+	for _, post := range e.posts("alice") {
+		seqs[post.Title] = post.Seq
+	}
+	for _, title := range []string{"missed", "headline", "fresh"} {
+		broker.broadcast(postEvent{Seq: seqs[title]})
+	}
+	if event := readEvent(t, r); event.post.Title != "fresh" {
+		t.Fatalf("the next event is %+v, want fresh", event)
+	}
+}
+
+func TestPostBrokerDropsSlowStreams(t *testing.T) {
+	b := newPostBroker("")
+	defer b.close()
+	slow := b.subscribe()
+	fast := b.subscribe()
+
+	for i := range cap(slow) + 1 {
+		b.broadcast(postEvent{Seq: int64(i)})
+		<-fast
+	}
+
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != cap(fast) {
+		t.Fatalf("the slow stream got %v events before closing, want %v", n, cap(fast))
+	}
+	select {
+	case _, ok := <-fast:
+		if !ok {
+			t.Fatal("the stream keeping up was closed")
+		}
+		t.Fatal("the stream keeping up has an extra event")
+	default:
+	}
+
+	//unsubscribing a dropped stream doesn't close it twice
+	b.unsubscribe(slow)
+	b.unsubscribe(fast)
+}
@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+	"golang.org/x/term"
+)
+
+//tui panes, left to right
+const (
+	paneFeeds = iota
+	panePosts
+	paneBody
+)
+
+const tuiHelp = "q quit  tab/←→ pane  ↑↓ move  enter open  r read  s star  o open link  R refresh  u unread only"
+
+type tuiFeed struct {
+	name string
+	url string //empty for the "All posts" entry
+	feedID uuid.NullUUID
+	unread int64
+}
+
+//tui holds the state of the interactive reader between key presses
+type tui struct {
+	s *state
+	user database.User
+	limit int32
+
+	feeds []tuiFeed
+	posts []database.GetPostsForUserRow
+
+	focus int
+	feedCursor int
+	feedOffset int
+	postCursor int
+	postOffset int
+	bodyOffset int
+	unreadOnly bool
+
+	status string
+	width int
+	height int
+}
+
+func handlerTUI(s *state, cmd command, user database.User) error {
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("tui needs an interactive terminal")
+	}
+
+	t := &tui{
+		s: s,
+		user: user,
+		limit: int32(limit),
+		unreadOnly: cmd.flagBool("unread"),
+	}
+	if err := t.reloadFeeds(); err != nil {
+		return err
+	}
+	if err := t.reloadPosts(); err != nil {
+		return err
+	}
+
+	//switch to raw mode on the alternate screen, restoring both on exit
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("Error setting up terminal: %v", err)
+	}
+	defer term.Restore(fd, oldState)
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	//terminal size is polled so resizes redraw without platform signals
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	t.render(os.Stdout)
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			if quit := t.handleKey(key); quit {
+				return nil
+			}
+			t.render(os.Stdout)
+		case <-ticker.C:
+			w, h, err := term.GetSize(int(os.Stdout.Fd()))
+			if err == nil && (w != t.width || h != t.height) {
+				t.render(os.Stdout)
+			}
+		}
+	}
+}
+
+//readKeys turns raw terminal input into key names ("up", "enter", "q", ...)
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	sequences := map[string]string{
+		"\x1b[A": "up",
+		"\x1b[B": "down",
+		"\x1b[C": "right",
+		"\x1b[D": "left",
+		"\x1b[Z": "backtab",
+		"\x1b[5~": "pgup",
+		"\x1b[6~": "pgdown",
+		"\x1b[H": "home",
+		"\x1b[F": "end",
+		"\x1bOA": "up",
+		"\x1bOB": "down",
+		"\x1bOC": "right",
+		"\x1bOD": "left",
+	}
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+
+		input := string(buf[:n])
+		for len(input) > 0 {
+			key := ""
+			size := 1
+			for seq, name := range sequences {
+				if strings.HasPrefix(input, seq) {
+					key, size = name, len(seq)
+					break
+				}
+			}
+			if key == "" {
+				switch input[0] {
+				case '\r', '\n':
+					key = "enter"
+				case '\t':
+					key = "tab"
+				case 3:
+					key = "ctrl+c"
+				case 0x1b:
+					key = "esc"
+				default:
+					key = input[:1]
+				}
+			}
+			keys <- key
+			input = input[size:]
+		}
+	}
+}
+
+//handleKey applies one key press and reports whether to quit
+func (t *tui) handleKey(key string) bool {
+	t.status = ""
+	rows := t.rows()
+
+	switch key {
+	case "q", "ctrl+c":
+		return true
+	case "tab", "right", "l":
+		t.focus = min(t.focus+1, paneBody)
+	case "backtab", "left", "h", "esc":
+		t.focus = max(t.focus-1, paneFeeds)
+	case "up", "k":
+		t.move(-1)
+	case "down", "j":
+		t.move(1)
+	case "pgup":
+		t.move(-rows)
+	case "pgdown", " ":
+		t.move(rows)
+	case "home", "g":
+		t.move(-1 << 30)
+	case "end", "G":
+		t.move(1 << 30)
+	case "enter":
+		switch t.focus {
+		case paneFeeds:
+			t.focus = panePosts
+		case panePosts:
+			if post, ok := t.selectedPost(); ok {
+				t.focus = paneBody
+				if !post.ReadAt.Valid {
+					t.setRead(true)
+				}
+			}
+		}
+	case "r":
+		if post, ok := t.selectedPost(); ok {
+			t.setRead(!post.ReadAt.Valid)
+		}
+	case "s":
+		if post, ok := t.selectedPost(); ok {
+			t.setStarred(!post.StarredAt.Valid)
+		}
+	case "o":
+		if post, ok := t.selectedPost(); ok {
+			if err := openURL(post.Url); err != nil {
+				t.status = fmt.Sprintf("Error opening link: %v", err)
+				break
+			}
+			t.status = "Opened " + post.Url
+			if !post.ReadAt.Valid {
+				t.setRead(true)
+			}
+		}
+	case "R":
+		t.refresh()
+	case "u":
+		t.unreadOnly = !t.unreadOnly
+		t.postCursor, t.postOffset, t.bodyOffset = 0, 0, 0
+		t.reportError(t.reloadPosts())
+	}
+
+	return false
+}
+
+//move shifts the cursor of the focused pane, or scrolls the post body
+func (t *tui) move(delta int) {
+	switch t.focus {
+	case paneFeeds:
+		cursor := clamp(t.feedCursor+delta, 0, len(t.feeds)-1)
+		if cursor != t.feedCursor {
+			t.feedCursor = cursor
+			t.postCursor, t.postOffset, t.bodyOffset = 0, 0, 0
+			t.reportError(t.reloadPosts())
+		}
+	case panePosts:
+		cursor := clamp(t.postCursor+delta, 0, len(t.posts)-1)
+		if cursor != t.postCursor {
+			t.postCursor = cursor
+			t.bodyOffset = 0
+		}
+	case paneBody:
+		t.bodyOffset = max(t.bodyOffset+delta, 0)
+	}
+}
+
+func (t *tui) selectedPost() (database.GetPostsForUserRow, bool) {
+	if t.postCursor < 0 || t.postCursor >= len(t.posts) {
+		return database.GetPostsForUserRow{}, false
+	}
+	return t.posts[t.postCursor], true
+}
+
+func (t *tui) setRead(read bool) {
+	post := &t.posts[t.postCursor]
+	readAt := sql.NullTime{Time: time.Now(), Valid: read}
+	err := t.s.db.SetPostRead(context.Background(), database.SetPostReadParams{
+		UserID: t.user.ID,
+		PostID: post.ID,
+		ReadAt: readAt,
+	})
+	if err != nil {
+		t.status = fmt.Sprintf("Error marking post read: %v", err)
+		return
+	}
+	post.ReadAt = readAt
+	t.reportError(t.reloadFeeds())
+}
+
+func (t *tui) setStarred(starred bool) {
+	post := &t.posts[t.postCursor]
+	starredAt := sql.NullTime{Time: time.Now(), Valid: starred}
+	err := t.s.db.SetPostStarred(context.Background(), database.SetPostStarredParams{
+		UserID: t.user.ID,
+		PostID: post.ID,
+		StarredAt: starredAt,
+	})
+	if err != nil {
+		t.status = fmt.Sprintf("Error starring post: %v", err)
+		return
+	}
+	post.StarredAt = starredAt
+}
+
+//refresh fetches the selected feed, or every followed feed from "All posts",
+//then reloads the lists
+func (t *tui) refresh() {
+	feeds := []tuiFeed{}
+	for _, feed := range t.feeds {
+		if feed.url != "" && (t.feedCursor == 0 || feed == t.feeds[t.feedCursor]) {
+			feeds = append(feeds, feed)
+		}
+	}
+
+	t.status = fmt.Sprintf("Refreshing %v feed(s)...", len(feeds))
+	t.render(os.Stdout)
+
+	failed := 0
+	for _, f := range feeds {
+		feed, err := t.s.db.GetFeed(context.Background(), f.url)
+		if err == nil {
+			err = scrapeFeed(t.s, feed)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+
+	if err := t.reloadFeeds(); err != nil {
+		t.reportError(err)
+		return
+	}
+	if err := t.reloadPosts(); err != nil {
+		t.reportError(err)
+		return
+	}
+	t.status = fmt.Sprintf("Refreshed %v feed(s)", len(feeds)-failed)
+	if failed > 0 {
+		t.status += fmt.Sprintf(", %v failed", failed)
+	}
+}
+
+func (t *tui) reloadFeeds() error {
+	follows, err := t.s.db.GetFeedFollowsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting following feeds: %v", err)
+	}
+	counts, err := t.s.db.GetUnreadCountsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting unread counts: %v", err)
+	}
+
+	unread := map[uuid.UUID]int64{}
+	total := int64(0)
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+		total += count.Unread
+	}
+
+	sort.Slice(follows, func(i, j int) bool {
+		return strings.ToLower(follows[i].FeedName) < strings.ToLower(follows[j].FeedName)
+	})
+
+	feeds := []tuiFeed{{name: "All posts", unread: total}}
+	for _, follow := range follows {
+		feeds = append(feeds, tuiFeed{
+			name: follow.FeedName,
+			url: follow.FeedUrl,
+			feedID: uuid.NullUUID{UUID: follow.FeedID, Valid: true},
+			unread: unread[follow.FeedID],
+		})
+	}
+
+	t.feeds = feeds
+	t.feedCursor = clamp(t.feedCursor, 0, len(t.feeds)-1)
+	return nil
+}
+
+func (t *tui) reloadPosts() error {
+	posts, err := listPosts(context.Background(), t.s, t.user, database.GetPostsForUserParams{
+		UserID: t.user.ID,
+		FeedID: t.feeds[t.feedCursor].feedID,
+		UnreadOnly: t.unreadOnly,
+		Limit: t.limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.posts = posts
+	t.postCursor = clamp(t.postCursor, 0, len(t.posts)-1)
+	return nil
+}
+
+func (t *tui) reportError(err error) {
+	if err != nil {
+		t.status = err.Error()
+	}
+}
+
+//rows is the number of lines available to the panes
+func (t *tui) rows() int {
+	return max(t.height-2, 1)
+}
+
+//render redraws the whole screen: a title bar, the three panes and a
+//status line
+func (t *tui) render(w io.Writer) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	t.width, t.height = width, height
+	rows := t.rows()
+
+	feedsWidth := max(width/4, 16)
+	postsWidth := max(width*35/100, 24)
+	bodyWidth := max(width-feedsWidth-postsWidth-2, 10)
+
+	t.feedOffset = scrollTo(t.feedCursor, t.feedOffset, rows)
+	t.postOffset = scrollTo(t.postCursor, t.postOffset, rows)
+	body := t.bodyLines(bodyWidth)
+	t.bodyOffset = clamp(t.bodyOffset, 0, max(len(body)-rows, 0))
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+
+	title := fmt.Sprintf(" gator - %v", t.user.Name)
+	if t.unreadOnly {
+		title += "  [unread only]"
+	}
+	b.WriteString("\x1b[7m" + fit(title, width) + "\x1b[0m\r\n")
+
+	for row := 0; row < rows; row++ {
+		i := t.feedOffset + row
+		if i < len(t.feeds) {
+			feed := t.feeds[i]
+			line := feed.name
+			if feed.unread > 0 {
+				line = fmt.Sprintf("%v (%v)", feed.name, feed.unread)
+			}
+			b.WriteString(highlight(fit(" "+line, feedsWidth), i == t.feedCursor, t.focus == paneFeeds))
+		} else {
+			b.WriteString(fit("", feedsWidth))
+		}
+		b.WriteString("│")
+
+		i = t.postOffset + row
+		if i < len(t.posts) {
+			post := t.posts[i]
+			marker := " "
+			if !post.ReadAt.Valid {
+				marker = "•"
+			}
+			star := " "
+			if post.StarredAt.Valid {
+				star = "★"
+			}
+			b.WriteString(highlight(fit(marker+star+post.Title, postsWidth), i == t.postCursor, t.focus == panePosts))
+		} else {
+			b.WriteString(fit("", postsWidth))
+		}
+		b.WriteString("│")
+
+		i = t.bodyOffset + row
+		if i < len(body) {
+			b.WriteString(fit(body[i], bodyWidth))
+		} else {
+			b.WriteString(fit("", bodyWidth))
+		}
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	status := t.status
+	if status == "" {
+		status = tuiHelp
+	}
+	b.WriteString("\x1b[7m" + fit(" "+status, width) + "\x1b[0m")
+
+	io.WriteString(w, b.String())
+}
+
+//bodyLines renders the selected post, wrapped to the body pane width
+func (t *tui) bodyLines(width int) []string {
+	post, ok := t.selectedPost()
+	if !ok {
+		return []string{" No posts"}
+	}
+
+	lines := []string{}
+	lines = append(lines, wrap(post.Title, width-1)...)
+	lines = append(lines, post.FeedName+" - "+post.PublishedAt.Format("Mon, 02 Jan 2006 15:04"))
+	lines = append(lines, post.Url, "")
+
+	text := post.Content.String
+	if !post.Content.Valid || text == "" {
+		text = post.Description.String
+	}
+	blank := false
+	for _, paragraph := range strings.Split(stripHTML(text), "\n") {
+		if strings.TrimSpace(paragraph) == "" {
+			if !blank {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		lines = append(lines, wrap(paragraph, width-1)...)
+	}
+
+	for i := range lines {
+		lines[i] = " " + lines[i]
+	}
+	return lines
+}
+
+func highlight(text string, selected, focused bool) string {
+	switch {
+	case selected && focused:
+		return "\x1b[7m" + text + "\x1b[0m"
+	case selected:
+		return "\x1b[1m" + text + "\x1b[0m"
+	}
+	return text
+}
+
+//fit pads or truncates text to exactly width columns
+func fit(text string, width int) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, text)
+
+	runes := []rune(text)
+	if len(runes) > width {
+		if width <= 0 {
+			return ""
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+//wrap splits text into lines of at most width runes, breaking on spaces
+func wrap(text string, width int) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for len([]rune(word)) > width && width > 0 {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, string([]rune(word)[:width]))
+			word = string([]rune(word)[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func scrollTo(cursor, offset, rows int) int {
+	if cursor < offset {
+		return cursor
+	}
+	if cursor >= offset+rows {
+		return cursor - rows + 1
+	}
+	return offset
+}
+
+func clamp(v, low, high int) int {
+	return max(low, min(v, high))
+}
+
+//openURL opens a link in the default browser without waiting for it
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
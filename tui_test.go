@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTUIReadKeys(t *testing.T) {
+	keys := make(chan string)
+	go readKeys(strings.NewReader("\x1b[Aj\r\t\x1bOD\x1b[6~q\x1b"), keys)
+
+	got := []string{}
+	for key := range keys {
+		got = append(got, key)
+	}
+	want := []string{"up", "j", "enter", "tab", "left", "pgdown", "q", "esc"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("read keys %q, want %q", got, want)
+	}
+}
+
+func TestTUIKeys(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("addfeed", "news", "http://example.com/news")
+
+	now := time.Now()
+	e.fetch("http://example.com/feed", testItem("first", now), testItem("second", now.Add(-time.Minute)))
+	e.fetch("http://example.com/news", testItem("headline", now.Add(-2*time.Minute)))
+
+	if _, err := e.run("tui", "--limit", "0"); err == nil || !strings.Contains(err.Error(), "--limit") {
+		t.Fatalf("tui --limit 0 returned %v, want a limit error", err)
+	}
+	if _, err := e.run("tui"); err == nil {
+		t.Fatal("tui ran without a terminal")
+	}
+
+	tu := &tui{s: e.s, user: e.user("alice"), limit: 50, height: 24}
+	if err := tu.reloadFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.reloadPosts(); err != nil {
+		t.Fatal(err)
+	}
+	postTitles := func() []string {
+		titles := []string{}
+		for _, post := range tu.posts {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	if len(tu.feeds) != 3 || tu.feeds[0].name != "All posts" || tu.feeds[0].unread != 3 {
+		t.Fatalf("feeds are %+v, want All posts with 3 unread, then blog and news", tu.feeds)
+	}
+	if want := []string{"first", "second", "headline"}; !slices.Equal(postTitles(), want) {
+		t.Fatalf("All posts lists %v, want %v", postTitles(), want)
+	}
+
+	//moving down the feeds pane selects blog and lists only its posts
+	tu.handleKey("j")
+	if want := []string{"first", "second"}; !slices.Equal(postTitles(), want) {
+		t.Fatalf("blog lists %v, want %v", postTitles(), want)
+	}
+
+	//enter focuses the posts, then opens the selected one and marks it read
+	tu.handleKey("enter")
+	tu.handleKey("down")
+	tu.handleKey("enter")
+	if tu.focus != paneBody {
+		t.Fatalf("focus is %v after opening a post, want the body pane", tu.focus)
+	}
+	if !tu.posts[1].ReadAt.Valid || tu.posts[0].ReadAt.Valid {
+		t.Fatal("opening second didn't mark only it read")
+	}
+	if tu.feeds[1].unread != 1 {
+		t.Errorf("blog shows %v unread after opening a post, want 1", tu.feeds[1].unread)
+	}
+
+	tu.handleKey("left")
+	tu.handleKey("s")
+	tu.handleKey("r")
+	for _, post := range e.posts("alice") {
+		if post.Title != "second" {
+			continue
+		}
+		if !post.StarredAt.Valid {
+			t.Error("s didn't star the selected post")
+		}
+		if post.ReadAt.Valid {
+			t.Error("r didn't mark the read post unread again")
+		}
+	}
+
+	//unread only hides posts as they're read
+	tu.handleKey("r")
+	tu.handleKey("u")
+	if want := []string{"first"}; !slices.Equal(postTitles(), want) {
+		t.Fatalf("blog lists %v with unread only, want %v", postTitles(), want)
+	}
+
+	if tu.handleKey("j") || !tu.handleKey("q") {
+		t.Error("only q should quit")
+	}
+}
+
+func TestTUIRender(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.fetch("http://example.com/feed", testItem("first", time.Now()))
+
+	tu := &tui{s: e.s, user: e.user("alice"), limit: 50}
+	if err := tu.reloadFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.reloadPosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	tu.render(&b)
+	for _, want := range []string{"gator - alice", "All posts", "blog", "first", "q quit"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("the screen doesn't show %q", want)
+		}
+	}
+}
+
+func TestTUIText(t *testing.T) {
+	if got := fit("a\tb", 5); got != "a b  " {
+		t.Errorf("fit padded to %q", got)
+	}
+	if got := fit("abcdef", 4); got != "abc…" {
+		t.Errorf("fit truncated to %q", got)
+	}
+	if got := fit("abc", 0); got != "" {
+		t.Errorf("fit to no width returned %q", got)
+	}
+
+	got := wrap("the quick brown fox jumpsoverthelazydog", 10)
+	want := []string{"the quick", "brown fox", "jumpsovert", "helazydog"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrap returned %q, want %q", got, want)
+	}
+
+	if got := scrollTo(12, 0, 10); got != 3 {
+		t.Errorf("scrolling to row 12 of 10 gave offset %v, want 3", got)
+	}
+	if got := scrollTo(2, 5, 10); got != 2 {
+		t.Errorf("scrolling up to row 2 gave offset %v, want 2", got)
+	}
+}
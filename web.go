@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//go:embed web
+var webFS embed.FS
+
+//sessionCookie holds the session token of a web login
+const sessionCookie = "gator_session"
+
+//webPageSize is the number of posts per page of the web UI
+const webPageSize = 30
+
+//webTemplates holds one template per page, each parsed with the layout
+var webTemplates = parseWebTemplates("error.html", "login.html", "posts.html", "post.html", "subscriptions.html")
+
+func parseWebTemplates(pages ...string) map[string]*template.Template {
+	funcs := template.FuncMap{
+		"date": func(t time.Time) string {
+			return t.Format("Jan 2, 2006 15:04")
+		},
+	}
+
+	templates := map[string]*template.Template{}
+	for _, page := range pages {
+		templates[page] = template.Must(template.New(page).Funcs(funcs).ParseFS(webFS, "web/templates/layout.html", "web/templates/"+page))
+	}
+	return templates
+}
+
+type webHandler func(http.ResponseWriter, *http.Request, database.User)
+
+//webPage is the data every page template is rendered with; each page uses
+//the fields it needs
+type webPage struct {
+	Title string
+	UserName string
+	Error string
+
+	//sidebar
+	Feeds []webFeed
+	Unread int64
+	FeedSeq int64
+
+	//post list
+	Posts []webPost
+	UnreadOnly bool
+	StarredOnly bool
+	PrevURL string
+	NextURL string
+
+	//article
+	Post *webPost
+
+	//subscriptions and login forms
+	Subscriptions []webSubscription
+	Name string
+	URL string
+}
+
+type webFeed struct {
+	Seq int64
+	Name string
+	Unread int64
+}
+
+type webPost struct {
+	Seq int64
+	Title string
+	URL string
+	FeedName string
+	PublishedAt time.Time
+	Read bool
+	Starred bool
+	Summary string
+	Content template.HTML
+}
+
+type webSubscription struct {
+	Name string
+	URL string
+	AddedBy string
+	Following bool
+}
+
+func (cfg *apiConfig) webRoutes(mux *http.ServeMux) {
+	static, err := fs.Sub(webFS, "web/static")
+	if err != nil {
+		log.Fatalf("Error loading web assets: %v", err)
+	}
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+
+	mux.HandleFunc("GET /login", cfg.handlerWebLoginPage)
+	mux.HandleFunc("POST /login", cfg.handlerWebLogin)
+	mux.HandleFunc("POST /logout", cfg.handlerWebLogout)
+
+	mux.HandleFunc("GET /{$}", cfg.middlewareWeb(cfg.handlerWebPosts))
+	mux.HandleFunc("GET /posts/{seq}", cfg.middlewareWeb(cfg.handlerWebPost))
+	mux.HandleFunc("POST /posts/{seq}/read", cfg.middlewareWeb(cfg.handlerWebPostRead))
+	mux.HandleFunc("POST /posts/{seq}/star", cfg.middlewareWeb(cfg.handlerWebPostStar))
+	mux.HandleFunc("GET /subscriptions", cfg.middlewareWeb(cfg.handlerWebSubscriptions))
+	mux.HandleFunc("POST /subscriptions/add", cfg.middlewareWeb(cfg.handlerWebAddFeed))
+	mux.HandleFunc("POST /subscriptions/follow", cfg.middlewareWeb(cfg.handlerWebFollow))
+	mux.HandleFunc("POST /subscriptions/unfollow", cfg.middlewareWeb(cfg.handlerWebUnfollow))
+}
+
+//middlewareWeb resolves the user from the session cookie, sending visitors
+//without one to the login page. Forms posted from other sites are refused;
+//the cookie is SameSite too, this covers older browsers.
+func (cfg *apiConfig) middlewareWeb(handler webHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			http.Error(w, "Cross-site request refused", http.StatusForbidden)
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		user, err := getUserBySession(r.Context(), cfg.s, cookie.Value)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
+
+//sameOrigin reports whether a request comes from our own pages
+func sameOrigin(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+//renderPage renders a page; images in posts may come from anywhere, but
+//nothing else is loaded from other sites and no scripts run
+func renderPage(w http.ResponseWriter, code int, name string, page webPage) {
+	buf := &bytes.Buffer{}
+	if err := webTemplates[name].ExecuteTemplate(buf, "layout", page); err != nil {
+		log.Printf("Error rendering %v: %v", name, err)
+		http.Error(w, "Error rendering page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'self'; img-src http: https: data:; form-action 'self'; frame-ancestors 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
+
+func renderError(w http.ResponseWriter, code int, user database.User, err error) {
+	renderPage(w, code, "error.html", webPage{
+		Title: "Error",
+		UserName: user.Name,
+		Error: err.Error(),
+	})
+}
+
+//redirectBack sends the browser to the local path in the form's "next"
+//field, or to fallback
+func redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = fallback
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handlerWebLoginPage(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, http.StatusOK, "login.html", webPage{Title: "Log in"})
+}
+
+//handlerWebLogin starts a session for users with a password; passwordless
+//users can't log in on the web, anyone could claim their name
+func (cfg *apiConfig) handlerWebLogin(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-site request refused", http.StatusForbidden)
+		return
+	}
+
+	name := r.FormValue("name")
+	user, err := cfg.s.db.GetUser(r.Context(), name)
+	if err != nil || !checkPassword(user, r.FormValue("password")) {
+		renderPage(w, http.StatusUnauthorized, "login.html", webPage{
+			Title: "Log in",
+			Error: "Wrong user name or password",
+			Name: name,
+		})
+		return
+	}
+
+	token, err := createSession(r.Context(), cfg.s, user)
+	if err != nil {
+		renderPage(w, http.StatusInternalServerError, "login.html", webPage{Title: "Log in", Error: err.Error(), Name: name})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: sessionCookie,
+		Value: token,
+		Path: "/",
+		Expires: time.Now().Add(sessionTTL),
+		HttpOnly: true,
+		Secure: r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handlerWebLogout(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-site request refused", http.StatusForbidden)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := cfg.s.db.DeleteSession(r.Context(), hashToken(cookie.Value)); err != nil {
+			log.Printf("Error deleting session: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: sessionCookie,
+		Path: "/",
+		MaxAge: -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+//sidebar fills in the followed feeds with their unread counts
+func (cfg *apiConfig) sidebar(ctx context.Context, user database.User, page *webPage) ([]database.Feed, error) {
+	feeds, err := cfg.s.db.GetFeedsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting feeds for user: %v", err)
+	}
+	counts, err := cfg.s.db.GetUnreadCountsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting unread counts: %v", err)
+	}
+
+	unread := map[uuid.UUID]int64{}
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+	}
+
+	page.UserName = user.Name
+	for _, feed := range feeds {
+		page.Feeds = append(page.Feeds, webFeed{Seq: feed.Seq, Name: feed.Name, Unread: unread[feed.ID]})
+		page.Unread += unread[feed.ID]
+	}
+	return feeds, nil
+}
+
+//handlerWebPosts lists posts like browse: ?feed=<id>, ?unread=1,
+//?starred=1 and ?page=
+func (cfg *apiConfig) handlerWebPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	page := webPage{
+		Title: "All posts",
+		UnreadOnly: query.Get("unread") == "1",
+		StarredOnly: query.Get("starred") == "1",
+	}
+
+	feeds, err := cfg.sidebar(r.Context(), user, &page)
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+
+	feedID := uuid.NullUUID{}
+	if v := query.Get("feed"); v != "" {
+		seq, _ := strconv.ParseInt(v, 10, 64)
+		for _, feed := range feeds {
+			if feed.Seq == seq {
+				feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+				page.FeedSeq = seq
+				page.Title = feed.Name
+			}
+		}
+		if !feedID.Valid {
+			renderError(w, http.StatusNotFound, user, fmt.Errorf("You don't follow this feed"))
+			return
+		}
+	}
+
+	n, err := strconv.Atoi(query.Get("page"))
+	if err != nil || n < 1 {
+		n = 1
+	}
+
+	//rules hide posts after the query, so pages are cut from the listing up
+	//to this one; one extra post tells whether there is a next page
+	posts, err := listPosts(r.Context(), cfg.s, user, database.GetPostsForUserParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		UnreadOnly: page.UnreadOnly,
+		StarredOnly: page.StarredOnly,
+		Limit: int32(n*webPageSize + 1),
+	})
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+	posts = posts[min((n-1)*webPageSize, len(posts)):]
+
+	pageURL := func(n int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(n))
+		return "/?" + q.Encode()
+	}
+	if n > 1 {
+		page.PrevURL = pageURL(n - 1)
+	}
+	if len(posts) > webPageSize {
+		posts = posts[:webPageSize]
+		page.NextURL = pageURL(n + 1)
+	}
+
+	for _, post := range posts {
+		page.Posts = append(page.Posts, webPost{
+			Seq: post.Seq,
+			Title: post.Title,
+			URL: post.Url,
+			FeedName: post.FeedName,
+			PublishedAt: post.PublishedAt,
+			Read: post.ReadAt.Valid,
+			Starred: post.StarredAt.Valid,
+			Summary: summarize(stripHTML(post.Description.String), 200),
+		})
+	}
+
+	renderPage(w, http.StatusOK, "posts.html", page)
+}
+
+//handlerWebPost shows a post and marks it read
+func (cfg *apiConfig) handlerWebPost(w http.ResponseWriter, r *http.Request, user database.User) {
+	item, err := cfg.webItem(r, user)
+	if err != nil {
+		renderError(w, actionErrorStatus(err), user, err)
+		return
+	}
+
+	if !item.ReadAt.Valid {
+		if err := setItemRead(r.Context(), cfg.s, user, item.ID, true); err != nil {
+			renderError(w, http.StatusInternalServerError, user, err)
+			return
+		}
+	}
+
+	page := webPage{Title: item.Title, FeedSeq: item.FeedSeq}
+	if _, err := cfg.sidebar(r.Context(), user, &page); err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+	page.Post = &webPost{
+		Seq: item.Seq,
+		Title: item.Title,
+		URL: item.Url,
+		FeedName: item.FeedName,
+		PublishedAt: item.PublishedAt,
+		Read: true,
+		Starred: item.StarredAt.Valid,
+		Content: sanitizeHTML(itemHTML(item), item.Url),
+	}
+
+	renderPage(w, http.StatusOK, "post.html", page)
+}
+
+func (cfg *apiConfig) handlerWebPostRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	item, err := cfg.webItem(r, user)
+	if err != nil {
+		renderError(w, actionErrorStatus(err), user, err)
+		return
+	}
+	if err := setItemRead(r.Context(), cfg.s, user, item.ID, r.FormValue("read") == "1"); err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+func (cfg *apiConfig) handlerWebPostStar(w http.ResponseWriter, r *http.Request, user database.User) {
+	item, err := cfg.webItem(r, user)
+	if err != nil {
+		renderError(w, actionErrorStatus(err), user, err)
+		return
+	}
+	if err := setItemStarred(r.Context(), cfg.s, user, item.ID, r.FormValue("starred") == "1"); err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+//webItem looks up the post in the {seq} path value among the user's posts
+func (cfg *apiConfig) webItem(r *http.Request, user database.User) (database.GetItemsForUserRow, error) {
+	seq, err := strconv.ParseInt(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		return database.GetItemsForUserRow{}, fmt.Errorf("Post %w", errNotFound)
+	}
+	items, err := getItemsBySeq(r.Context(), cfg.s, user, []int64{seq})
+	if err != nil {
+		return database.GetItemsForUserRow{}, err
+	}
+	if len(items) == 0 {
+		return database.GetItemsForUserRow{}, fmt.Errorf("Post %w", errNotFound)
+	}
+	return items[0], nil
+}
+
+//handlerWebSubscriptions lists every feed with forms to follow, unfollow
+//and add feeds
+func (cfg *apiConfig) handlerWebSubscriptions(w http.ResponseWriter, r *http.Request, user database.User) {
+	cfg.renderSubscriptions(w, r, user, http.StatusOK, webPage{})
+}
+
+func (cfg *apiConfig) renderSubscriptions(w http.ResponseWriter, r *http.Request, user database.User, code int, page webPage) {
+	page.Title = "Subscriptions"
+	followed, err := cfg.sidebar(r.Context(), user, &page)
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, user, err)
+		return
+	}
+	feeds, err := cfg.s.db.GetFeeds(r.Context())
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, user, fmt.Errorf("Error getting feeds: %v", err))
+		return
+	}
+
+	following := map[uuid.UUID]bool{}
+	for _, feed := range followed {
+		following[feed.ID] = true
+	}
+
+	for _, feed := range feeds {
+		name, err := cfg.s.db.GetUserNameFromID(r.Context(), feed.UserID)
+		if err != nil {
+			renderError(w, http.StatusInternalServerError, user, fmt.Errorf("Error getting user name from id: %v", err))
+			return
+		}
+		page.Subscriptions = append(page.Subscriptions, webSubscription{
+			Name: feed.Name,
+			URL: feed.Url,
+			AddedBy: name,
+			Following: following[feed.ID],
+		})
+	}
+
+	renderPage(w, code, "subscriptions.html", page)
+}
+
+func (cfg *apiConfig) handlerWebAddFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	name, url := strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("url"))
+	if name == "" || url == "" {
+		cfg.renderSubscriptions(w, r, user, http.StatusBadRequest, webPage{Error: "Name and url are required", Name: name, URL: url})
+		return
+	}
+
+	if _, err := addFeed(r.Context(), cfg.s, user, name, url); err != nil {
+		cfg.renderSubscriptions(w, r, user, actionErrorStatus(err), webPage{Error: err.Error(), Name: name, URL: url})
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handlerWebFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	if _, _, err := followFeed(r.Context(), cfg.s, user, r.FormValue("url")); err != nil && !errors.Is(err, errConflict) {
+		cfg.renderSubscriptions(w, r, user, actionErrorStatus(err), webPage{Error: err.Error()})
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handlerWebUnfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	if _, err := unfollowFeed(r.Context(), cfg.s, user, r.FormValue("url")); err != nil {
+		cfg.renderSubscriptions(w, r, user, actionErrorStatus(err), webPage{Error: err.Error()})
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+//summarize shortens text to about n characters on a word boundary
+func summarize(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)[:n]
+	if i := strings.LastIndex(string(runes), " "); i > 0 {
+		return string(runes)[:i] + "…"
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+//webClient browses a server on e's database with a session cookie,
+//without following redirects
+type webClient struct {
+	t       *testing.T
+	url     string
+	session string
+}
+
+func (e *testEnv) webClient(name string) *webClient {
+	e.t.Helper()
+	token, err := createSession(context.Background(), e.s, e.user(name))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	server := httptest.NewServer((&apiConfig{s: e.s}).routes())
+	e.t.Cleanup(server.Close)
+	return &webClient{t: e.t, url: server.URL, session: token}
+}
+
+//send makes a request with the form, if any, returning the status and the
+//redirect location or the body
+func (c *webClient) send(method, path string, form url.Values) (int, string) {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: c.session})
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != "" {
+		return resp.StatusCode, location
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestWebPostsApplyRules(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+
+	//35 posts to list, with 5 to hide among the first page's
+	now := time.Now()
+	items := []RSSItem{}
+	for i := 1; i <= 35; i++ {
+		items = append(items, testItem(fmt.Sprintf("post %02d", i), now.Add(-time.Duration(2*i)*time.Minute)))
+		if i <= 5 {
+			items = append(items, testItem(fmt.Sprintf("sponsored %02d", i), now.Add(-time.Duration(2*i+1)*time.Minute)))
+		}
+	}
+	e.fetch("http://example.com/feed", items...)
+	e.mustRun("rule", "add", "sponsored")
+	c := e.webClient("alice")
+
+	listed := func(body string) []string {
+		titles := []string{}
+		for i := 1; i <= 35; i++ {
+			if title := fmt.Sprintf("post %02d", i); strings.Contains(body, title) {
+				titles = append(titles, title)
+			}
+		}
+		return titles
+	}
+
+	code, body := c.send(http.MethodGet, "/", nil)
+	if code != http.StatusOK || strings.Contains(body, "sponsored") {
+		t.Fatalf("the first page (%v) shows hidden posts:\n%v", code, body)
+	}
+	if got := listed(body); len(got) != webPageSize || got[0] != "post 01" || got[len(got)-1] != "post 30" {
+		t.Fatalf("the first page lists %v, want post 01 to post 30", got)
+	}
+	if !strings.Contains(body, "page=2") {
+		t.Fatal("the first page doesn't link to the next")
+	}
+
+	_, body = c.send(http.MethodGet, "/?page=2", nil)
+	if got, want := listed(body), []string{"post 31", "post 32", "post 33", "post 34", "post 35"}; !slices.Equal(got, want) {
+		t.Fatalf("the second page lists %v, want %v", got, want)
+	}
+	if strings.Contains(body, "page=3") {
+		t.Fatal("the last page links to a next one")
+	}
+}
+
+func TestWebLogin(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.input("correct horse", "correct horse")
+	e.mustRun("register", "bob", "--password")
+	c := e.webClient("bob")
+	c.session = ""
+
+	if code, location := c.send(http.MethodGet, "/", nil); code != http.StatusSeeOther || location != "/login" {
+		t.Fatalf("the posts page without a session: got %v %v, want a redirect to /login", code, location)
+	}
+
+	login := func(name, password string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, c.url+"/login", strings.NewReader(url.Values{"name": {name}, "password": {password}}.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	for _, creds := range [][2]string{{"bob", "wrong"}, {"alice", ""}, {"carol", "correct horse"}} {
+		resp, err := login(creds[0], creds[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("logging in as %v with %q: got %v, want 401", creds[0], creds[1], resp.StatusCode)
+		}
+	}
+
+	resp, err := login("bob", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			c.session = cookie.Value
+		}
+	}
+	if resp.StatusCode != http.StatusSeeOther || c.session == "" {
+		t.Fatalf("logging in as bob: got %v without a session cookie", resp.StatusCode)
+	}
+	if code, body := c.send(http.MethodGet, "/", nil); code != http.StatusOK || !strings.Contains(body, "bob") {
+		t.Fatalf("the posts page after logging in: got %v", code)
+	}
+
+	//logging out ends the session, not just the cookie
+	if code, location := c.send(http.MethodPost, "/logout", url.Values{}); code != http.StatusSeeOther || location != "/login" {
+		t.Fatalf("logging out: got %v %v", code, location)
+	}
+	if code, _ := c.send(http.MethodGet, "/", nil); code != http.StatusSeeOther {
+		t.Fatalf("the posts page with an ended session: got %v, want a redirect", code)
+	}
+}
+
+func TestWebActions(t *testing.T) {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.fetch("http://example.com/feed", RSSItem{
+		Title:       "first",
+		Link:        "http://example.com/first",
+		Description: `<p>hello</p><script>alert(1)</script>`,
+		PubDate:     time.Now().Format(time.RFC1123),
+	})
+	e.mustRun("register", "bob")
+	c := e.webClient("alice")
+	seq := fmt.Sprint(e.posts("alice")[0].Seq)
+
+	code, body := c.send(http.MethodGet, "/posts/"+seq, nil)
+	if code != http.StatusOK || !strings.Contains(body, "<p>hello</p>") || strings.Contains(body, "<script>alert") {
+		t.Fatalf("the post page (%v) doesn't show the sanitized post:\n%v", code, body)
+	}
+	if !e.posts("alice")[0].ReadAt.Valid {
+		t.Error("opening the post didn't mark it read")
+	}
+	if code, _ := c.send(http.MethodGet, "/posts/999", nil); code != http.StatusNotFound {
+		t.Errorf("opening a missing post: got %v, want 404", code)
+	}
+	if code, _ := e.webClient("bob").send(http.MethodGet, "/posts/"+seq, nil); code != http.StatusNotFound {
+		t.Errorf("opening a post of a feed bob doesn't follow: got %v, want 404", code)
+	}
+
+	//forms go back to a local next page only
+	code, location := c.send(http.MethodPost, "/posts/"+seq+"/star", url.Values{"starred": {"1"}, "next": {"/?page=2"}})
+	if code != http.StatusSeeOther || location != "/?page=2" || !e.posts("alice")[0].StarredAt.Valid {
+		t.Fatalf("starring the post: got %v %v", code, location)
+	}
+	code, location = c.send(http.MethodPost, "/posts/"+seq+"/read", url.Values{"read": {"0"}, "next": {"//evil.example"}})
+	if code != http.StatusSeeOther || location != "/" || e.posts("alice")[0].ReadAt.Valid {
+		t.Fatalf("marking the post unread: got %v %v", code, location)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+"/posts/"+seq+"/star", strings.NewReader("starred=0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://evil.example")
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: c.session})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !e.posts("alice")[0].StarredAt.Valid {
+		t.Fatalf("a cross-site form: got %v, want 403 and the post still starred", resp.StatusCode)
+	}
+
+	c = e.webClient("bob")
+	if code, _ := c.send(http.MethodPost, "/subscriptions/add", url.Values{"name": {"news"}}); code != http.StatusBadRequest {
+		t.Errorf("adding a feed without a url: got %v, want 400", code)
+	}
+	if code, location := c.send(http.MethodPost, "/subscriptions/add", url.Values{"name": {"news"}, "url": {"http://example.com/news"}}); code != http.StatusSeeOther || location != "/subscriptions" {
+		t.Fatalf("adding a feed: got %v %v", code, location)
+	}
+	if code, _ := c.send(http.MethodPost, "/subscriptions/follow", url.Values{"url": {"http://example.com/feed"}}); code != http.StatusSeeOther {
+		t.Fatalf("following blog: got %v", code)
+	}
+	if code, _ := c.send(http.MethodPost, "/subscriptions/unfollow", url.Values{"url": {"http://example.com/news"}}); code != http.StatusSeeOther {
+		t.Fatalf("unfollowing news: got %v", code)
+	}
+	if got, want := e.titles("bob"), []string{"first"}; !slices.Equal(got, want) {
+		t.Fatalf("bob sees %v, want %v", got, want)
+	}
+	code, body = c.send(http.MethodGet, "/subscriptions", nil)
+	if code != http.StatusOK || !strings.Contains(body, "http://example.com/news") {
+		t.Fatalf("the subscriptions page (%v) doesn't list news", code)
+	}
+}
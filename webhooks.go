@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//webhook delivery statuses
+const (
+	deliveryPending = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed = "failed"
+)
+
+//webhook events
+const (
+	webhookEventPost = "post.created"
+	webhookEventPing = "ping"
+)
+
+const (
+	//maxWebhookAttempts is how many times a delivery is tried before it's
+	//marked failed
+	maxWebhookAttempts = 8
+	//webhookRetryBase is the wait after the first failure, doubled after
+	//each one after that
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax = 2 * time.Hour
+	//webhookLease keeps other workers off a delivery while it's being sent
+	webhookLease = 2 * time.Minute
+	webhookBatchSize = 20
+	webhookPollInterval = 10 * time.Second
+)
+
+//webhookTimeout is how long a receiver has to answer, a var so tests don't
+//wait that long
+var webhookTimeout = 10 * time.Second
+
+//webhookPost is the post sent in a post.created delivery
+type webhookPost struct {
+	ID uuid.UUID `json:"id"`
+	FeedID uuid.UUID `json:"feed_id"`
+	FeedName string `json:"feed_name"`
+	FeedUrl string `json:"feed_url"`
+	Title string `json:"title"`
+	Url string `json:"url"`
+	Description string `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type webhookPayload struct {
+	Event string `json:"event"`
+	DeliveryID uuid.UUID `json:"delivery_id"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	Post *webhookPost `json:"post,omitempty"`
+}
+
+//signWebhook signs a delivery's timestamp and body with the webhook's
+//secret. Receivers recompute it to check the delivery came from gator and
+//reject old timestamps to stop replays.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//sendWebhook posts a signed payload and returns the response's status code,
+//which is 0 when no response came back
+func sendWebhook(ctx context.Context, targetURL, secret string, payload webhookPayload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("Error encoding payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("Error creating request: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gator-webhook")
+	req.Header.Set("X-Gator-Event", payload.Event)
+	req.Header.Set("X-Gator-Delivery", payload.DeliveryID.String())
+	req.Header.Set("X-Gator-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Gator-Signature", signWebhook(secret, timestamp, body))
+
+	client := &http.Client{
+		Timeout: webhookTimeout,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Error making the request: %v", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("Unexpected status %v", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+//webhookRetryDelay is how long to wait after the given number of failed
+//attempts
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := webhookRetryBase
+	for i := int32(1); i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMax)
+}
+
+//enqueueWebhooks queues a new post for every webhook it matches
+func enqueueWebhooks(s *state, post database.Post) error {
+	_, err := s.db.EnqueueWebhookDeliveries(context.Background(), database.EnqueueWebhookDeliveriesParams{
+		CreatedAt: time.Now(),
+		PostID: post.ID,
+	})
+	return err
+}
+
+//deliverWebhooks sends the deliveries that are due and returns how many
+//were tried
+func deliverWebhooks(ctx context.Context, s *state) (int, error) {
+	now := time.Now()
+	deliveries, err := s.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: now.Add(webhookLease),
+		Now: now,
+		MaxDeliveries: webhookBatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Error claiming webhook deliveries: %v", err)
+	}
+
+	for _, d := range deliveries {
+		code, sendErr := sendWebhook(ctx, d.WebhookUrl, d.Secret, webhookPayload{
+			Event: webhookEventPost,
+			DeliveryID: d.ID,
+			WebhookID: d.WebhookID,
+			Post: &webhookPost{
+				ID: d.PostID,
+				FeedID: d.FeedID,
+				FeedName: d.FeedName,
+				FeedUrl: d.FeedUrl,
+				Title: d.Title,
+				Url: d.Url,
+				Description: stripHTML(d.Description.String),
+				PublishedAt: d.PublishedAt,
+			},
+		})
+
+		attempts := d.Attempts + 1
+		params := database.RecordWebhookAttemptParams{
+			ID: d.ID,
+			Status: deliveryDelivered,
+			Attempts: attempts,
+			LastAttemptAt: sql.NullTime{Time: time.Now(), Valid: true},
+			NextAttemptAt: time.Now(),
+			ResponseCode: sql.NullInt32{Int32: int32(code), Valid: code != 0},
+		}
+		if sendErr != nil {
+			params.Status = deliveryPending
+			params.NextAttemptAt = time.Now().Add(webhookRetryDelay(attempts))
+			params.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+			if attempts >= maxWebhookAttempts {
+				params.Status = deliveryFailed
+			}
+			log.Printf("Webhook delivery %v to %v failed (attempt %v): %v", d.ID, d.WebhookUrl, attempts, sendErr)
+		}
+
+		if err := s.db.RecordWebhookAttempt(ctx, params); err != nil {
+			return len(deliveries), fmt.Errorf("Error recording webhook delivery: %v", err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+//runWebhookWorker delivers webhooks until ctx is done
+func runWebhookWorker(ctx context.Context, s *state) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		//keep going while there's a backlog
+		n, err := deliverWebhooks(ctx, s)
+		if err != nil {
+			log.Println(err)
+		}
+		if err == nil && n == webhookBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func handlerWebhookAdd(s *state, cmd command, user database.User) error {
+	u, err := url.Parse(cmd.args[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Webhook url must be an http(s) url: %v", cmd.args[0])
+	}
+
+	feedID := uuid.NullUUID{}
+	if v := cmd.flagString("feed"); v != "" {
+		follow, err := findFollowedFeed(s, user, v)
+		if err != nil {
+			return err
+		}
+		feedID = uuid.NullUUID{UUID: follow.FeedID, Valid: true}
+	}
+	keyword := cmd.flagString("keyword")
+
+	secret, _, err := generateToken(webhookSecretPrefix)
+	if err != nil {
+		return fmt.Errorf("Error generating webhook secret: %v", err)
+	}
+
+	webhook, err := s.db.CreateWebhook(context.Background(), database.CreateWebhookParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID: user.ID,
+		Url: u.String(),
+		Secret: secret,
+		FeedID: feedID,
+		Keyword: sql.NullString{String: keyword, Valid: keyword != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating webhook: %v", err)
+	}
+
+	fmt.Println("New webhook:")
+	fmt.Printf("  - ID: %v\n", webhook.ID)
+	fmt.Printf("  - Url: %v\n", webhook.Url)
+	if feedID.Valid {
+		fmt.Printf("  - Feed: %v\n", cmd.flagString("feed"))
+	}
+	if keyword != "" {
+		fmt.Printf("  - Keyword: %v\n", keyword)
+	}
+	fmt.Println("\nSigning secret (shown once, store it somewhere safe):")
+	fmt.Printf("  %v\n", secret)
+	return nil
+}
+
+func handlerWebhookList(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Error getting webhooks: %v", err)
+	}
+
+	records := []webhookRecord{}
+	for _, webhook := range webhooks {
+		records = append(records, newWebhookRecord(webhook))
+	}
+
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	fmt.Println("Webhooks:")
+	for _, webhook := range records {
+		fmt.Println()
+		fmt.Printf("  - %v\n", webhook.Url)
+		fmt.Printf("    ID: %v\n", webhook.ID)
+		if webhook.Feed != "" {
+			fmt.Printf("    Feed: %v\n", webhook.Feed)
+		}
+		if webhook.Keyword != "" {
+			fmt.Printf("    Keyword: %v\n", webhook.Keyword)
+		}
+		fmt.Printf("    Created at: %v\n", webhook.CreatedAt)
+	}
+	return nil
+}
+
+func handlerWebhookRemove(s *state, cmd command, user database.User) error {
+	webhook, err := findWebhook(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.DeleteWebhook(context.Background(), database.DeleteWebhookParams{
+		ID: webhook.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting webhook: %v", err)
+	}
+
+	fmt.Printf("Removed webhook %v\n", webhook.Url)
+	return nil
+}
+
+//handlerWebhookTest sends a ping to a webhook straight away, outside the
+//delivery queue, to check the receiver and its signature checks
+func handlerWebhookTest(s *state, cmd command, user database.User) error {
+	webhook, err := findWebhook(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	code, err := sendWebhook(context.Background(), webhook.Url, webhook.Secret, webhookPayload{
+		Event: webhookEventPing,
+		DeliveryID: uuid.New(),
+		WebhookID: webhook.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error sending test webhook to %v: %v", webhook.Url, err)
+	}
+
+	fmt.Printf("Webhook %v answered %v\n", webhook.Url, code)
+	return nil
+}
+
+func handlerWebhookLog(s *state, cmd command, user database.User) error {
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	limit, err := cmd.flagLimit("limit")
+	if err != nil {
+		return err
+	}
+
+	webhookID := uuid.NullUUID{}
+	if len(cmd.args) > 0 {
+		webhook, err := findWebhook(s, user, cmd.args[0])
+		if err != nil {
+			return err
+		}
+		webhookID = uuid.NullUUID{UUID: webhook.ID, Valid: true}
+	}
+
+	deliveries, err := s.db.GetWebhookDeliveriesForUser(context.Background(), database.GetWebhookDeliveriesForUserParams{
+		UserID: user.ID,
+		WebhookID: webhookID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("Error getting webhook deliveries: %v", err)
+	}
+
+	records := []webhookDeliveryRecord{}
+	for _, d := range deliveries {
+		records = append(records, newWebhookDeliveryRecord(d))
+	}
+
+	if format != outputText {
+		return writeRecords(os.Stdout, format, records)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No webhook deliveries yet")
+		return nil
+	}
+	for _, d := range records {
+		fmt.Printf("%v  %-9v  %v\n", d.CreatedAt.Format(time.DateTime), d.Status, d.PostTitle)
+		fmt.Printf("    To: %v\n", d.WebhookUrl)
+		fmt.Printf("    Attempts: %v", d.Attempts)
+		if d.ResponseCode != 0 {
+			fmt.Printf(", last response %v", d.ResponseCode)
+		}
+		fmt.Println()
+		if d.Error != "" {
+			fmt.Printf("    Error: %v\n", d.Error)
+		}
+		if d.Status == deliveryPending && d.Attempts > 0 {
+			fmt.Printf("    Next attempt: %v\n", d.NextAttemptAt.Format(time.DateTime))
+		}
+	}
+	return nil
+}
+
+//handlerWebhookDeliver sends the deliveries that are due once, for setups
+//that don't keep agg running
+func handlerWebhookDeliver(s *state, cmd command) error {
+	total := 0
+	for {
+		n, err := deliverWebhooks(context.Background(), s)
+		total += n
+		if err != nil {
+			return err
+		}
+		if n < webhookBatchSize {
+			break
+		}
+	}
+	fmt.Printf("Tried %v webhook deliveries\n", total)
+	return nil
+}
+
+//findWebhook finds one of the user's webhooks by id or the id's first
+//eight characters
+func findWebhook(s *state, user database.User, id string) (database.GetWebhooksForUserRow, error) {
+	webhooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+	if err != nil {
+		return database.GetWebhooksForUserRow{}, fmt.Errorf("Error getting webhooks: %v", err)
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.ID.String() == id || shortID(webhook.ID) == id {
+			return webhook, nil
+		}
+	}
+	return database.GetWebhooksForUserRow{}, fmt.Errorf("No webhook with id %v", id)
+}
+
+//completeWebhooks suggests the short ids of the current user's webhooks
+func completeWebhooks(s *state) []string {
+	user, err := currentUser(context.Background(), s)
+	if err != nil {
+		return nil
+	}
+	webhooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	ids := []string{}
+	for _, webhook := range webhooks {
+		ids = append(ids, shortID(webhook.ID))
+	}
+	return ids
+}
+
+type webhookRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Url       string    `json:"url"`
+	Feed      string    `json:"feed"`
+	Keyword   string    `json:"keyword"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWebhookRecord(webhook database.GetWebhooksForUserRow) webhookRecord {
+	return webhookRecord{
+		ID:        webhook.ID,
+		Url:       webhook.Url,
+		Feed:      webhook.FeedName.String,
+		Keyword:   webhook.Keyword.String,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func (r webhookRecord) header() []string {
+	return []string{"id", "url", "feed", "keyword", "created_at"}
+}
+
+func (r webhookRecord) fields() []string {
+	return []string{r.ID.String(), r.Url, r.Feed, r.Keyword, formatTime(r.CreatedAt)}
+}
+
+type webhookDeliveryRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	WebhookID     uuid.UUID  `json:"webhook_id"`
+	WebhookUrl    string     `json:"webhook_url"`
+	PostID        uuid.UUID  `json:"post_id"`
+	PostTitle     string     `json:"post_title"`
+	Status        string     `json:"status"`
+	Attempts      int32      `json:"attempts"`
+	ResponseCode  int32      `json:"response_code"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+}
+
+func newWebhookDeliveryRecord(d database.GetWebhookDeliveriesForUserRow) webhookDeliveryRecord {
+	return webhookDeliveryRecord{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID,
+		WebhookUrl:    d.WebhookUrl,
+		PostID:        d.PostID,
+		PostTitle:     d.PostTitle,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		ResponseCode:  d.ResponseCode.Int32,
+		Error:         d.Error.String,
+		CreatedAt:     d.CreatedAt,
+		LastAttemptAt: nullTimePtr(d.LastAttemptAt),
+		NextAttemptAt: d.NextAttemptAt,
+	}
+}
+
+func (r webhookDeliveryRecord) header() []string {
+	return []string{"id", "webhook_id", "webhook_url", "post_id", "post_title", "status", "attempts", "response_code", "error", "created_at", "last_attempt_at", "next_attempt_at"}
+}
+
+func (r webhookDeliveryRecord) fields() []string {
+	return []string{r.ID.String(), r.WebhookID.String(), r.WebhookUrl, r.PostID.String(), r.PostTitle, r.Status, fmt.Sprint(r.Attempts), fmt.Sprint(r.ResponseCode), r.Error, formatTime(r.CreatedAt), formatTimePtr(r.LastAttemptAt), formatTime(r.NextAttemptAt)}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/npayetteraynauld/Blog-Aggregator/internal/database"
+)
+
+//webhookReceiver is a local stand-in for a webhook receiver: it answers
+//deliveries whose signature checks out with the next of its statuses, the
+//last one repeating, and others with 401
+type webhookReceiver struct {
+	*httptest.Server
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	payloads []webhookPayload
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	r := &webhookReceiver{t: t, statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Errorf("reading delivery: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-Gator-Timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)).Abs() > time.Minute {
+		r.t.Errorf("delivery timestamp %q isn't now", req.Header.Get("X-Gator-Timestamp"))
+	}
+	want := signWebhook(r.secret, timestamp, body)
+	if !hmac.Equal([]byte(req.Header.Get("X-Gator-Signature")), []byte(want)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Errorf("delivery body %q: %v", body, err)
+	}
+	if req.Header.Get("X-Gator-Event") != payload.Event || req.Header.Get("X-Gator-Delivery") != payload.DeliveryID.String() {
+		r.t.Errorf("delivery headers %v don't match its body %+v", req.Header, payload)
+	}
+	r.payloads = append(r.payloads, payload)
+
+	status := r.statuses[0]
+	if len(r.statuses) > 1 {
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *webhookReceiver) received() []webhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookPayload(nil), r.payloads...)
+}
+
+//newWebhookEnv has alice add a feed and a webhook pointing at receiver, and
+//fetch one post from the feed, which queues a delivery
+func newWebhookEnv(t *testing.T, receiver *webhookReceiver) *testEnv {
+	e := newTestEnv(t)
+	e.mustRun("register", "alice")
+	e.mustRun("addfeed", "blog", "http://example.com/feed")
+	e.mustRun("webhook", "add", receiver.URL)
+
+	webhooks, err := e.s.db.GetWebhooksForUser(context.Background(), e.user("alice").ID)
+	if err != nil || len(webhooks) != 1 {
+		t.Fatalf("getting alice's webhook: %v %v", webhooks, err)
+	}
+	receiver.secret = webhooks[0].Secret
+
+	e.fetch("http://example.com/feed", testItem("new post", time.Now()))
+	return e
+}
+
+//deliver runs deliverWebhooks once and checks how many deliveries it tried
+func (e *testEnv) deliver(want int) {
+	e.t.Helper()
+	n, err := deliverWebhooks(context.Background(), e.s)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if n != want {
+		e.t.Fatalf("tried %v deliveries, want %v", n, want)
+	}
+}
+
+//delivery is alice's only delivery, as webhook log shows it
+func (e *testEnv) delivery() database.GetWebhookDeliveriesForUserRow {
+	e.t.Helper()
+	deliveries, err := e.s.db.GetWebhookDeliveriesForUser(context.Background(), database.GetWebhookDeliveriesForUserParams{
+		UserID: e.user("alice").ID,
+		Limit:  10,
+	})
+	if err != nil || len(deliveries) != 1 {
+		e.t.Fatalf("getting alice's deliveries: %v %v", deliveries, err)
+	}
+	return deliveries[0]
+}
+
+//makeDue brings a delivery's next attempt forward to now, as if its retry
+//delay had passed
+func (e *testEnv) makeDue(d database.GetWebhookDeliveriesForUserRow) {
+	e.t.Helper()
+	err := e.s.db.RecordWebhookAttempt(context.Background(), database.RecordWebhookAttemptParams{
+		ID:            d.ID,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastAttemptAt: d.LastAttemptAt,
+		NextAttemptAt: time.Now().Add(-time.Second),
+		ResponseCode:  d.ResponseCode,
+		Error:         d.Error,
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{9, 2 * time.Hour},
+		{100, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSendWebhookSignature(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	receiver.secret = "whsec_test"
+
+	code, err := sendWebhook(context.Background(), receiver.URL, "whsec_test", webhookPayload{Event: webhookEventPing})
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("sending a ping: %v %v", code, err)
+	}
+
+	//the signature doesn't check out with another secret
+	code, err = sendWebhook(context.Background(), receiver.URL, "whsec_other", webhookPayload{Event: webhookEventPing})
+	if err == nil || code != http.StatusUnauthorized {
+		t.Fatalf("sending a ping signed with another secret: %v %v", code, err)
+	}
+	if n := len(receiver.received()); n != 1 {
+		t.Fatalf("the receiver accepted %v pings, want 1", n)
+	}
+}
+
+func TestDeliverWebhooksRetries(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+	e := newWebhookEnv(t, receiver)
+
+	before := time.Now()
+	e.deliver(1)
+	d := e.delivery()
+	if d.Status != deliveryPending || d.Attempts != 1 || d.ResponseCode.Int32 != http.StatusInternalServerError || !d.Error.Valid {
+		t.Fatalf("after a 500 the delivery is %+v, want pending with the response logged", d)
+	}
+	if wait := d.NextAttemptAt.Sub(before); wait < webhookRetryBase || wait > webhookRetryBase+time.Minute {
+		t.Fatalf("the retry is due in %v, want %v", wait, webhookRetryBase)
+	}
+
+	//nothing is sent again until the retry is due
+	e.deliver(0)
+	e.makeDue(d)
+	e.deliver(1)
+	d = e.delivery()
+	if d.Status != deliveryDelivered || d.Attempts != 2 || d.ResponseCode.Int32 != http.StatusOK {
+		t.Fatalf("after a 200 the delivery is %+v, want delivered", d)
+	}
+	e.deliver(0)
+
+	payloads := receiver.received()
+	if len(payloads) != 2 {
+		t.Fatalf("the receiver got %v deliveries, want 2", len(payloads))
+	}
+	for _, p := range payloads {
+		if p.Event != webhookEventPost || p.DeliveryID != d.ID || p.Post == nil || p.Post.Title != "new post" {
+			t.Errorf("the receiver got %+v, want the new post", p)
+		}
+	}
+
+	if _, err := e.run("webhook", "log", "--limit", "0"); err == nil {
+		t.Error("webhook log --limit 0 succeeded")
+	}
+	out := e.mustRun("webhook", "log")
+	if !strings.Contains(out, deliveryDelivered) || !strings.Contains(out, "Attempts: 2, last response 200") {
+		t.Errorf("webhook log printed %q", out)
+	}
+}
+
+func TestDeliverWebhooksTimeout(t *testing.T) {
+	timeout := webhookTimeout
+	webhookTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { webhookTimeout = timeout })
+
+	stop := make(chan struct{})
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	slow := receiver.Config.Handler
+	receiver.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-stop:
+		case <-time.After(time.Second):
+		}
+		slow.ServeHTTP(w, req)
+	})
+	e := newWebhookEnv(t, receiver)
+	defer close(stop)
+
+	e.deliver(1)
+	d := e.delivery()
+	if d.Status != deliveryPending || d.Attempts != 1 || d.ResponseCode.Valid || !strings.Contains(d.Error.String, "Timeout") {
+		t.Fatalf("after a timeout the delivery is %+v, want pending with the error logged", d)
+	}
+}
+
+func TestDeliverWebhooksGivesUp(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	e := newWebhookEnv(t, receiver)
+
+	for attempt := int32(1); attempt <= maxWebhookAttempts; attempt++ {
+		e.deliver(1)
+		d := e.delivery()
+		if d.Attempts != attempt {
+			t.Fatalf("attempt %v recorded as %v", attempt, d.Attempts)
+		}
+		if attempt < maxWebhookAttempts {
+			if d.Status != deliveryPending {
+				t.Fatalf("after attempt %v the delivery is %v, want pending", attempt, d.Status)
+			}
+			//each wait doubles the one before
+			wait := d.NextAttemptAt.Sub(d.LastAttemptAt.Time)
+			if want := webhookRetryDelay(attempt); wait < want-time.Second || wait > want+time.Second {
+				t.Fatalf("after attempt %v the retry is due in %v, want %v", attempt, wait, want)
+			}
+			e.makeDue(d)
+		}
+	}
+
+	d := e.delivery()
+	if d.Status != deliveryFailed || d.ResponseCode.Int32 != http.StatusServiceUnavailable {
+		t.Fatalf("after %v attempts the delivery is %+v, want failed", maxWebhookAttempts, d)
+	}
+	e.makeDue(d)
+	e.deliver(0)
+	if n := len(receiver.received()); n != maxWebhookAttempts {
+		t.Fatalf("the receiver got %v deliveries, want %v", n, maxWebhookAttempts)
+	}
+
+	out := e.mustRun("webhook", "log")
+	if !strings.Contains(out, deliveryFailed) || !strings.Contains(out, "Unexpected status 503") {
+		t.Errorf("webhook log printed %q", out)
+	}
+}